@@ -58,6 +58,8 @@ instance.interceptors.response.use(
           );
           // Cập nhật access token mới vào localStorage
           localStorage.setItem("accessToken", data.access_token);
+          // Refresh token được xoay vòng mỗi lần refresh, token cũ không còn dùng được
+          localStorage.setItem("refreshToken", data.refresh_token);
 
           // Cập nhật header Authorization cho instance và request gốc
           instance.defaults.headers.common[
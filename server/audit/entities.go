@@ -0,0 +1,130 @@
+package audit
+
+import (
+	"encoding/json"
+
+	"ecommerce-project/models"
+
+	"gorm.io/gorm"
+)
+
+// Loader đọc trạng thái hiện tại của một entity theo id, trả về gorm.ErrRecordNotFound nếu không có
+type Loader func(db *gorm.DB, id string) (interface{}, error)
+
+// loaders ánh xạ tên tài nguyên trong route (/api/admin/<tài nguyên>/:id hoặc /api/<tài nguyên>/:id)
+// tới cách đọc entity
+// Sản phẩm, variant và danh mục được đọc cả khi đã nằm trong thùng rác để ghi nhận việc xoá mềm và khôi phục.
+var loaders = map[string]Loader{
+	"products": func(db *gorm.DB, id string) (interface{}, error) {
+		var product models.Product
+		err := db.Unscoped().Preload("Variants").First(&product, "id = ?", id).Error
+		return product, err
+	},
+	"variants": func(db *gorm.DB, id string) (interface{}, error) {
+		var variant models.ProductVariant
+		err := db.Unscoped().First(&variant, "id = ?", id).Error
+		return variant, err
+	},
+	"categories": func(db *gorm.DB, id string) (interface{}, error) {
+		var category models.Category
+		err := db.Unscoped().First(&category, "id = ?", id).Error
+		return category, err
+	},
+	"reviews": func(db *gorm.DB, id string) (interface{}, error) {
+		var review models.Review
+		err := db.First(&review, "id = ?", id).Error
+		return review, err
+	},
+	"media": func(db *gorm.DB, id string) (interface{}, error) {
+		var media models.Media
+		err := db.First(&media, "id = ?", id).Error
+		return media, err
+	},
+	"orders": func(db *gorm.DB, id string) (interface{}, error) {
+		var order models.Order
+		err := db.Preload("Lines").First(&order, "id = ?", id).Error
+		return order, err
+	},
+	// Yêu cầu đổi trả được ghi kèm các dòng và lần hoàn tiền để thấy thay đổi khi nhận hàng, hoàn tiền
+	"returns": func(db *gorm.DB, id string) (interface{}, error) {
+		var request models.ReturnRequest
+		err := db.Preload("Lines").Preload("Refunds").First(&request, "id = ?", id).Error
+		return request, err
+	},
+	"roles": func(db *gorm.DB, id string) (interface{}, error) {
+		var role models.Role
+		err := db.First(&role, "name = ?", id).Error
+		return role, err
+	},
+	// Người dùng được ghi dưới dạng UserResponse (không có mật khẩu, secret 2FA) kèm vai trò nhân viên
+	"users": func(db *gorm.DB, id string) (interface{}, error) {
+		var user models.User
+		if err := db.First(&user, "id = ?", id).Error; err != nil {
+			return nil, err
+		}
+		var roles []string
+		if err := db.Model(&models.UserRole{}).Where("user_id = ?", user.ID).
+			Order("role_name ASC").Pluck("role_name", &roles).Error; err != nil {
+			return nil, err
+		}
+		return struct {
+			models.UserResponse
+			StaffRoles []string `json:"staff_roles"`
+		}{models.NewUserResponse(user), roles}, nil
+	},
+}
+
+// RegisterEntity đăng ký cách đọc entity cho tài nguyên resource
+func RegisterEntity(resource string, loader Loader) {
+	loaders[resource] = loader
+}
+
+// snapshot chuyển entity thành map JSON để so sánh từng trường
+func snapshot(entity interface{}) map[string]interface{} {
+	if entity == nil {
+		return nil
+	}
+	data, err := json.Marshal(entity)
+	if err != nil {
+		return nil
+	}
+	var result map[string]interface{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil
+	}
+	return result
+}
+
+// diff trả về các trường khác nhau giữa before và after
+func diff(before, after map[string]interface{}) map[string]models.AuditChange {
+	changes := map[string]models.AuditChange{}
+	for key, value := range before {
+		afterValue, ok := after[key]
+		if !ok || !JSONEqual(value, afterValue) {
+			changes[key] = models.AuditChange{Before: value, After: afterValue}
+		}
+	}
+	for key, value := range after {
+		if _, ok := before[key]; !ok {
+			changes[key] = models.AuditChange{Before: nil, After: value}
+		}
+	}
+	// updated_at luôn thay đổi khi cập nhật, không mang thông tin
+	if len(changes) > 1 {
+		delete(changes, "updated_at")
+	}
+	return changes
+}
+
+// JSONEqual cho biết hai giá trị có cùng biểu diễn JSON
+func JSONEqual(a, b interface{}) bool {
+	left, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	right, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+	return string(left) == string(right)
+}
@@ -0,0 +1,152 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"ecommerce-project/config"
+	"ecommerce-project/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Giới hạn phần response được giữ lại để đọc id của entity vừa tạo
+const maxCapturedBody = 1 << 20
+
+// contextKey đánh dấu request đã được audit, tránh ghi hai lần khi có nhiều RequirePermission
+const contextKey = "audit"
+
+// bufferedWriter giữ lại toàn bộ response cho tới khi audit log được ghi xong
+type bufferedWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bufferedWriter) Write(data []byte) (int, error) {
+	return w.body.Write(data)
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+// resolveEntity xác định loại entity và id từ mẫu route: tài nguyên là đoạn đầu tiên sau
+// /api/ (bỏ qua admin/), id là tham số :id (hoặc :name) của route.
+func resolveEntity(c *gin.Context) (string, string) {
+	route := strings.TrimPrefix(c.FullPath(), "/api/")
+	route = strings.TrimPrefix(route, "admin/")
+	resource := strings.SplitN(route, "/", 2)[0]
+
+	id := c.Param("id")
+	if id == "" {
+		id = c.Param("name")
+	}
+	return resource, id
+}
+
+// action suy ra hành động từ method và việc route có id hay không
+func action(method, id string) string {
+	switch {
+	case method == http.MethodDelete:
+		return models.AuditActionDelete
+	case method == http.MethodPost && id == "":
+		return models.AuditActionCreate
+	default:
+		return models.AuditActionUpdate
+	}
+}
+
+// Handle chạy các handler còn lại của request và ghi audit log nếu request POST, PUT, PATCH
+// hoặc DELETE thành công. Được gọi bởi middleware.RequirePermission sau khi kiểm tra quyền,
+// nên mọi route cần quyền quản trị đều được audit, bất kể đường dẫn; middleware.AuthMiddleware
+// cũng gọi Handle cho mọi request của phiên giả danh để các thao tác đó mang ImpersonatorID.
+// Trạng thái entity được đọc trước và sau khi handler chạy để lưu các trường thay đổi;
+// với thao tác tạo mới, id được lấy từ response. Khi audit log được ghi, transaction của handler
+// đã commit: nếu không ghi được, lỗi chỉ được log (kèm nội dung bản ghi) và client vẫn nhận kết
+// quả thật, tránh client thử lại và lặp lại một thay đổi đã thành công.
+func Handle(c *gin.Context) {
+	if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead ||
+		c.Request.Method == http.MethodOptions || c.GetBool(contextKey) {
+		c.Next()
+		return
+	}
+	c.Set(contextKey, true)
+
+	resource, id := resolveEntity(c)
+	loader := loaders[resource]
+
+	var before map[string]interface{}
+	if loader != nil && id != "" {
+		if entity, err := loader(config.DB, id); err == nil {
+			before = snapshot(entity)
+		}
+	}
+
+	original := c.Writer
+	writer := &bufferedWriter{ResponseWriter: original}
+	c.Writer = writer
+	c.Next()
+	c.Writer = original
+
+	status := writer.Status()
+	if status < 200 || status >= 300 {
+		original.Write(writer.body.Bytes())
+		return
+	}
+
+	// Thao tác tạo mới: đọc id từ response JSON
+	if id == "" && writer.body.Len() <= maxCapturedBody {
+		var created struct {
+			ID   interface{} `json:"id"`
+			Name string      `json:"name"`
+		}
+		if err := json.Unmarshal(writer.body.Bytes(), &created); err == nil {
+			if value, ok := created.ID.(string); ok {
+				id = value
+			} else if resource == "roles" {
+				id = created.Name
+			}
+		}
+	}
+
+	var after map[string]interface{}
+	if loader != nil && id != "" {
+		if entity, err := loader(config.DB, id); err == nil {
+			after = snapshot(entity)
+		}
+	}
+
+	entry := models.AuditLog{
+		ID:         uuid.New(),
+		ActorRole:  c.GetString("role"),
+		IPAddress:  c.ClientIP(),
+		Method:     c.Request.Method,
+		Route:      c.FullPath(),
+		Path:       c.Request.URL.Path,
+		StatusCode: status,
+		Action:     action(c.Request.Method, c.Param("id")+c.Param("name")),
+		EntityType: resource,
+		EntityID:   id,
+		Changes:    diff(before, after),
+	}
+	if actorID, ok := c.Get("userID"); ok {
+		if value, ok := actorID.(uuid.UUID); ok {
+			entry.ActorID = &value
+		}
+	}
+	if impersonatorID, ok := c.Get("impersonatorID"); ok {
+		if value, ok := impersonatorID.(uuid.UUID); ok {
+			entry.ImpersonatorID = &value
+		}
+	}
+
+	if err := config.DB.Create(&entry).Error; err != nil {
+		record, _ := json.Marshal(entry)
+		log.Printf("audit: failed to write audit log, change was applied: %v: %s", err, record)
+	}
+	original.Write(writer.body.Bytes())
+}
@@ -0,0 +1,101 @@
+package bruteforce
+
+import (
+	"sync/atomic"
+	"time"
+
+	"ecommerce-project/models"
+
+	"gorm.io/gorm"
+)
+
+// DBStore là AttemptStore lưu bộ đếm trong bảng login_attempts (Postgres), dùng chung giữa các instance.
+// Increment dùng INSERT ... ON CONFLICT DO UPDATE ... RETURNING nên không có bước đọc-sửa-ghi.
+type DBStore struct {
+	db         *gorm.DB
+	increments atomic.Int64
+}
+
+// NewDBStore khởi tạo DBStore trên db (bảng login_attempts cần được migrate trước)
+func NewDBStore(db *gorm.DB) *DBStore {
+	return &DBStore{db: db}
+}
+
+func (s *DBStore) Get(key string) (Attempts, error) {
+	var record models.LoginAttempt
+	err := s.db.Where(`"key" = ? AND expires_at > ?`, key, time.Now()).Limit(1).Find(&record).Error
+	if err != nil || record.Key == "" {
+		return Attempts{}, err
+	}
+	return toAttempts(record), nil
+}
+
+// incrementSQL tăng bộ đếm (tính lại từ 1 nếu bản ghi đã hết hạn) và gia hạn bản ghi thêm Window
+// (không ngắn hơn thời điểm hết khoá).
+const incrementSQL = `
+INSERT INTO login_attempts ("key", failures, last_failure, locked_until, expires_at)
+VALUES (@key, 1, @now, NULL, @expires)
+ON CONFLICT ("key") DO UPDATE SET
+	failures = CASE WHEN login_attempts.expires_at <= @now THEN 1 ELSE login_attempts.failures + 1 END,
+	locked_until = CASE WHEN login_attempts.expires_at <= @now THEN NULL ELSE login_attempts.locked_until END,
+	last_failure = @now,
+	expires_at = GREATEST(@expires, COALESCE(login_attempts.locked_until, @expires))
+RETURNING "key", failures, last_failure, locked_until, expires_at`
+
+// lockSQL khoá key khi đạt ngưỡng và chưa bị khoá. Dòng đã bị khoá bởi câu lệnh đồng thời khác
+// không còn thoả điều kiện, nên chỉ một request nhận được kết quả "bị khoá".
+const lockSQL = `
+UPDATE login_attempts SET
+	failures = 0,
+	locked_until = @lockedUntil,
+	expires_at = GREATEST(expires_at, @lockedUntil)
+WHERE "key" = @key AND failures >= @threshold AND (locked_until IS NULL OR locked_until <= @now)
+RETURNING "key", failures, last_failure, locked_until, expires_at`
+
+func (s *DBStore) Increment(key string, now time.Time, policy Policy) (Attempts, bool, error) {
+	var record models.LoginAttempt
+	if err := s.db.Raw(incrementSQL, map[string]interface{}{
+		"key":     key,
+		"now":     now,
+		"expires": now.Add(policy.Window),
+	}).Scan(&record).Error; err != nil {
+		return Attempts{}, false, err
+	}
+
+	lockedOut := false
+	if policy.LockoutThreshold > 0 && record.Failures >= policy.LockoutThreshold {
+		var locked []models.LoginAttempt
+		if err := s.db.Raw(lockSQL, map[string]interface{}{
+			"key":         key,
+			"now":         now,
+			"threshold":   policy.LockoutThreshold,
+			"lockedUntil": now.Add(policy.LockoutDuration),
+		}).Scan(&locked).Error; err != nil {
+			return Attempts{}, false, err
+		}
+		if len(locked) > 0 {
+			record = locked[0]
+			lockedOut = true
+		}
+	}
+
+	// Dọn các bản ghi hết hạn để bảng không tăng mãi
+	if s.increments.Add(1)%1000 == 0 {
+		if err := s.db.Where("expires_at < ?", now).Delete(&models.LoginAttempt{}).Error; err != nil {
+			return toAttempts(record), lockedOut, err
+		}
+	}
+	return toAttempts(record), lockedOut, nil
+}
+
+func (s *DBStore) Delete(key string) error {
+	return s.db.Where(`"key" = ?`, key).Delete(&models.LoginAttempt{}).Error
+}
+
+func toAttempts(record models.LoginAttempt) Attempts {
+	attempts := Attempts{Failures: record.Failures, LastFailure: record.LastFailure}
+	if record.LockedUntil != nil {
+		attempts.LockedUntil = *record.LockedUntil
+	}
+	return attempts
+}
@@ -0,0 +1,79 @@
+package bruteforce
+
+import (
+	"time"
+)
+
+// Policy cấu hình cách tính thời gian chờ và khoá tạm thời cho một loại khoá
+type Policy struct {
+	// FreeAttempts là số lần sai được phép trước khi bắt đầu phải chờ
+	FreeAttempts int
+	// BaseDelay là thời gian chờ sau lần sai đầu tiên vượt FreeAttempts, nhân đôi sau mỗi lần sai tiếp theo
+	BaseDelay time.Duration
+	// MaxDelay là thời gian chờ tối đa giữa hai lần thử
+	MaxDelay time.Duration
+	// LockoutThreshold là số lần sai liên tiếp dẫn tới khoá tạm thời
+	LockoutThreshold int
+	// LockoutDuration là thời gian khoá tạm thời
+	LockoutDuration time.Duration
+	// Window là thời gian giữ bộ đếm kể từ lần sai cuối cùng
+	Window time.Duration
+}
+
+// Guard theo dõi các lần đăng nhập thất bại và quyết định khi nào phải từ chối thử lại
+type Guard struct {
+	store AttemptStore
+	now   func() time.Time
+}
+
+// NewGuard khởi tạo Guard dùng store để lưu bộ đếm
+func NewGuard(store AttemptStore) *Guard {
+	return &Guard{store: store, now: time.Now}
+}
+
+// RetryAfter trả về thời gian còn phải chờ trước khi key được thử tiếp (0 nếu được phép)
+func (g *Guard) RetryAfter(key string, policy Policy) (time.Duration, error) {
+	attempts, err := g.store.Get(key)
+	if err != nil {
+		return 0, err
+	}
+
+	now := g.now()
+	if now.Before(attempts.LockedUntil) {
+		return attempts.LockedUntil.Sub(now), nil
+	}
+
+	next := attempts.LastFailure.Add(policy.delay(attempts.Failures))
+	if now.Before(next) {
+		return next.Sub(now), nil
+	}
+	return 0, nil
+}
+
+// RecordFailure ghi nhận một lần thất bại. Trả về true nếu lần thất bại này khiến key bị khoá tạm thời.
+// Bộ đếm được tăng nguyên tử trong store nên các request đồng thời không làm mất lần thất bại nào.
+func (g *Guard) RecordFailure(key string, policy Policy) (bool, error) {
+	_, lockedOut, err := g.store.Increment(key, g.now(), policy)
+	return lockedOut, err
+}
+
+// Reset xoá bộ đếm của key (đăng nhập thành công hoặc admin mở khoá)
+func (g *Guard) Reset(key string) error {
+	return g.store.Delete(key)
+}
+
+// delay tính thời gian chờ sau failures lần sai: BaseDelay * 2^(failures-FreeAttempts-1), tối đa MaxDelay
+func (p Policy) delay(failures int) time.Duration {
+	if failures <= p.FreeAttempts {
+		return 0
+	}
+
+	wait := p.BaseDelay
+	for i := p.FreeAttempts + 1; i < failures; i++ {
+		wait *= 2
+		if wait >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+	return wait
+}
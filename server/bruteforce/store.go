@@ -0,0 +1,114 @@
+package bruteforce
+
+import (
+	"sync"
+	"time"
+)
+
+// Attempts là trạng thái đăng nhập thất bại của một khoá (tài khoản hoặc IP)
+type Attempts struct {
+	Failures    int
+	LastFailure time.Time
+	LockedUntil time.Time
+}
+
+// AttemptStore lưu bộ đếm đăng nhập thất bại. MemoryStore dùng cho một node và khi test,
+// triển khai nhiều node dùng DBStore (hoặc một store dùng chung khác) theo cùng interface.
+type AttemptStore interface {
+	// Get trả về trạng thái hiện tại của key (giá trị rỗng nếu chưa có)
+	Get(key string) (Attempts, error)
+	// Increment ghi nhận một lần thất bại tại thời điểm now một cách nguyên tử: tăng Failures
+	// (bộ đếm đã quá Window được tính lại từ đầu) và khoá key theo policy khi đạt LockoutThreshold.
+	// Trả về trạng thái sau khi tăng và true nếu chính lần tăng này khiến key bị khoá.
+	Increment(key string, now time.Time, policy Policy) (Attempts, bool, error)
+	// Delete xoá trạng thái của key
+	Delete(key string) error
+}
+
+// applyFailure cập nhật attempts cho một lần thất bại tại now, trả về true nếu key bị khoá
+func applyFailure(attempts *Attempts, now time.Time, policy Policy) bool {
+	attempts.Failures++
+	attempts.LastFailure = now
+
+	if policy.LockoutThreshold > 0 && attempts.Failures >= policy.LockoutThreshold && !now.Before(attempts.LockedUntil) {
+		attempts.LockedUntil = now.Add(policy.LockoutDuration)
+		// Sau khi hết khoá, người dùng có lại số lần thử như ban đầu
+		attempts.Failures = 0
+		return true
+	}
+	return false
+}
+
+// retention là thời gian giữ bản ghi sau lần thất bại: Window, hoặc tới khi hết khoá nếu lâu hơn
+func retention(attempts Attempts, now time.Time, policy Policy) time.Duration {
+	ttl := policy.Window
+	if until := attempts.LockedUntil.Sub(now); until > ttl {
+		ttl = until
+	}
+	return ttl
+}
+
+type memoryEntry struct {
+	attempts  Attempts
+	expiresAt time.Time
+}
+
+// MemoryStore là AttemptStore lưu trong bộ nhớ của process
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+	now     func() time.Time
+}
+
+// NewMemoryStore khởi tạo MemoryStore rỗng
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		entries: map[string]memoryEntry{},
+		now:     time.Now,
+	}
+}
+
+func (s *MemoryStore) Get(key string) (Attempts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[key]
+	if !ok {
+		return Attempts{}, nil
+	}
+	if s.now().After(entry.expiresAt) {
+		delete(s.entries, key)
+		return Attempts{}, nil
+	}
+	return entry.attempts, nil
+}
+
+func (s *MemoryStore) Increment(key string, now time.Time, policy Policy) (Attempts, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var attempts Attempts
+	if entry, ok := s.entries[key]; ok && !now.After(entry.expiresAt) {
+		attempts = entry.attempts
+	}
+	lockedOut := applyFailure(&attempts, now, policy)
+	s.entries[key] = memoryEntry{attempts: attempts, expiresAt: now.Add(retention(attempts, now, policy))}
+
+	// Dọn các bản ghi hết hạn để map không tăng mãi
+	if len(s.entries)%1000 == 0 {
+		for k, entry := range s.entries {
+			if now.After(entry.expiresAt) {
+				delete(s.entries, k)
+			}
+		}
+	}
+	return attempts, lockedOut, nil
+}
+
+func (s *MemoryStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+	return nil
+}
@@ -0,0 +1,225 @@
+package bruteforce
+
+import (
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"ecommerce-project/models"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+var testPolicy = Policy{
+	FreeAttempts:     2,
+	BaseDelay:        time.Second,
+	MaxDelay:         time.Minute,
+	LockoutThreshold: 5,
+	LockoutDuration:  10 * time.Minute,
+	Window:           time.Hour,
+}
+
+// testStore chạy các kiểm tra chung cho mọi AttemptStore. Mỗi lần gọi dùng key riêng.
+func testStore(t *testing.T, store AttemptStore) {
+	t.Run("increment counts failures", func(t *testing.T) {
+		key := "test:" + uuid.NewString()
+		now := time.Now()
+		for i := 1; i <= 3; i++ {
+			attempts, lockedOut, err := store.Increment(key, now, testPolicy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if attempts.Failures != i || lockedOut {
+				t.Fatalf("failure %d: got failures=%d lockedOut=%v", i, attempts.Failures, lockedOut)
+			}
+		}
+		got, err := store.Get(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Failures != 3 {
+			t.Fatalf("Get: got %d failures, want 3", got.Failures)
+		}
+	})
+
+	t.Run("lockout at threshold", func(t *testing.T) {
+		key := "test:" + uuid.NewString()
+		now := time.Now()
+		for i := 1; i < testPolicy.LockoutThreshold; i++ {
+			if _, lockedOut, err := store.Increment(key, now, testPolicy); err != nil || lockedOut {
+				t.Fatalf("failure %d: lockedOut=%v err=%v", i, lockedOut, err)
+			}
+		}
+		attempts, lockedOut, err := store.Increment(key, now, testPolicy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !lockedOut || attempts.Failures != 0 {
+			t.Fatalf("got lockedOut=%v failures=%d, want locked with counter reset", lockedOut, attempts.Failures)
+		}
+		// Postgres lưu thời gian tới micro giây
+		if want := now.Add(testPolicy.LockoutDuration); attempts.LockedUntil.Sub(want).Abs() > time.Millisecond {
+			t.Fatalf("got LockedUntil=%v, want %v", attempts.LockedUntil, want)
+		}
+	})
+
+	t.Run("expired counter starts over", func(t *testing.T) {
+		key := "test:" + uuid.NewString()
+		now := time.Now()
+		for i := 0; i < 3; i++ {
+			if _, _, err := store.Increment(key, now, testPolicy); err != nil {
+				t.Fatal(err)
+			}
+		}
+		attempts, _, err := store.Increment(key, now.Add(testPolicy.Window+time.Second), testPolicy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if attempts.Failures != 1 {
+			t.Fatalf("got %d failures after window, want 1", attempts.Failures)
+		}
+	})
+
+	t.Run("concurrent increments are not lost", func(t *testing.T) {
+		key := "test:" + uuid.NewString()
+		policy := testPolicy
+		policy.LockoutThreshold = 0
+		const workers = 20
+
+		var wg sync.WaitGroup
+		errs := make(chan error, workers)
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, _, err := store.Increment(key, time.Now(), policy); err != nil {
+					errs <- err
+				}
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			t.Fatal(err)
+		}
+
+		got, err := store.Get(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Failures != workers {
+			t.Fatalf("got %d failures, want %d", got.Failures, workers)
+		}
+	})
+
+	t.Run("concurrent failures lock out once", func(t *testing.T) {
+		key := "test:" + uuid.NewString()
+		const workers = 20
+
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		lockouts := 0
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, lockedOut, err := store.Increment(key, time.Now(), testPolicy)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if lockedOut {
+					mu.Lock()
+					lockouts++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		if lockouts != 1 {
+			t.Fatalf("got %d lockouts, want 1", lockouts)
+		}
+	})
+
+	t.Run("delete resets", func(t *testing.T) {
+		key := "test:" + uuid.NewString()
+		if _, _, err := store.Increment(key, time.Now(), testPolicy); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Delete(key); err != nil {
+			t.Fatal(err)
+		}
+		got, err := store.Get(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Failures != 0 {
+			t.Fatalf("got %d failures after delete, want 0", got.Failures)
+		}
+	})
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+// TestDBStore chạy cùng bộ kiểm tra trên Postgres khi có BRUTEFORCE_TEST_DATABASE_DSN
+func TestDBStore(t *testing.T) {
+	dsn := os.Getenv("BRUTEFORCE_TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("BRUTEFORCE_TEST_DATABASE_DSN is not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.LoginAttempt{}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Where(`"key" LIKE ?`, "test:%").Delete(&models.LoginAttempt{})
+	})
+	testStore(t, NewDBStore(db))
+}
+
+func TestGuardDelays(t *testing.T) {
+	now := time.Now()
+	guard := NewGuard(NewMemoryStore())
+	guard.now = func() time.Time { return now }
+
+	for i := 0; i < testPolicy.FreeAttempts; i++ {
+		if _, err := guard.RecordFailure("k", testPolicy); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if wait, _ := guard.RetryAfter("k", testPolicy); wait != 0 {
+		t.Fatalf("got wait %v within free attempts, want 0", wait)
+	}
+
+	guard.RecordFailure("k", testPolicy)
+	if wait, _ := guard.RetryAfter("k", testPolicy); wait != testPolicy.BaseDelay {
+		t.Fatalf("got wait %v, want %v", wait, testPolicy.BaseDelay)
+	}
+	guard.RecordFailure("k", testPolicy)
+	if wait, _ := guard.RetryAfter("k", testPolicy); wait != 2*testPolicy.BaseDelay {
+		t.Fatalf("got wait %v, want %v", wait, 2*testPolicy.BaseDelay)
+	}
+
+	locked, err := guard.RecordFailure("k", testPolicy)
+	if err != nil || !locked {
+		t.Fatalf("got locked=%v err=%v at threshold, want locked", locked, err)
+	}
+	if wait, _ := guard.RetryAfter("k", testPolicy); wait != testPolicy.LockoutDuration {
+		t.Fatalf("got wait %v while locked, want %v", wait, testPolicy.LockoutDuration)
+	}
+
+	if err := guard.Reset("k"); err != nil {
+		t.Fatal(err)
+	}
+	if wait, _ := guard.RetryAfter("k", testPolicy); wait != 0 {
+		t.Fatalf("got wait %v after reset, want 0", wait)
+	}
+}
@@ -0,0 +1,69 @@
+// Lệnh storagecheck chạy bộ kiểm thử hợp đồng storagetest với một backend lưu trữ.
+//
+//	go run ./cmd/storagecheck -backend s3     # dùng cấu hình S3_* (ví dụ MinIO)
+//	go run ./cmd/storagecheck -backend local  # thư mục tạm, phục vụ qua server HTTP cục bộ
+//
+// Mặc định dùng backend trong STORAGE_BACKEND. Lệnh thoát với mã 1 nếu có kiểm thử không đạt.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+
+	"ecommerce-project/config"
+	"ecommerce-project/storagetest"
+
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	// File .env là tuỳ chọn: cấu hình có thể được truyền qua biến môi trường
+	_ = godotenv.Load()
+	backend := flag.String("backend", config.StorageBackend(), "storage backend: supabase, local or s3")
+	flag.Parse()
+
+	stor, err := newStorage(*backend)
+	if err != nil {
+		log.Fatal("Failed to create storage:", err)
+	}
+
+	results, err := storagetest.Run(stor, storagetest.Options{})
+	for _, result := range results {
+		if result.Err != nil {
+			fmt.Printf("FAIL %s: %v\n", result.Name, result.Err)
+			continue
+		}
+		fmt.Printf("ok   %s\n", result.Name)
+	}
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	fmt.Printf("storage backend %q passed\n", *backend)
+}
+
+// newStorage tạo backend cần kiểm thử. Backend local dùng thư mục tạm và server HTTP
+// cục bộ để bộ kiểm thử tải được file qua URL public.
+func newStorage(backend string) (config.Storage, error) {
+	if backend != config.StorageBackendLocal {
+		return config.NewStorage(backend)
+	}
+
+	dir, err := os.MkdirTemp("", "storagecheck-")
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+
+	stor, err := config.NewLocalStorage(dir, server.URL, "/files")
+	if err != nil {
+		return nil, err
+	}
+	mux.Handle(stor.URLPath()+"/", stor.Handler())
+	return stor, nil
+}
@@ -4,6 +4,9 @@ import (
 	"ecommerce-project/models"
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 	"gorm.io/driver/postgres"
@@ -22,16 +25,177 @@ func GetEnv(key string) string {
 	return os.Getenv(key)
 }
 
+// GetEnvDefault trả về giá trị biến môi trường, hoặc fallback nếu biến không được thiết lập
+func GetEnvDefault(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
+	return fallback
+}
+
+// GetEnvInt đọc biến môi trường kiểu số nguyên, trả về fallback nếu không có hoặc sai định dạng
+func GetEnvInt(key string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// GetEnvDuration đọc biến môi trường dạng time.Duration (ví dụ "30s", "15m"),
+// trả về fallback nếu không có hoặc sai định dạng
+func GetEnvDuration(key string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// RequireAdminTwoFactor cho biết chính sách bắt buộc admin phải đăng nhập bằng 2FA
+// mới được truy cập các route dành riêng cho admin (biến môi trường REQUIRE_ADMIN_2FA)
+func RequireAdminTwoFactor() bool {
+	required, _ := strconv.ParseBool(os.Getenv("REQUIRE_ADMIN_2FA"))
+	return required
+}
+
+// SecureCookies cho biết cookie do server đặt có cờ Secure (chỉ gửi qua HTTPS), đọc từ COOKIE_SECURE.
+// Mặc định bật; đặt COOKIE_SECURE=false khi phát triển không có HTTPS.
+func SecureCookies() bool {
+	secure, err := strconv.ParseBool(GetEnvDefault("COOKIE_SECURE", "true"))
+	if err != nil {
+		return true
+	}
+	return secure
+}
+
+// TrustedProxies là danh sách IP/CIDR của reverse proxy được tin cậy, đọc từ TRUSTED_PROXIES
+// (cách nhau bởi dấu phẩy). Mặc định rỗng: X-Forwarded-For bị bỏ qua và IP client là địa chỉ kết nối.
+func TrustedProxies() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
+
 func InitDatabase() {
 	dsn := os.Getenv("DATABASE_DSN")
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+		// Lỗi vi phạm unique index được trả về dưới dạng gorm.ErrDuplicatedKey
+		TranslateError: true,
+	})
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 	DB = db
 
-	if err := DB.AutoMigrate(&models.User{}, &models.RefreshToken{}, &models.Product{}, &models.ProductVariant{}, &models.Cart{}, &models.CartItem{}, &models.Category{}); err != nil {
+	if err := DB.AutoMigrate(&models.SchemaMigration{}); err != nil {
+		log.Fatal("Migration failed:", err)
+	}
+
+	// Phiên bản cũ lưu refresh token gốc ở cột "token", nay chỉ lưu hash ở cột "token_hash".
+	// Xoá các token cũ và cột cũ trước khi AutoMigrate thêm cột mới; người dùng cần đăng nhập lại.
+	// Migration chỉ chạy một lần, các lần khởi động sau không đụng tới refresh_tokens.
+	if err := runMigrationOnce(DB, "refresh_tokens_hash_only", func(tx *gorm.DB) error {
+		if !tx.Migrator().HasColumn(&models.RefreshToken{}, "token") {
+			return nil
+		}
+		if err := tx.Exec("DELETE FROM refresh_tokens").Error; err != nil {
+			return err
+		}
+		return tx.Migrator().DropColumn(&models.RefreshToken{}, "token")
+	}); err != nil {
+		log.Fatal("Migration failed:", err)
+	}
+
+	// Checksum của media trước đây chỉ có index thường nên upload đồng thời có thể tạo media trùng nội dung.
+	// Trước khi AutoMigrate tạo unique index, ảnh sản phẩm và lượt upload được chuyển sang media cũ nhất
+	// cùng checksum rồi các media trùng bị xoá (file của chúng vẫn nằm trong storage).
+	if err := runMigrationOnce(DB, "media_unique_checksum", func(tx *gorm.DB) error {
+		if !tx.Migrator().HasTable(&models.Media{}) {
+			return nil
+		}
+		if err := tx.Exec(mediaDuplicatesSQL).Error; err != nil {
+			return err
+		}
+		for _, table := range []string{"product_images", "media_uploads"} {
+			if !tx.Migrator().HasTable(table) {
+				continue
+			}
+			if err := tx.Exec("UPDATE " + table + " SET media_id = d.keep_id FROM media_duplicates d WHERE " + table + ".media_id = d.id").Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Exec("DELETE FROM media WHERE id IN (SELECT id FROM media_duplicates)").Error; err != nil {
+			return err
+		}
+		return tx.Exec("DROP INDEX IF EXISTS idx_media_checksum").Error
+	}); err != nil {
+		log.Fatal("Migration failed:", err)
+	}
+
+	// Trước khi AutoMigrate tạo unique index cho đăng ký đang chờ thông báo, các đăng ký trùng
+	// (do request đồng thời) được gộp lại, giữ đăng ký cũ nhất
+	if err := runMigrationOnce(DB, "stock_subscriptions_unique_pending", func(tx *gorm.DB) error {
+		if !tx.Migrator().HasTable(&models.StockSubscription{}) {
+			return nil
+		}
+		return tx.Exec(`DELETE FROM stock_subscriptions s USING stock_subscriptions k
+			WHERE s.notified_at IS NULL AND k.notified_at IS NULL
+			AND s.user_id = k.user_id AND s.variant_id = k.variant_id
+			AND (k.created_at, k.id) < (s.created_at, s.id)`).Error
+	}); err != nil {
+		log.Fatal("Migration failed:", err)
+	}
+
+	if err := DB.AutoMigrate(&models.User{}, &models.RefreshToken{}, &models.Product{}, &models.ProductVariant{}, &models.Cart{}, &models.CartItem{}, &models.Category{}, &models.Review{}, &models.WishlistItem{}, &models.StockSubscription{}, &models.OutboxMessage{}, &models.UserToken{}, &models.SigningKey{}, &models.RecoveryCode{}, &models.SecurityEvent{}, &models.UserIdentity{}, &models.OAuthState{}, &models.Role{}, &models.UserRole{}, &models.AuditLog{}, &models.ProductRevision{}, &models.ProductInteraction{}, &models.ProductRecommendation{}, &models.ProductCompatibility{}, &models.Media{}, &models.ProductImage{}, &models.MediaUpload{}, &models.Order{}, &models.OrderLine{}, &models.StockMovement{}, &models.ReturnRequest{}, &models.ReturnLine{}, &models.ReturnStatusChange{}, &models.Refund{}, &models.LoginAttempt{}); err != nil {
+		log.Fatal("Migration failed:", err)
+	}
+
+	// Phiên bản cũ dùng cột boolean "public", nay thay bằng vòng đời status (cột mới mặc định draft,
+	// cùng mặc định với CreateProduct). Sản phẩm đang hiển thị được chuyển thành published.
+	if DB.Migrator().HasColumn(&models.Product{}, "public") {
+		if err := DB.Exec("UPDATE products SET status = CASE WHEN public THEN ? ELSE ? END",
+			models.ProductStatusPublished, models.ProductStatusDraft).Error; err != nil {
+			log.Fatal("Migration failed:", err)
+		}
+		if err := DB.Migrator().DropColumn(&models.Product{}, "public"); err != nil {
+			log.Fatal("Migration failed:", err)
+		}
+	}
+
+	// Audit log chỉ cho phép thêm mới: trigger từ chối mọi UPDATE, DELETE và TRUNCATE
+	if err := DB.Exec(auditLogAppendOnlySQL).Error; err != nil {
 		log.Fatal("Migration failed:", err)
 	}
 	log.Println("Migration completed successfully!")
-}
\ No newline at end of file
+}
+
+// mediaDuplicatesSQL ghi các media trùng checksum cùng media cũ nhất được giữ lại (keep_id)
+// vào bảng tạm, bảng bị xoá khi transaction của migration kết thúc
+const mediaDuplicatesSQL = `
+CREATE TEMPORARY TABLE media_duplicates ON COMMIT DROP AS
+	SELECT id, keep_id FROM (
+		SELECT id, first_value(id) OVER (PARTITION BY checksum ORDER BY created_at, id) AS keep_id FROM media
+	) ranked
+	WHERE id <> keep_id
+`
+
+const auditLogAppendOnlySQL = `
+CREATE OR REPLACE FUNCTION audit_logs_append_only() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'audit_logs is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_logs_no_modify ON audit_logs;
+CREATE TRIGGER audit_logs_no_modify BEFORE UPDATE OR DELETE ON audit_logs
+	FOR EACH ROW EXECUTE FUNCTION audit_logs_append_only();
+
+DROP TRIGGER IF EXISTS audit_logs_no_truncate ON audit_logs;
+CREATE TRIGGER audit_logs_no_truncate BEFORE TRUNCATE ON audit_logs
+	FOR EACH STATEMENT EXECUTE FUNCTION audit_logs_append_only();
+`
\ No newline at end of file
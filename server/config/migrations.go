@@ -0,0 +1,26 @@
+package config
+
+import (
+	"time"
+
+	"ecommerce-project/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// runMigrationOnce chạy migrate trong một transaction và ghi tên migration vào schema_migrations.
+// Migration đã được ghi nhận sẽ không chạy lại; khi nhiều instance khởi động cùng lúc, instance đến sau
+// chờ dòng của instance đầu tiên được commit rồi bỏ qua.
+func runMigrationOnce(db *gorm.DB, name string, migrate func(tx *gorm.DB) error) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.SchemaMigration{
+			Name:      name,
+			AppliedAt: time.Now(),
+		})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		return migrate(tx)
+	})
+}
@@ -2,6 +2,8 @@ package config
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -9,7 +11,9 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/supabase-community/supabase-go"
@@ -18,6 +22,11 @@ import (
 var supabaseClient *supabase.Client
 
 func InitStorageClient() {
+    // Supabase client chỉ cần khi dùng backend Supabase
+    if StorageBackend() != StorageBackendSupabase {
+        return
+    }
+
     supabaseUrl := os.Getenv("SUPABASE_URL")
     supabaseKey := os.Getenv("SUPABASE_KEY")
 
@@ -30,9 +39,104 @@ func InitStorageClient() {
 	supabaseClient = client
 }
 
+// Storage lưu file và trả về URL public để client tải về. Mọi backend phải vượt qua
+// bộ kiểm thử trong package storagetest.
 type Storage interface {
     UploadFile(file *multipart.FileHeader) (string, error)
+    // UploadBytes lưu nội dung vào đường dẫn key (có thể chứa "/") và trả về URL public
+    UploadBytes(key string, content []byte, contentType string) (string, error)
+    // UploadStream giống UploadBytes nhưng đọc nội dung (đúng size byte) từ content theo dạng stream
+    UploadStream(key string, content io.Reader, size int64, contentType string) (string, error)
     DeleteFile(filename string) error
+    // PresignUpload tạo URL có hạn dùng để client upload trực tiếp nội dung (đúng size byte) của key lên
+    // storage. Backend local và S3 từ chối nội dung có dung lượng khác size; Supabase không giới hạn được
+    // nên bên gọi vẫn phải kiểm tra lại dung lượng của file đã upload.
+    PresignUpload(key, contentType string, size int64, expires time.Duration) (PresignedUpload, error)
+    // CopyFile sao chép nội dung của key from sang key to; trả về lỗi bọc ErrObjectNotFound nếu from không tồn tại
+    CopyFile(from, to string) error
+    // OpenFile đọc nội dung của key; trả về lỗi bọc ErrObjectNotFound nếu key không tồn tại
+    OpenFile(key string) (io.ReadCloser, error)
+    // FileURL là URL public của key
+    FileURL(key string) string
+}
+
+// PresignedUpload mô tả request mà client cần gửi để upload trực tiếp lên storage
+type PresignedUpload struct {
+    Method    string            `json:"method"`
+    URL       string            `json:"url"`
+    Headers   map[string]string `json:"headers,omitempty"`
+    ExpiresAt time.Time         `json:"expires_at"`
+}
+
+// ErrObjectNotFound được trả về khi file không tồn tại trong storage
+var ErrObjectNotFound = errors.New("object not found")
+
+// Các backend lưu trữ, chọn bằng biến môi trường STORAGE_BACKEND
+const (
+    StorageBackendSupabase = "supabase"
+    StorageBackendLocal    = "local"
+    StorageBackendS3       = "s3"
+)
+
+var errInvalidObjectKey = errors.New("invalid object key")
+
+// StorageBackend trả về backend lưu trữ được cấu hình, mặc định là Supabase
+func StorageBackend() string {
+    return strings.ToLower(GetEnvDefault("STORAGE_BACKEND", StorageBackendSupabase))
+}
+
+// NewStorage khởi tạo backend lưu trữ theo tên với cấu hình từ biến môi trường
+func NewStorage(backend string) (Storage, error) {
+    switch backend {
+    case StorageBackendSupabase:
+        return newSupabaseStorage()
+    case StorageBackendLocal:
+        return NewLocalStorage(
+            GetEnvDefault("LOCAL_STORAGE_DIR", "uploads"),
+            GetEnvDefault("LOCAL_STORAGE_PUBLIC_URL", "http://localhost:8080"),
+            GetEnvDefault("LOCAL_STORAGE_URL_PATH", "/files"),
+        )
+    case StorageBackendS3:
+        return NewS3Storage(S3ConfigFromEnv())
+    default:
+        return nil, fmt.Errorf("unknown storage backend %q", backend)
+    }
+}
+
+// validateObjectKey từ chối key rỗng, key tuyệt đối hoặc chứa "." / ".." để file không thể
+// bị ghi ra ngoài thư mục (bucket) lưu trữ
+func validateObjectKey(key string) error {
+    if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+        return fmt.Errorf("%w: %q", errInvalidObjectKey, key)
+    }
+    for _, segment := range strings.Split(key, "/") {
+        if segment == "" || segment == "." || segment == ".." {
+            return fmt.Errorf("%w: %q", errInvalidObjectKey, key)
+        }
+    }
+    return nil
+}
+
+// escapeObjectKey mã hoá key để dùng trong URL theo quy tắc URI encoding của S3:
+// chỉ giữ nguyên chữ, số, "-", ".", "_", "~" và dấu "/" phân cách
+func escapeObjectKey(key string) string {
+    var b strings.Builder
+    for i := 0; i < len(key); i++ {
+        ch := key[i]
+        if ch >= 'A' && ch <= 'Z' || ch >= 'a' && ch <= 'z' || ch >= '0' && ch <= '9' ||
+            ch == '-' || ch == '.' || ch == '_' || ch == '~' || ch == '/' {
+            b.WriteByte(ch)
+            continue
+        }
+        fmt.Fprintf(&b, "%%%02X", ch)
+    }
+    return b.String()
+}
+
+// generateFilename tạo tên file ngẫu nhiên, giữ lại đuôi file gốc
+func generateFilename(originalName string) string {
+    ext := filepath.Ext(originalName)
+    return fmt.Sprintf("%s%s", uuid.New().String(), ext)
 }
 
 type SupabaseStorage struct {
@@ -50,7 +154,7 @@ var (
 func GetStorage() (Storage, error) {
     var err error
     once.Do(func() {
-        instance, err = newSupabaseStorage()
+        instance, err = NewStorage(StorageBackend())
     })
     return instance, err
 }
@@ -66,7 +170,7 @@ func newSupabaseStorage() (Storage, error) {
 
 func (s *SupabaseStorage) DeleteFile(filename string) error {
 	// Xây dựng URL cho việc xóa file
-	url := fmt.Sprintf("%s/storage/v1/object/%s/%s", s.url, s.bucket, filename)
+	url := fmt.Sprintf("%s/storage/v1/object/%s/%s", s.url, s.bucket, escapeObjectKey(filename))
 	req, err := http.NewRequest(http.MethodDelete, url, nil)
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
@@ -91,15 +195,17 @@ func (s *SupabaseStorage) DeleteFile(filename string) error {
 }
 
 func (s *SupabaseStorage) UploadFile(file *multipart.FileHeader) (string, error) {
-    fileContent, err := s.readFile(file)
+    // Stream nội dung file lên Supabase, không đọc toàn bộ vào bộ nhớ
+    src, err := file.Open()
     if err != nil {
         return "", fmt.Errorf("failed to read file: %w", err)
     }
+    defer src.Close()
 
-    filename := s.generateFilename(file.Filename)
+    filename := generateFilename(file.Filename)
     fmt.Println(filename)
     
-    if err := s.uploadToSupabase(fileContent, filename, file.Header.Get("Content-Type")); err != nil {
+    if err := s.uploadToSupabase(src, file.Size, filename, file.Header.Get("Content-Type")); err != nil {
         fmt.Println("123123")
         return "", err
     }
@@ -108,23 +214,138 @@ func (s *SupabaseStorage) UploadFile(file *multipart.FileHeader) (string, error)
     return s.generatePublicURL(filename), nil
 }
 
-func (s *SupabaseStorage) readFile(file *multipart.FileHeader) ([]byte, error) {
-    src, err := file.Open()
+func (s *SupabaseStorage) UploadBytes(key string, content []byte, contentType string) (string, error) {
+    if err := validateObjectKey(key); err != nil {
+        return "", err
+    }
+    return s.UploadStream(key, bytes.NewReader(content), int64(len(content)), contentType)
+}
+
+func (s *SupabaseStorage) UploadStream(key string, content io.Reader, size int64, contentType string) (string, error) {
+    if err := validateObjectKey(key); err != nil {
+        return "", err
+    }
+    if err := s.uploadToSupabase(content, size, key, contentType); err != nil {
+        return "", err
+    }
+    return s.generatePublicURL(key), nil
+}
+
+// PresignUpload tạo signed upload URL của Supabase. Supabase tự đặt hạn dùng cho URL (mặc định 2 giờ),
+// ExpiresAt là hạn mà server chấp nhận hoàn tất upload. URL của Supabase không ràng buộc được dung lượng
+// nên size không được dùng ở đây.
+func (s *SupabaseStorage) PresignUpload(key, contentType string, size int64, expires time.Duration) (PresignedUpload, error) {
+    if err := validateObjectKey(key); err != nil {
+        return PresignedUpload{}, err
+    }
+
+    url := fmt.Sprintf("%s/storage/v1/object/upload/sign/%s/%s", s.url, s.bucket, escapeObjectKey(key))
+    req, err := http.NewRequest(http.MethodPost, url, nil)
     if err != nil {
+        return PresignedUpload{}, fmt.Errorf("failed to create request: %w", err)
+    }
+    req.Header.Set("Authorization", "Bearer "+os.Getenv("SUPABASE_SERVICE_KEY"))
+
+    resp, err := s.client.Do(req)
+    if err != nil {
+        return PresignedUpload{}, fmt.Errorf("failed to send request: %w", err)
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+        body, _ := io.ReadAll(resp.Body)
+        return PresignedUpload{}, fmt.Errorf("presign failed with status %d: %s", resp.StatusCode, string(body))
+    }
+
+    // Supabase trả về đường dẫn tương đối so với /storage/v1, kèm token trong query
+    var signed struct {
+        URL string `json:"url"`
+    }
+    if err := json.NewDecoder(resp.Body).Decode(&signed); err != nil || signed.URL == "" {
+        return PresignedUpload{}, fmt.Errorf("invalid presign response: %v", err)
+    }
+
+    if contentType == "" {
+        contentType = "application/octet-stream"
+    }
+    return PresignedUpload{
+        Method:    http.MethodPut,
+        URL:       s.url + "/storage/v1" + signed.URL,
+        Headers:   map[string]string{"Content-Type": contentType},
+        ExpiresAt: time.Now().Add(expires),
+    }, nil
+}
+
+// CopyFile sao chép object trong bucket bằng API copy của Supabase, nội dung không đi qua server
+func (s *SupabaseStorage) CopyFile(from, to string) error {
+    if err := validateObjectKey(from); err != nil {
+        return err
+    }
+    if err := validateObjectKey(to); err != nil {
+        return err
+    }
+
+    payload, err := json.Marshal(map[string]string{"bucketId": s.bucket, "sourceKey": from, "destinationKey": to})
+    if err != nil {
+        return err
+    }
+    req, err := http.NewRequest(http.MethodPost, s.url+"/storage/v1/object/copy", bytes.NewReader(payload))
+    if err != nil {
+        return fmt.Errorf("failed to create request: %w", err)
+    }
+    req.Header.Set("Content-Type", "application/json")
+    req.Header.Set("Authorization", "Bearer "+os.Getenv("SUPABASE_SERVICE_KEY"))
+
+    resp, err := s.client.Do(req)
+    if err != nil {
+        return fmt.Errorf("failed to send request: %w", err)
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+        body, _ := io.ReadAll(resp.Body)
+        if resp.StatusCode == http.StatusNotFound || bytes.Contains(bytes.ToLower(body), []byte("not_found")) {
+            return fmt.Errorf("%w: %s", ErrObjectNotFound, from)
+        }
+        return fmt.Errorf("copy failed with status %d: %s", resp.StatusCode, string(body))
+    }
+    return nil
+}
+
+func (s *SupabaseStorage) OpenFile(key string) (io.ReadCloser, error) {
+    if err := validateObjectKey(key); err != nil {
         return nil, err
     }
-    defer src.Close()
 
-    return io.ReadAll(src)
+    url := fmt.Sprintf("%s/storage/v1/object/%s/%s", s.url, s.bucket, escapeObjectKey(key))
+    req, err := http.NewRequest(http.MethodGet, url, nil)
+    if err != nil {
+        return nil, fmt.Errorf("failed to create request: %w", err)
+    }
+    req.Header.Set("Authorization", "Bearer "+os.Getenv("SUPABASE_SERVICE_KEY"))
+
+    resp, err := s.client.Do(req)
+    if err != nil {
+        return nil, fmt.Errorf("failed to send request: %w", err)
+    }
+    if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+        defer resp.Body.Close()
+        body, _ := io.ReadAll(resp.Body)
+        // Supabase trả về 400 kèm "not_found" khi object không tồn tại
+        if resp.StatusCode == http.StatusNotFound || bytes.Contains(bytes.ToLower(body), []byte("not_found")) {
+            return nil, fmt.Errorf("%w: %s", ErrObjectNotFound, key)
+        }
+        return nil, fmt.Errorf("download failed with status %d: %s", resp.StatusCode, string(body))
+    }
+    return resp.Body, nil
 }
 
-func (s *SupabaseStorage) generateFilename(originalName string) string {
-    ext := filepath.Ext(originalName)
-    return fmt.Sprintf("%s%s", uuid.New().String(), ext)
+func (s *SupabaseStorage) FileURL(key string) string {
+    return s.generatePublicURL(key)
 }
 
-func (s *SupabaseStorage) uploadToSupabase(content []byte, filename, contentType string) error {
-    url := fmt.Sprintf("%s/storage/v1/object/%s/%s", s.url, s.bucket, filename)
+func (s *SupabaseStorage) uploadToSupabase(content io.Reader, size int64, filename, contentType string) error {
+    url := fmt.Sprintf("%s/storage/v1/object/%s/%s", s.url, s.bucket, escapeObjectKey(filename))
 
     // Kiểm tra Content-Type
     if contentType == "" {
@@ -132,11 +353,12 @@ func (s *SupabaseStorage) uploadToSupabase(content []byte, filename, contentType
     }
     
     // Thử dùng PUT thay vì POST
-    req, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(content))
+    req, err := http.NewRequest(http.MethodPut, url, content)
     if err != nil {
         fmt.Println("Error tạo request")
         return fmt.Errorf("failed to create request: %w", err)
     }
+    req.ContentLength = size
 
     req.Header.Set("Content-Type", contentType)
     req.Header.Set("Authorization", "Bearer "+os.Getenv("SUPABASE_SERVICE_KEY"))
@@ -162,7 +384,7 @@ func (s *SupabaseStorage) generatePublicURL(filename string) string {
     return fmt.Sprintf("%s/storage/v1/object/public/%s/%s",
         s.url,
         s.bucket,
-        filename,
+        escapeObjectKey(filename),
     )
 }
 
@@ -0,0 +1,283 @@
+package config
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LocalStorage lưu file trên ổ đĩa của server, dùng cho môi trường phát triển và kiểm thử.
+// File được phục vụ qua HTTP tại urlPath (xem Handler), URL public có dạng publicURL + urlPath + "/" + key.
+type LocalStorage struct {
+	root      string
+	publicURL string
+	urlPath   string
+	// signingKey ký URL upload trực tiếp, được tạo ngẫu nhiên mỗi lần khởi động
+	signingKey []byte
+}
+
+// NewLocalStorage tạo backend lưu file trong thư mục root (tự tạo nếu chưa có)
+func NewLocalStorage(root, publicURL, urlPath string) (*LocalStorage, error) {
+	urlPath = "/" + strings.Trim(urlPath, "/")
+	if urlPath == "/" {
+		return nil, errors.New("local storage url path must not be the site root")
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+	signingKey := make([]byte, 32)
+	if _, err := rand.Read(signingKey); err != nil {
+		return nil, fmt.Errorf("failed to generate signing key: %w", err)
+	}
+	return &LocalStorage{
+		root:       root,
+		publicURL:  strings.TrimRight(publicURL, "/"),
+		urlPath:    urlPath,
+		signingKey: signingKey,
+	}, nil
+}
+
+// LocalFileServer trả về backend cục bộ đang được dùng, để server gắn Handler của nó vào router
+func LocalFileServer() (*LocalStorage, bool) {
+	stor, err := GetStorage()
+	if err != nil {
+		return nil, false
+	}
+	local, ok := stor.(*LocalStorage)
+	return local, ok
+}
+
+// URLPath là đường dẫn HTTP mà Handler cần được gắn vào
+func (s *LocalStorage) URLPath() string {
+	return s.urlPath
+}
+
+// Handler phục vụ các file đã lưu (không liệt kê thư mục) và nhận upload trực tiếp (PUT) qua URL
+// do PresignUpload tạo. Handler cần được gắn vào URLPath.
+func (s *LocalStorage) Handler() http.Handler {
+	files := http.FileServer(localFileSystem{http.Dir(s.root)})
+	return http.StripPrefix(s.urlPath, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			s.handleUpload(w, r)
+			return
+		}
+		// File do người dùng upload không được trình duyệt đoán lại kiểu nội dung
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		files.ServeHTTP(w, r)
+	}))
+}
+
+// handleUpload lưu nội dung request PUT nếu chữ ký và hạn dùng của URL hợp lệ. Dung lượng được ký cùng URL
+// nên nội dung dài hơn bị từ chối.
+func (s *LocalStorage) handleUpload(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/")
+	query := r.URL.Query()
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		http.Error(w, "upload url expired", http.StatusForbidden)
+		return
+	}
+	size, sizeErr := strconv.ParseInt(query.Get("size"), 10, 64)
+	signature, err := hex.DecodeString(query.Get("signature"))
+	if err != nil || sizeErr != nil || !hmac.Equal(signature, s.uploadSignature(key, expires, size)) {
+		http.Error(w, "invalid upload signature", http.StatusForbidden)
+		return
+	}
+	if err := validateObjectKey(key); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if r.ContentLength > size {
+		http.Error(w, "upload is larger than the signed size", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	// Request không khai báo Content-Length (chunked) vẫn bị cắt ở dung lượng đã ký
+	if err := s.write(key, http.MaxBytesReader(w, r.Body, size)); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "upload is larger than the signed size", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// uploadSignature là HMAC-SHA256 của key, thời điểm hết hạn và dung lượng được phép upload
+func (s *LocalStorage) uploadSignature(key string, expires, size int64) []byte {
+	mac := hmac.New(sha256.New, s.signingKey)
+	mac.Write([]byte(key + "\n" + strconv.FormatInt(expires, 10) + "\n" + strconv.FormatInt(size, 10)))
+	return mac.Sum(nil)
+}
+
+// localFileSystem ẩn các thư mục để http.FileServer không trả về danh sách file
+type localFileSystem struct {
+	fs http.FileSystem
+}
+
+func (l localFileSystem) Open(name string) (http.File, error) {
+	file, err := l.fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if info.IsDir() {
+		file.Close()
+		return nil, fs.ErrNotExist
+	}
+	return file, nil
+}
+
+func (s *LocalStorage) UploadFile(file *multipart.FileHeader) (string, error) {
+	src, err := file.Open()
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+	defer src.Close()
+
+	key := generateFilename(file.Filename)
+	if err := s.write(key, src); err != nil {
+		return "", err
+	}
+	return s.objectURL(key), nil
+}
+
+func (s *LocalStorage) UploadBytes(key string, content []byte, contentType string) (string, error) {
+	if err := validateObjectKey(key); err != nil {
+		return "", err
+	}
+	return s.UploadStream(key, bytes.NewReader(content), int64(len(content)), contentType)
+}
+
+func (s *LocalStorage) UploadStream(key string, content io.Reader, size int64, contentType string) (string, error) {
+	if err := validateObjectKey(key); err != nil {
+		return "", err
+	}
+	if err := s.write(key, content); err != nil {
+		return "", err
+	}
+	return s.objectURL(key), nil
+}
+
+// PresignUpload tạo URL PUT tới Handler, được ký bằng HMAC (kèm dung lượng size) và hết hạn sau expires
+func (s *LocalStorage) PresignUpload(key, contentType string, size int64, expires time.Duration) (PresignedUpload, error) {
+	if err := validateObjectKey(key); err != nil {
+		return PresignedUpload{}, err
+	}
+	if size <= 0 {
+		return PresignedUpload{}, fmt.Errorf("invalid upload size %d", size)
+	}
+	expiresAt := time.Now().Add(expires)
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expiresAt.Unix(), 10))
+	query.Set("size", strconv.FormatInt(size, 10))
+	query.Set("signature", hex.EncodeToString(s.uploadSignature(key, expiresAt.Unix(), size)))
+
+	upload := PresignedUpload{
+		Method:    http.MethodPut,
+		URL:       s.objectURL(key) + "?" + query.Encode(),
+		ExpiresAt: expiresAt,
+	}
+	if contentType != "" {
+		upload.Headers = map[string]string{"Content-Type": contentType}
+	}
+	return upload, nil
+}
+
+func (s *LocalStorage) CopyFile(from, to string) error {
+	if err := validateObjectKey(to); err != nil {
+		return err
+	}
+	src, err := s.OpenFile(from)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	return s.write(to, src)
+}
+
+func (s *LocalStorage) OpenFile(key string) (io.ReadCloser, error) {
+	if err := validateObjectKey(key); err != nil {
+		return nil, err
+	}
+	file, err := os.Open(s.filePath(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrObjectNotFound, key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	return file, nil
+}
+
+func (s *LocalStorage) FileURL(key string) string {
+	return s.objectURL(key)
+}
+
+func (s *LocalStorage) DeleteFile(filename string) error {
+	if err := validateObjectKey(filename); err != nil {
+		return err
+	}
+	// Xoá file không tồn tại không phải là lỗi, giống S3
+	if err := os.Remove(s.filePath(filename)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+	return nil
+}
+
+// write ghi nội dung ra file tạm rồi đổi tên, để người đọc không bao giờ thấy file ghi dở
+func (s *LocalStorage) write(key string, content io.Reader) error {
+	target := s.filePath(key)
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	return nil
+}
+
+func (s *LocalStorage) filePath(key string) string {
+	return filepath.Join(s.root, filepath.FromSlash(key))
+}
+
+func (s *LocalStorage) objectURL(key string) string {
+	return s.publicURL + s.urlPath + "/" + escapeObjectKey(key)
+}
@@ -0,0 +1,161 @@
+package config
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// newTestLocalStorage tạo LocalStorage trong thư mục tạm, phục vụ qua server của httptest
+func newTestLocalStorage(t *testing.T) *LocalStorage {
+	t.Helper()
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	stor, err := NewLocalStorage(t.TempDir(), server.URL, "/files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux.Handle(stor.URLPath()+"/", stor.Handler())
+	return stor
+}
+
+// put gửi nội dung tới URL upload; chunked = true để request không khai báo Content-Length
+func put(t *testing.T, target string, content []byte, chunked bool) int {
+	t.Helper()
+	var body io.Reader = bytes.NewReader(content)
+	if chunked {
+		// Reader không có Len nên http.Client gửi theo dạng chunked
+		body = io.MultiReader(body)
+	}
+	req, err := http.NewRequest(http.MethodPut, target, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestLocalPresignedUpload(t *testing.T) {
+	stor := newTestLocalStorage(t)
+	content := []byte("presigned content")
+
+	upload, err := stor.PresignUpload("uploads/ok", "text/plain", int64(len(content)), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := put(t, upload.URL, content, false); status != http.StatusOK {
+		t.Fatalf("got status %d, want 200", status)
+	}
+
+	file, err := stor.OpenFile("uploads/ok")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	got, err := io.ReadAll(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Fatalf("got %q, want %q", got, content)
+	}
+}
+
+func TestLocalPresignedUploadRejects(t *testing.T) {
+	stor := newTestLocalStorage(t)
+	content := []byte("presigned content")
+	size := int64(len(content))
+
+	// presigned ký URL cho key với dung lượng size, mutate sửa URL trước khi upload
+	presigned := func(key string, expires time.Duration, mutate func(query url.Values)) string {
+		upload, err := stor.PresignUpload(key, "text/plain", size, expires)
+		if err != nil {
+			t.Fatal(err)
+		}
+		target, err := url.Parse(upload.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mutate != nil {
+			query := target.Query()
+			mutate(query)
+			target.RawQuery = query.Encode()
+		}
+		return target.String()
+	}
+
+	tests := []struct {
+		name    string
+		url     string
+		content []byte
+		chunked bool
+		want    int
+	}{
+		{
+			name:    "larger than signed size",
+			url:     presigned("uploads/large", time.Minute, nil),
+			content: append(content, '!'),
+			want:    http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:    "chunked body larger than signed size",
+			url:     presigned("uploads/chunked", time.Minute, nil),
+			content: bytes.Repeat(content, 4),
+			chunked: true,
+			want:    http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:    "size changed in url",
+			url:     presigned("uploads/size", time.Minute, func(query url.Values) { query.Set("size", "1048576") }),
+			content: bytes.Repeat(content, 4),
+			want:    http.StatusForbidden,
+		},
+		{
+			name:    "expired",
+			url:     presigned("uploads/expired", -time.Minute, nil),
+			content: content,
+			want:    http.StatusForbidden,
+		},
+		{
+			name: "signature of another key",
+			url: func() string {
+				signed, _ := url.Parse(presigned("uploads/a", time.Minute, nil))
+				signed.Path = stor.URLPath() + "/uploads/b"
+				return signed.String()
+			}(),
+			content: content,
+			want:    http.StatusForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status := put(t, tt.url, tt.content, tt.chunked); status != tt.want {
+				t.Fatalf("got status %d, want %d", status, tt.want)
+			}
+		})
+	}
+
+	for _, key := range []string{"uploads/large", "uploads/chunked", "uploads/size", "uploads/expired", "uploads/b"} {
+		if file, err := stor.OpenFile(key); err == nil {
+			file.Close()
+			t.Errorf("rejected upload %q was stored", key)
+		}
+	}
+}
+
+func TestLocalPresignUploadRequiresSize(t *testing.T) {
+	stor := newTestLocalStorage(t)
+	if _, err := stor.PresignUpload("uploads/empty", "text/plain", 0, time.Minute); err == nil {
+		t.Fatal("presign without a size succeeded")
+	}
+}
@@ -0,0 +1,387 @@
+package config
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Giá trị x-amz-content-sha256 khi nội dung được stream mà không tính trước checksum
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// S3Config là cấu hình kết nối tới dịch vụ tương thích S3 (AWS S3, MinIO, ...)
+type S3Config struct {
+	// Endpoint là địa chỉ dịch vụ, ví dụ "https://s3.ap-southeast-1.amazonaws.com" hoặc "http://localhost:9000"
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	// PathStyle dùng URL dạng endpoint/bucket/key thay vì bucket.endpoint/key (MinIO cần bật)
+	PathStyle bool
+	// PublicURL là tiền tố URL public của object (ví dụ CDN); mặc định là URL của object trên endpoint,
+	// khi đó bucket cần cho phép đọc công khai (MinIO: mc anonymous set download)
+	PublicURL string
+}
+
+// S3ConfigFromEnv đọc cấu hình S3 từ các biến môi trường S3_*
+func S3ConfigFromEnv() S3Config {
+	pathStyle, err := strconv.ParseBool(GetEnv("S3_FORCE_PATH_STYLE"))
+	if err != nil {
+		pathStyle = true
+	}
+	return S3Config{
+		Endpoint:        GetEnv("S3_ENDPOINT"),
+		Region:          GetEnvDefault("S3_REGION", "us-east-1"),
+		Bucket:          GetEnv("S3_BUCKET"),
+		AccessKeyID:     GetEnv("S3_ACCESS_KEY_ID"),
+		SecretAccessKey: GetEnv("S3_SECRET_ACCESS_KEY"),
+		PathStyle:       pathStyle,
+		PublicURL:       GetEnv("S3_PUBLIC_URL"),
+	}
+}
+
+// S3Storage lưu file vào bucket S3, ký request bằng AWS Signature Version 4
+type S3Storage struct {
+	cfg      S3Config
+	endpoint *url.URL
+	client   *http.Client
+	now      func() time.Time
+}
+
+// NewS3Storage kiểm tra cấu hình và tạo backend S3
+func NewS3Storage(cfg S3Config) (*S3Storage, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" || cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+		return nil, errors.New("s3 storage requires endpoint, bucket, access key id and secret access key")
+	}
+	endpoint, err := url.Parse(strings.TrimRight(cfg.Endpoint, "/"))
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid s3 endpoint %q", cfg.Endpoint)
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	cfg.PublicURL = strings.TrimRight(cfg.PublicURL, "/")
+
+	return &S3Storage{
+		cfg:      cfg,
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: 5 * time.Minute},
+		now:      time.Now,
+	}, nil
+}
+
+func (s *S3Storage) UploadFile(file *multipart.FileHeader) (string, error) {
+	src, err := file.Open()
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+	defer src.Close()
+
+	key := generateFilename(file.Filename)
+	// Stream nội dung file lên S3, không đọc toàn bộ vào bộ nhớ
+	if err := s.putObject(key, src, file.Size, unsignedPayload, file.Header.Get("Content-Type")); err != nil {
+		return "", err
+	}
+	return s.publicObjectURL(key), nil
+}
+
+func (s *S3Storage) UploadBytes(key string, content []byte, contentType string) (string, error) {
+	if err := validateObjectKey(key); err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(content)
+	if err := s.putObject(key, bytes.NewReader(content), int64(len(content)), hex.EncodeToString(sum[:]), contentType); err != nil {
+		return "", err
+	}
+	return s.publicObjectURL(key), nil
+}
+
+func (s *S3Storage) UploadStream(key string, content io.Reader, size int64, contentType string) (string, error) {
+	if err := validateObjectKey(key); err != nil {
+		return "", err
+	}
+	if err := s.putObject(key, content, size, unsignedPayload, contentType); err != nil {
+		return "", err
+	}
+	return s.publicObjectURL(key), nil
+}
+
+// PresignUpload tạo URL PUT được ký bằng query string (SigV4), có hạn dùng tối đa 7 ngày theo giới hạn của S3.
+// Header Content-Length được ký nên S3 từ chối nội dung có dung lượng khác size.
+func (s *S3Storage) PresignUpload(key, contentType string, size int64, expires time.Duration) (PresignedUpload, error) {
+	if err := validateObjectKey(key); err != nil {
+		return PresignedUpload{}, err
+	}
+	if expires <= 0 || expires > 7*24*time.Hour {
+		return PresignedUpload{}, fmt.Errorf("invalid presign expiry %s", expires)
+	}
+	if size <= 0 {
+		return PresignedUpload{}, fmt.Errorf("invalid upload size %d", size)
+	}
+
+	now := s.now()
+	signedHeaders := map[string]string{"content-length": strconv.FormatInt(size, 10)}
+	upload := PresignedUpload{
+		Method:    http.MethodPut,
+		URL:       s.presign(http.MethodPut, s.objectURL(key), signedHeaders, expires, now).String(),
+		ExpiresAt: now.Add(expires),
+	}
+	if contentType != "" {
+		upload.Headers = map[string]string{"Content-Type": contentType}
+	}
+	return upload, nil
+}
+
+func (s *S3Storage) OpenFile(key string) (io.ReadCloser, error) {
+	if err := validateObjectKey(key); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodGet, s.objectURL(key).String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	s.sign(req, emptyPayloadHash, s.now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("%w: %s", ErrObjectNotFound, key)
+		}
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("s3 GET failed with status %d: %s", resp.StatusCode, string(body))
+	}
+	return resp.Body, nil
+}
+
+// CopyFile sao chép object trong bucket bằng CopyObject, nội dung không đi qua server
+func (s *S3Storage) CopyFile(from, to string) error {
+	if err := validateObjectKey(from); err != nil {
+		return err
+	}
+	if err := validateObjectKey(to); err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPut, s.objectURL(to).String(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("X-Amz-Copy-Source", "/"+escapeObjectKey(s.cfg.Bucket)+"/"+escapeObjectKey(from))
+	resp, err := s.do(req, emptyPayloadHash)
+	if err != nil && resp != nil && resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w: %s", ErrObjectNotFound, from)
+	}
+	return err
+}
+
+func (s *S3Storage) FileURL(key string) string {
+	return s.publicObjectURL(key)
+}
+
+func (s *S3Storage) DeleteFile(filename string) error {
+	if err := validateObjectKey(filename); err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodDelete, s.objectURL(filename).String(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	_, err = s.do(req, emptyPayloadHash)
+	return err
+}
+
+func (s *S3Storage) putObject(key string, content io.Reader, size int64, payloadHash, contentType string) error {
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	req, err := http.NewRequest(http.MethodPut, s.objectURL(key).String(), content)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", contentType)
+	_, err = s.do(req, payloadHash)
+	return err
+}
+
+// do ký và gửi request, trả về lỗi kèm nội dung phản hồi nếu status không phải 2xx
+func (s *S3Storage) do(req *http.Request, payloadHash string) (*http.Response, error) {
+	s.sign(req, payloadHash, s.now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return resp, fmt.Errorf("s3 %s failed with status %d: %s", req.Method, resp.StatusCode, string(body))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return resp, nil
+}
+
+// objectURL là URL của object trên endpoint, theo kiểu path-style hoặc virtual-hosted
+func (s *S3Storage) objectURL(key string) *url.URL {
+	u := *s.endpoint
+	escaped := escapeObjectKey(key)
+	if s.cfg.PathStyle {
+		u.Path = s.endpoint.Path + "/" + s.cfg.Bucket + "/" + key
+		u.RawPath = s.endpoint.EscapedPath() + "/" + escapeObjectKey(s.cfg.Bucket) + "/" + escaped
+	} else {
+		u.Host = s.cfg.Bucket + "." + s.endpoint.Host
+		u.Path = s.endpoint.Path + "/" + key
+		u.RawPath = s.endpoint.EscapedPath() + "/" + escaped
+	}
+	return &u
+}
+
+func (s *S3Storage) publicObjectURL(key string) string {
+	if s.cfg.PublicURL != "" {
+		return s.cfg.PublicURL + "/" + escapeObjectKey(key)
+	}
+	return s.objectURL(key).String()
+}
+
+// SHA-256 của nội dung rỗng
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// sign ký request theo AWS Signature Version 4 bằng header Authorization.
+// Mọi header đã có trên request (cùng host, x-amz-date, x-amz-content-sha256) đều được ký.
+func (s *S3Storage) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope, signature := s.signature(amzDate, canonicalRequest)
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.cfg.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// presign ký URL bằng query string theo SigV4. Chỉ header host và các header trong headers (tên viết thường)
+// được ký, client tự đặt các header khác.
+func (s *S3Storage) presign(method string, target *url.URL, headers map[string]string, expires time.Duration, now time.Time) *url.URL {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	scope := amzDate[:8] + "/" + s.cfg.Region + "/s3/aws4_request"
+
+	signed := map[string]string{"host": target.Host}
+	for name, value := range headers {
+		signed[name] = value
+	}
+	names := make([]string, 0, len(signed))
+	for name := range signed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + signed[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	query := target.Query()
+	query.Set("X-Amz-Algorithm", "AWS4-HMAC-SHA256")
+	query.Set("X-Amz-Credential", s.cfg.AccessKeyID+"/"+scope)
+	query.Set("X-Amz-Date", amzDate)
+	query.Set("X-Amz-Expires", strconv.Itoa(int(expires.Seconds())))
+	query.Set("X-Amz-SignedHeaders", signedHeaders)
+
+	canonicalRequest := strings.Join([]string{
+		method,
+		target.EscapedPath(),
+		canonicalQuery(query),
+		canonicalHeaders.String(),
+		signedHeaders,
+		unsignedPayload,
+	}, "\n")
+	_, signature := s.signature(amzDate, canonicalRequest)
+
+	signedURL := *target
+	signedURL.RawQuery = canonicalQuery(query) + "&X-Amz-Signature=" + signature
+	return &signedURL
+}
+
+// signature tính chữ ký SigV4 của canonical request, trả về credential scope và chữ ký (hex)
+func (s *S3Storage) signature(amzDate, canonicalRequest string) (string, string) {
+	date := amzDate[:8]
+	scope := date + "/" + s.cfg.Region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.cfg.SecretAccessKey), date)
+	key = hmacSHA256(key, s.cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	return scope, hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQuery sắp xếp và mã hoá query string theo quy tắc của SigV4
+func canonicalQuery(values url.Values) string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(values))
+	for _, key := range keys {
+		vals := append([]string(nil), values[key]...)
+		sort.Strings(vals)
+		for _, value := range vals {
+			pairs = append(pairs, uriEncode(key)+"="+uriEncode(value))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode mã hoá một thành phần của query (kể cả dấu "/")
+func uriEncode(value string) string {
+	return strings.ReplaceAll(escapeObjectKey(value), "/", "%2F")
+}
@@ -0,0 +1,414 @@
+package controllers
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"ecommerce-project/config"
+	"ecommerce-project/models"
+	"ecommerce-project/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// AdminGetUsers (admin) tìm kiếm người dùng theo email, tên hoặc số điện thoại (q),
+// lọc theo role, status (active, disabled, deleted) và ngày đăng ký (signed_up_from, signed_up_to: YYYY-MM-DD).
+func AdminGetUsers(c *gin.Context) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if err != nil || pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+	offset := (page - 1) * pageSize
+
+	query := config.DB.Model(&models.User{})
+
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		pattern := "%" + strings.ToLower(q) + "%"
+		query = query.Where("LOWER(email) LIKE ? OR LOWER(username) LIKE ? OR LOWER(full_name) LIKE ? OR phone_number LIKE ?",
+			pattern, pattern, pattern, pattern)
+	}
+	if role := c.Query("role"); role != "" {
+		query = query.Where("role = ?", role)
+	}
+
+	switch c.DefaultQuery("status", "active") {
+	case "active":
+		query = query.Where("deleted_at IS NULL AND disabled_at IS NULL")
+	case "disabled":
+		query = query.Where("deleted_at IS NULL AND disabled_at IS NOT NULL")
+	case "deleted":
+		query = query.Where("deleted_at IS NOT NULL")
+	case "all":
+	default:
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid status", "status must be one of active, disabled, deleted, all")
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+
+	if from := c.Query("signed_up_from"); from != "" {
+		date, err := time.Parse("2006-01-02", from)
+		if err != nil {
+			errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid signed_up_from", err.Error())
+			c.JSON(http.StatusBadRequest, errResp)
+			return
+		}
+		query = query.Where("created_at >= ?", date)
+	}
+	if to := c.Query("signed_up_to"); to != "" {
+		date, err := time.Parse("2006-01-02", to)
+		if err != nil {
+			errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid signed_up_to", err.Error())
+			c.JSON(http.StatusBadRequest, errResp)
+			return
+		}
+		// Bao gồm cả ngày signed_up_to
+		query = query.Where("created_at < ?", date.AddDate(0, 0, 1))
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to count users", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	var users []models.User
+	if err := query.Order("created_at DESC").Offset(offset).Limit(pageSize).Find(&users).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to fetch users", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	data := make([]models.UserResponse, 0, len(users))
+	for _, user := range users {
+		data = append(data, models.NewUserResponse(user))
+	}
+
+	totalPages := int(math.Ceil(float64(total) / float64(pageSize)))
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": data,
+		"pagination": gin.H{
+			"total":       total,
+			"page":        page,
+			"page_size":   pageSize,
+			"total_pages": totalPages,
+		},
+	})
+}
+
+// AdminGetUser (admin) trả về chi tiết người dùng kèm vai trò nhân viên, tài khoản liên kết,
+// số phiên đăng nhập và thống kê đơn hàng. LifetimeValue là tổng giá trị đơn hàng trừ số tiền
+// đã hoàn qua các yêu cầu trả hàng.
+func AdminGetUser(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid user id", err.Error())
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, "id = ?", userID).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusNotFound, "User not found", err.Error())
+		c.JSON(http.StatusNotFound, errResp)
+		return
+	}
+
+	detail := models.AdminUserDetail{
+		UserResponse:   models.NewUserResponse(user),
+		StaffRoles:     []string{},
+		LinkedAccounts: []string{},
+	}
+	if err := config.DB.Model(&models.UserRole{}).Where("user_id = ?", user.ID).
+		Order("role_name ASC").Pluck("role_name", &detail.StaffRoles).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to fetch roles", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+	if err := config.DB.Model(&models.UserIdentity{}).Where("user_id = ?", user.ID).
+		Order("provider ASC").Pluck("provider", &detail.LinkedAccounts).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to fetch linked accounts", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+	if err := config.DB.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", user.ID, time.Now()).
+		Count(&detail.ActiveSessions).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to count sessions", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	var orders struct {
+		Count int64
+		Total float64
+	}
+	if err := config.DB.Model(&models.Order{}).Select("COUNT(*) AS count, COALESCE(SUM(total), 0) AS total").
+		Where("user_id = ?", user.ID).Scan(&orders).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to fetch order statistics", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+	var refunded float64
+	if err := config.DB.Model(&models.ReturnRequest{}).Select("COALESCE(SUM(refunded_amount), 0)").
+		Where("user_id = ?", user.ID).Scan(&refunded).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to fetch order statistics", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+	detail.OrderCount = orders.Count
+	detail.LifetimeValue = math.Round((orders.Total-refunded)*100) / 100
+
+	c.JSON(http.StatusOK, detail)
+}
+
+// AdminChangeUserRole (admin) đổi vai trò cơ bản (user hoặc admin) của người dùng.
+// Chỉ admin mới được cấp hoặc thu hồi vai trò admin; nhân viên có quyền role:manage
+// không thể tự nâng ai lên admin. TokenVersion được tăng để access token mang vai trò cũ bị từ chối ngay.
+func AdminChangeUserRole(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid user id", err.Error())
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+
+	var input models.ChangeRoleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.HandleValidationError(c, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(input); err != nil {
+		utils.HandleValidationError(c, err)
+		return
+	}
+
+	// Admin không tự hạ quyền của chính mình để tránh mất quyền quản trị cuối cùng
+	if actorID := currentActorID(c); actorID != nil && *actorID == userID {
+		errResp := models.NewErrorResponse(http.StatusConflict, "You cannot change your own role")
+		c.JSON(http.StatusConflict, errResp)
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, "id = ? AND deleted_at IS NULL", userID).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusNotFound, "User not found", err.Error())
+		c.JSON(http.StatusNotFound, errResp)
+		return
+	}
+
+	if (input.Role == "admin" || user.Role == "admin") && c.GetString("role") != "admin" {
+		errResp := models.NewErrorResponse(http.StatusForbidden, "Only administrators can grant or revoke the admin role")
+		c.JSON(http.StatusForbidden, errResp)
+		return
+	}
+
+	previousRole := user.Role
+	if err := config.DB.Model(&user).Updates(map[string]interface{}{
+		"role":          input.Role,
+		"token_version": gorm.Expr("token_version + 1"),
+		"updated_at":    time.Now(),
+	}).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to change role", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	recordSecurityEvent(models.SecurityEvent{
+		Type:      models.SecurityEventRoleChanged,
+		UserID:    &user.ID,
+		ActorID:   currentActorID(c),
+		Email:     user.Email,
+		IPAddress: c.ClientIP(),
+		Details:   previousRole + " -> " + input.Role,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role changed successfully"})
+}
+
+// setUserDisabled vô hiệu hoá hoặc kích hoạt lại tài khoản. Khi vô hiệu hoá, mọi phiên đăng nhập
+// bị thu hồi; AuthMiddleware và Login từ chối tài khoản có DisabledAt.
+func setUserDisabled(c *gin.Context, disabled bool) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid user id", err.Error())
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+
+	if actorID := currentActorID(c); disabled && actorID != nil && *actorID == userID {
+		errResp := models.NewErrorResponse(http.StatusConflict, "You cannot disable your own account")
+		c.JSON(http.StatusConflict, errResp)
+		return
+	}
+
+	var user models.User
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&user, "id = ? AND deleted_at IS NULL", userID).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if !disabled {
+			return tx.Model(&user).Updates(map[string]interface{}{
+				"disabled_at": nil,
+				"updated_at":  now,
+			}).Error
+		}
+
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"disabled_at":   now,
+			"token_version": gorm.Expr("token_version + 1"),
+			"updated_at":    now,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.RefreshToken{}).
+			Where("user_id = ? AND revoked_at IS NULL", user.ID).
+			Update("revoked_at", now).Error
+	})
+	if err == gorm.ErrRecordNotFound {
+		errResp := models.NewErrorResponse(http.StatusNotFound, "User not found", err.Error())
+		c.JSON(http.StatusNotFound, errResp)
+		return
+	}
+	if err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to update user", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	eventType := models.SecurityEventAccountEnabled
+	message := "User enabled successfully"
+	if disabled {
+		eventType = models.SecurityEventAccountDisabled
+		message = "User disabled successfully"
+	}
+	recordSecurityEvent(models.SecurityEvent{
+		Type:      eventType,
+		UserID:    &user.ID,
+		ActorID:   currentActorID(c),
+		Email:     user.Email,
+		IPAddress: c.ClientIP(),
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": message})
+}
+
+// AdminDisableUser (admin) vô hiệu hoá tài khoản
+func AdminDisableUser(c *gin.Context) {
+	setUserDisabled(c, true)
+}
+
+// AdminEnableUser (admin) kích hoạt lại tài khoản đã bị vô hiệu hoá
+func AdminEnableUser(c *gin.Context) {
+	setUserDisabled(c, false)
+}
+
+// ImpersonateUser (admin) cấp access token để admin xem hệ thống dưới danh nghĩa người dùng.
+// Token có claim imp (id admin), phiên giả danh được ghi vào sự kiện bảo mật và không có
+// refresh token: hết hạn sau AccessTokenTTL hoặc khi bị thu hồi như một phiên thường.
+func ImpersonateUser(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid user id", err.Error())
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+
+	actorID := currentActorID(c)
+	if actorID == nil {
+		errResp := models.NewErrorResponse(http.StatusUnauthorized, "Unauthorized")
+		c.JSON(http.StatusUnauthorized, errResp)
+		return
+	}
+
+	// Phiên giả danh không được dùng để giả danh tiếp
+	if _, impersonating := c.Get("impersonatorID"); impersonating {
+		errResp := models.NewErrorResponse(http.StatusForbidden, "Cannot impersonate from an impersonation session")
+		c.JSON(http.StatusForbidden, errResp)
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, "id = ? AND deleted_at IS NULL AND disabled_at IS NULL", userID).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusNotFound, "User not found", err.Error())
+		c.JSON(http.StatusNotFound, errResp)
+		return
+	}
+
+	// Không cho phép giả danh admin hay nhân viên để tránh leo thang quyền
+	if user.Role == "admin" {
+		errResp := models.NewErrorResponse(http.StatusForbidden, "Administrators cannot be impersonated")
+		c.JSON(http.StatusForbidden, errResp)
+		return
+	}
+	var staffRoles int64
+	if err := config.DB.Model(&models.UserRole{}).Where("user_id = ?", user.ID).Count(&staffRoles).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to fetch roles", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+	if staffRoles > 0 {
+		errResp := models.NewErrorResponse(http.StatusForbidden, "Staff accounts cannot be impersonated")
+		c.JSON(http.StatusForbidden, errResp)
+		return
+	}
+
+	session := models.RefreshToken{
+		UserID:         user.ID,
+		FamilyID:       uuid.New(),
+		DeviceName:     "Impersonation by " + actorID.String(),
+		UserAgent:      c.Request.UserAgent(),
+		IPAddress:      c.ClientIP(),
+		ImpersonatorID: actorID,
+	}
+
+	accessToken, refreshToken, err := utils.GenerateTokens(user, session)
+	if err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to generate tokens", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	// Lưu bản ghi phiên để AuthMiddleware chấp nhận token, refresh token gốc không được trả về.
+	// Phiên hết hạn cùng access token.
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		record, err := storeRefreshToken(tx, session, refreshToken)
+		if err != nil {
+			return err
+		}
+		return tx.Model(&record).Update("expires_at", time.Now().Add(utils.AccessTokenTTL)).Error
+	})
+	if err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to start session", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	recordSecurityEvent(models.SecurityEvent{
+		Type:      models.SecurityEventImpersonation,
+		UserID:    &user.ID,
+		ActorID:   actorID,
+		Email:     user.Email,
+		IPAddress: c.ClientIP(),
+		Details:   "session " + session.FamilyID.String(),
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"access_token":  accessToken,
+		"expires_in":    int(utils.AccessTokenTTL.Seconds()),
+		"impersonating": models.NewUserResponse(user),
+	})
+}
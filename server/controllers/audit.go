@@ -0,0 +1,78 @@
+package controllers
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"ecommerce-project/config"
+	"ecommerce-project/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetAuditLogs (admin) truy vấn audit log, lọc theo actor_id, entity_type, entity_id, action,
+// route và khoảng thời gian (from, to theo RFC 3339), mới nhất trước.
+func GetAuditLogs(c *gin.Context) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", "50"))
+	if err != nil || pageSize < 1 || pageSize > 200 {
+		pageSize = 50
+	}
+	offset := (page - 1) * pageSize
+
+	query := config.DB.Model(&models.AuditLog{})
+	for _, filter := range []string{"actor_id", "entity_type", "entity_id", "action", "route"} {
+		if value := c.Query(filter); value != "" {
+			query = query.Where(filter+" = ?", value)
+		}
+	}
+	if from := c.Query("from"); from != "" {
+		t, err := time.Parse(time.RFC3339, from)
+		if err != nil {
+			errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid from", err.Error())
+			c.JSON(http.StatusBadRequest, errResp)
+			return
+		}
+		query = query.Where("created_at >= ?", t)
+	}
+	if to := c.Query("to"); to != "" {
+		t, err := time.Parse(time.RFC3339, to)
+		if err != nil {
+			errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid to", err.Error())
+			c.JSON(http.StatusBadRequest, errResp)
+			return
+		}
+		query = query.Where("created_at <= ?", t)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to count audit logs", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	var logs []models.AuditLog
+	if err := query.Order("created_at DESC").Offset(offset).Limit(pageSize).Find(&logs).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to fetch audit logs", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	totalPages := int(math.Ceil(float64(total) / float64(pageSize)))
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": logs,
+		"pagination": gin.H{
+			"total":       total,
+			"page":        page,
+			"page_size":   pageSize,
+			"total_pages": totalPages,
+		},
+	})
+}
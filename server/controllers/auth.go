@@ -3,21 +3,94 @@ package controllers
 import (
 	"ecommerce-project/config"
 	"ecommerce-project/models"
+	"ecommerce-project/notification"
 	"ecommerce-project/utils"
+	"errors"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+var (
+	errRefreshTokenNotFound = errors.New("refresh token not found")
+	errRefreshTokenExpired  = errors.New("refresh token expired")
+	errRefreshTokenReused   = errors.New("refresh token reused")
+	errAccountDisabled      = errors.New("account is disabled")
+)
+
+// storeRefreshToken lưu hash của refresh token mới. UserID, FamilyID và thông tin thiết bị
+// được lấy từ session (bản ghi của token trước đó hoặc phiên mới tạo).
+func storeRefreshToken(tx *gorm.DB, session models.RefreshToken, token string) (models.RefreshToken, error) {
+	now := time.Now()
+	record := models.RefreshToken{
+		ID:             uuid.New(),
+		UserID:         session.UserID,
+		TokenHash:      utils.HashToken(token),
+		FamilyID:       session.FamilyID,
+		ExpiresAt:      now.Add(7 * 24 * time.Hour), // Refresh token hết hạn sau 7 ngày
+		DeviceName:     session.DeviceName,
+		UserAgent:      session.UserAgent,
+		IPAddress:      session.IPAddress,
+		LastUsedAt:     now,
+		MFA:            session.MFA,
+		ImpersonatorID: session.ImpersonatorID,
+		CreatedAt:      now,
+	}
+	return record, tx.Create(&record).Error
+}
+
+// startSession bắt đầu một phiên đăng nhập mới (family refresh token mới) cho user
+// và trả về cặp access token, refresh token. mfa cho biết người dùng đã qua bước 2FA.
+func startSession(c *gin.Context, user models.User, deviceName string, mfa bool) (string, string, error) {
+	userAgent := c.Request.UserAgent()
+	if deviceName == "" {
+		deviceName = userAgent
+	}
+	if len(deviceName) > 100 {
+		deviceName = deviceName[:100]
+	}
+
+	session := models.RefreshToken{
+		UserID:     user.ID,
+		FamilyID:   uuid.New(),
+		DeviceName: deviceName,
+		UserAgent:  userAgent,
+		IPAddress:  c.ClientIP(),
+		MFA:        mfa,
+	}
+
+	accessToken, refreshToken, err := utils.GenerateTokens(user, session)
+	if err != nil {
+		return "", "", err
+	}
+
+	if _, err := storeRefreshToken(config.DB, session, refreshToken); err != nil {
+		return "", "", err
+	}
+
+	return accessToken, refreshToken, nil
+}
+
+// revokeTokenFamily thu hồi mọi refresh token còn hiệu lực của một family
+func revokeTokenFamily(tx *gorm.DB, familyID uuid.UUID) error {
+	return tx.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+}
+
 // Register
 func Register(c *gin.Context) {
     type Request struct {
         Username string `json:"username" validate:"required,min=3,max=50"`
         Email    string `json:"email" validate:"required,email"`
         Password string `json:"password" validate:"required,min=6"`
+        // Ngôn ngữ nhận email (vi hoặc en), mặc định là vi
+        Locale   string `json:"locale" validate:"omitempty,oneof=vi en"`
     }
 
     var req Request
@@ -52,9 +125,22 @@ func Register(c *gin.Context) {
         Username:     req.Username,
         Email:        req.Email,
         PasswordHash: string(hashedPassword),
+        Locale:       notification.NormalizeLocale(req.Locale),
     }
 
-    if err := config.DB.Create(&localUser).Error; err != nil {
+    // Lưu user, email chào mừng và email xác minh trong cùng một transaction
+    err = config.DB.Transaction(func(tx *gorm.DB) error {
+        if err := tx.Create(&localUser).Error; err != nil {
+            return err
+        }
+        if err := notification.Enqueue(tx, notification.TemplateWelcome, localUser.Locale, localUser.Email, map[string]interface{}{
+            "Username": localUser.Username,
+        }); err != nil {
+            return err
+        }
+        return sendEmailVerification(tx, localUser)
+    })
+    if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save user in database"})
         return
     }
@@ -67,6 +153,8 @@ func Login(c *gin.Context) {
     type Request struct {
         Email    string `json:"email" validate:"required,email"`
         Password string `json:"password" validate:"required"`
+        // Tên thiết bị hiển thị trong danh sách phiên đăng nhập, mặc định là User-Agent
+        DeviceName string `json:"device_name" validate:"max=100"`
     }
 
     var req Request
@@ -81,37 +169,54 @@ func Login(c *gin.Context) {
         return
     }
 
+    // Từ chối khi tài khoản hoặc IP đang bị chặn do đăng nhập sai nhiều lần
+    if !checkLoginAllowed(c, req.Email) {
+        return
+    }
+
     // Lấy thông tin user từ database
     var user models.User
     if err := config.DB.Where("email = ?", req.Email).First(&user).Error; err != nil {
+        recordLoginFailure(c, req.Email, nil)
         c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
         return
     }
 
     // So sánh password
     if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
+        recordLoginFailure(c, req.Email, &user.ID)
         c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
         return
     }
 
-    // Tạo access token và refresh token
-    accessToken, refreshToken, err := utils.GenerateTokens(user)
-    if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate tokens"})
+    // Tài khoản bị admin vô hiệu hoá
+    if user.DisabledAt != nil {
+        c.JSON(http.StatusForbidden, gin.H{"error": "Account is disabled"})
         return
     }
 
-    // Lưu refresh token vào database
-    newRefreshToken := models.RefreshToken{
-        ID:        uuid.New(),
-        UserID:    user.ID,
-        Token:     refreshToken,
-        ExpiresAt: time.Now().Add(7 * 24 * time.Hour), // Refresh token hết hạn sau 7 ngày
-        CreatedAt: time.Now(),
+    // Người dùng đã bật 2FA: bộ đếm chỉ được xoá khi bước 2 thành công.
+    // Trả về challenge token, client gọi tiếp /auth/login/2fa kèm mã TOTP
+    if user.TOTPEnabled {
+        challengeToken, err := issueUserToken(config.DB, user.ID, models.TokenPurposeTwoFactorChallenge, 5*time.Minute)
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create two-factor challenge"})
+            return
+        }
+
+        c.JSON(http.StatusOK, gin.H{
+            "two_factor_required": true,
+            "challenge_token":     challengeToken,
+        })
+        return
     }
 
-    if err := config.DB.Create(&newRefreshToken).Error; err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save refresh token"})
+    recordLoginSuccess(user.Email)
+
+    // Tạo access token và refresh token cho phiên đăng nhập mới
+    accessToken, refreshToken, err := startSession(c, user, req.DeviceName, false)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate tokens"})
         return
     }
 
@@ -135,15 +240,15 @@ func Logout(c *gin.Context) {
 		return
 	}
 
-	// Với một API đơn giản, ta chỉ kiểm tra và xoá refresh token khỏi database.
+	// Kiểm tra refresh token trong database (so khớp theo hash)
 	var tokenRecord models.RefreshToken
-	if err := config.DB.Where("token = ?", req.RefreshToken).First(&tokenRecord).Error; err != nil {
+	if err := config.DB.Where("token_hash = ?", utils.HashToken(req.RefreshToken)).First(&tokenRecord).Error; err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token not found"})
 		return
 	}
 
-	// Xoá refresh token khỏi database
-	if err := config.DB.Where("token = ?", req.RefreshToken).Delete(&models.RefreshToken{}).Error; err != nil {
+	// Thu hồi toàn bộ family của refresh token (phiên đăng nhập hiện tại)
+	if err := revokeTokenFamily(config.DB, tokenRecord.FamilyID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete refresh token"})
 		return
 	}
@@ -165,34 +270,87 @@ func Refresh(c *gin.Context) {
 		return
 	}
 
-	// Truy vấn database để lấy record của refresh token
-	var tokenRecord models.RefreshToken
-	if err := config.DB.Where("token = ?", req.RefreshToken).First(&tokenRecord).Error; err != nil {
+	var accessToken, refreshToken string
+	reused := false
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		// Khoá record của refresh token để hai request refresh đồng thời không cùng xoay vòng một token
+		var tokenRecord models.RefreshToken
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", utils.HashToken(req.RefreshToken)).
+			First(&tokenRecord).Error; err != nil {
+			return errRefreshTokenNotFound
+		}
+
+		// Token đã bị thu hồi (đã xoay vòng hoặc đăng xuất) mà vẫn được dùng lại:
+		// có thể token đã bị lộ, toàn bộ family bị thu hồi trong chính transaction này
+		// (transaction được commit, request vẫn bị từ chối).
+		if tokenRecord.RevokedAt != nil {
+			reused = true
+			return revokeTokenFamily(tx, tokenRecord.FamilyID)
+		}
+
+		// Kiểm tra xem refresh token đã hết hạn hay chưa
+		if time.Now().After(tokenRecord.ExpiresAt) {
+			return errRefreshTokenExpired
+		}
+
+		// Lấy thông tin người dùng dựa trên tokenRecord.UserID
+		var user models.User
+		if err := tx.First(&user, "id = ?", tokenRecord.UserID).Error; err != nil {
+			return err
+		}
+		if user.DisabledAt != nil {
+			return errAccountDisabled
+		}
+
+		// Cấp cặp token mới, token mới thuộc cùng family với token cũ
+		var err error
+		accessToken, refreshToken, err = utils.GenerateTokens(user, tokenRecord)
+		if err != nil {
+			return err
+		}
+
+		// Giữ tên thiết bị, cập nhật User-Agent và IP theo request hiện tại
+		session := tokenRecord
+		session.UserAgent = c.Request.UserAgent()
+		session.IPAddress = c.ClientIP()
+		newRecord, err := storeRefreshToken(tx, session, refreshToken)
+		if err != nil {
+			return err
+		}
+
+		// Vô hiệu hoá token cũ
+		now := time.Now()
+		tokenRecord.RevokedAt = &now
+		tokenRecord.ReplacedByID = &newRecord.ID
+		return tx.Save(&tokenRecord).Error
+	})
+	if err == nil && reused {
+		err = errRefreshTokenReused
+	}
+	switch err {
+	case nil:
+	case errRefreshTokenNotFound:
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token not found"})
 		return
-	}
-
-	// Kiểm tra xem refresh token đã hết hạn hay chưa
-	if time.Now().After(tokenRecord.ExpiresAt) {
+	case errRefreshTokenExpired:
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token expired"})
 		return
-	}
-
-	// Lấy thông tin người dùng dựa trên tokenRecord.UserID
-	var user models.User
-	if err := config.DB.First(&user, "id = ?", tokenRecord.UserID).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "User not found"})
+	case errAccountDisabled:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account is disabled"})
 		return
-	}
-
-	// Tạo access token mới dựa trên thông tin người dùng
-	newAccessToken, err := utils.GenerateAccessToken(user)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate new access token"})
+	case errRefreshTokenReused:
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token reuse detected, please log in again"})
+		return
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh tokens"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"access_token": newAccessToken})
+	c.JSON(http.StatusOK, gin.H{
+		"access_token":  accessToken,
+		"refresh_token": refreshToken,
+	})
 }
 
 func CheckMe(c *gin.Context) {
@@ -204,9 +362,17 @@ func CheckMe(c *gin.Context) {
 	}
 
 	role, _ := c.Get("role") // Nếu cần thiết, lấy thêm thông tin role
+	permissions, _ := c.Get("permissions")
 
-	c.JSON(http.StatusOK, gin.H{
-		"userID": userID,
-		"role":   role,
-	})
+	response := gin.H{
+		"userID":      userID,
+		"role":        role,
+		"permissions": permissions,
+	}
+	// Phiên do admin giả danh, client hiển thị cảnh báo
+	if impersonatorID, ok := c.Get("impersonatorID"); ok {
+		response["impersonator_id"] = impersonatorID
+	}
+
+	c.JSON(http.StatusOK, response)
 }
\ No newline at end of file
@@ -1,11 +1,15 @@
 package controllers
 
 import (
+	"errors"
+	"log"
 	"net/http"
 	"time"
 
 	"ecommerce-project/config"
 	"ecommerce-project/models"
+	"ecommerce-project/notification"
+	"ecommerce-project/payment"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -63,6 +67,14 @@ func AddCartItem(c *gin.Context) {
 		return
 	}
 
+	// Variant phải còn tồn tại (không nằm trong thùng rác) và thuộc sản phẩm đang được bán
+	var variant models.ProductVariant
+	if err := config.DB.Scopes(models.VisibleVariants).First(&variant, "id = ?", variantID).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusNotFound, "Variant not found", err.Error())
+		c.JSON(http.StatusNotFound, errResp)
+		return
+	}
+
 	// Lấy (hoặc tạo mới) Cart của người dùng
 	cart, err := getOrCreateCart(userID)
 	if err != nil {
@@ -256,7 +268,8 @@ func ClearCart(c *gin.Context) {
 }
 
 // CheckoutCart xử lý thanh toán cho toàn bộ Cart của người dùng.
-// Sau khi thanh toán thành công, toàn bộ CartItem trong Cart sẽ bị xoá.
+// Thanh toán được thu qua payment.DefaultProvider, sau đó đơn hàng được lưu, tồn kho bị trừ
+// và toàn bộ CartItem trong Cart sẽ bị xoá. Trả về 409 nếu có variant không đủ hàng.
 func CheckoutCart(c *gin.Context) {
 	userIDInterface, exists := c.Get("userID")
 	if !exists {
@@ -279,9 +292,9 @@ func CheckoutCart(c *gin.Context) {
 		return
 	}
 
-	// Lấy tất cả CartItem trong Cart
+	// Lấy tất cả CartItem trong Cart (kèm Variant để tính tiền cho email xác nhận)
 	var cartItems []models.CartItem
-	if err := config.DB.Where("cart_id = ?", cart.ID).Find(&cartItems).Error; err != nil {
+	if err := config.DB.Preload("Variant", models.VisibleVariants).Where("cart_id = ?", cart.ID).Find(&cartItems).Error; err != nil {
 		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to fetch cart items", err.Error())
 		c.JSON(http.StatusInternalServerError, errResp)
 		return
@@ -293,17 +306,102 @@ func CheckoutCart(c *gin.Context) {
 		return
 	}
 
-	// Giả sử xử lý thanh toán thành công (tích hợp gateway thanh toán nếu cần)
-	// Sau đó, xoá toàn bộ CartItem trong Cart.
-	if err := config.DB.Where("cart_id = ?", cart.ID).Delete(&models.CartItem{}).Error; err != nil {
-		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to remove purchased items from cart", err.Error())
+	// Variant đã bị xoá hoặc sản phẩm đã ngừng bán sau khi thêm vào giỏ không được preload
+	for _, item := range cartItems {
+		if item.Variant.ID == uuid.Nil {
+			errResp := models.NewErrorResponse(http.StatusConflict, "Some items are no longer available", item.ID.String())
+			c.JSON(http.StatusConflict, errResp)
+			return
+		}
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, "id = ?", userID).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusNotFound, "User not found", err.Error())
+		c.JSON(http.StatusNotFound, errResp)
+		return
+	}
+
+	// Chuẩn bị dữ liệu cho email xác nhận thanh toán
+	checkoutTime := time.Now()
+	items := make([]map[string]interface{}, 0, len(cartItems))
+	total := 0.0
+	for _, item := range cartItems {
+		subtotal := item.Variant.Price * float64(item.Quantity)
+		total += subtotal
+		items = append(items, map[string]interface{}{
+			"Color":    item.Variant.Color,
+			"Capacity": item.Variant.Capacity,
+			"Quantity": item.Quantity,
+			"Subtotal": subtotal,
+		})
+	}
+
+	provider, err := payment.DefaultProvider()
+	if err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to get payment provider", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	order := models.Order{
+		ID:              uuid.New(),
+		UserID:          userID,
+		Status:          models.OrderStatusPlaced,
+		Total:           total,
+		PaymentProvider: provider.Name(),
+		PlacedAt:        checkoutTime,
+		UpdatedAt:       checkoutTime,
+	}
+	order.PaymentReference, err = provider.Charge(order.ID, total)
+	if err != nil {
+		errResp := models.NewErrorResponse(http.StatusPaymentRequired, "Payment failed", err.Error())
+		c.JSON(http.StatusPaymentRequired, errResp)
+		return
+	}
+
+	// Lưu đơn hàng, trừ tồn kho, xoá toàn bộ CartItem trong Cart và ghi email xác nhận vào outbox
+	// trong cùng một transaction.
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := placeOrder(tx, &order, cartItems); err != nil {
+			return err
+		}
+		if err := tx.Where("cart_id = ?", cart.ID).Delete(&models.CartItem{}).Error; err != nil {
+			return err
+		}
+		// Ghi nhận các sản phẩm được mua cùng nhau cho gợi ý sản phẩm
+		if err := recordPurchases(tx, userID, cartItems, checkoutTime); err != nil {
+			return err
+		}
+		return notification.Enqueue(tx, notification.TemplateOrderConfirmation, user.Locale, user.Email, map[string]interface{}{
+			"Username":     user.Username,
+			"OrderID":      order.ID.String(),
+			"CheckoutTime": checkoutTime.Format("02/01/2006 15:04"),
+			"Items":        items,
+			"Total":        total,
+		})
+	})
+	if err != nil {
+		// Đơn hàng không được lưu: hoàn lại khoản tiền vừa thu
+		if _, refundErr := provider.Refund(order.PaymentReference, order.ID, total); refundErr != nil {
+			log.Printf("checkout: failed to refund payment %s of unsaved order %s: %v", order.PaymentReference, order.ID, refundErr)
+		}
+
+		var stockErr insufficientStockError
+		if errors.As(err, &stockErr) {
+			errResp := models.NewErrorResponse(http.StatusConflict, "Some items are out of stock", stockErr.variantID.String())
+			c.JSON(http.StatusConflict, errResp)
+			return
+		}
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to place order", err.Error())
 		c.JSON(http.StatusInternalServerError, errResp)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"message":         "Checkout successful",
+		"order":           order,
 		"purchased_items": cartItems,
-		"checkout_time":   time.Now(),
+		"checkout_time":   checkoutTime,
 	})
 }
@@ -6,6 +6,7 @@ import (
 
 	"ecommerce-project/config"
 	"ecommerce-project/models"
+	"ecommerce-project/specs"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -19,6 +20,11 @@ func CreateCategory(c *gin.Context) {
         return
     }
 
+    if err := specs.ValidateSchema(input.SpecSchema); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
     input.ID = uuid.New()
     input.CreatedAt = time.Now()
     input.UpdatedAt = time.Now()
@@ -70,6 +76,14 @@ func UpdateCategory(c *gin.Context) {
     }
 
     category.Name = input.Name
+    // Schema mới chỉ áp dụng khi sản phẩm được tạo hoặc cập nhật; giá trị đã lưu không bị kiểm tra lại
+    if input.SpecSchema != nil {
+        if err := specs.ValidateSchema(input.SpecSchema); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+        }
+        category.SpecSchema = input.SpecSchema
+    }
     category.UpdatedAt = time.Now()
 
     if err := config.DB.Save(&category).Error; err != nil {
@@ -80,13 +94,30 @@ func UpdateCategory(c *gin.Context) {
     c.JSON(http.StatusOK, category)
 }
 
-// DeleteCategory deletes a category by ID
+// DeleteCategory moves a category to the trash (soft delete).
+// Categories that still contain products cannot be deleted.
 func DeleteCategory(c *gin.Context) {
     id := c.Param("id")
-    if err := config.DB.Delete(&models.Category{}, "id = ?", id).Error; err != nil {
+
+    var productCount int64
+    if err := config.DB.Model(&models.Product{}).Where("category_id = ?", id).Count(&productCount).Error; err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
         return
     }
+    if productCount > 0 {
+        c.JSON(http.StatusConflict, gin.H{"error": "Category still contains products"})
+        return
+    }
+
+    result := config.DB.Delete(&models.Category{}, "id = ?", id)
+    if result.Error != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+        return
+    }
+    if result.RowsAffected == 0 {
+        c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+        return
+    }
 
     c.JSON(http.StatusOK, gin.H{"message": "Category deleted successfully"})
 }
\ No newline at end of file
@@ -0,0 +1,225 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"ecommerce-project/config"
+	"ecommerce-project/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Số cặp tương thích tối đa trong một request hàng loạt
+const maxCompatibilityBatch = 1000
+
+// compatibleAccessories giới hạn truy vấn sản phẩm ở các phụ kiện dùng được với thiết bị deviceID
+func compatibleAccessories(deviceID uuid.UUID) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("products.id IN (?)",
+			config.DB.Model(&models.ProductCompatibility{}).Select("accessory_id").Where("device_id = ?", deviceID))
+	}
+}
+
+// compatibleDevices giới hạn truy vấn sản phẩm ở các thiết bị mà phụ kiện accessoryID dùng được
+func compatibleDevices(accessoryID uuid.UUID) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("products.id IN (?)",
+			config.DB.Model(&models.ProductCompatibility{}).Select("device_id").Where("accessory_id = ?", accessoryID))
+	}
+}
+
+// parseCompatibilityPairs kiểm tra các cặp tương thích: phụ kiện khác thiết bị và cả hai sản phẩm đều tồn tại
+func parseCompatibilityPairs(tx *gorm.DB, pairs []models.CompatibilityPair) ([]models.ProductCompatibility, error) {
+	result := make([]models.ProductCompatibility, 0, len(pairs))
+	ids := map[uuid.UUID]bool{}
+	for _, pair := range pairs {
+		accessoryID := uuid.MustParse(pair.AccessoryID)
+		deviceID := uuid.MustParse(pair.DeviceID)
+		if accessoryID == deviceID {
+			return nil, fmt.Errorf("product %s cannot be compatible with itself", accessoryID)
+		}
+		ids[accessoryID] = true
+		ids[deviceID] = true
+		result = append(result, models.ProductCompatibility{AccessoryID: accessoryID, DeviceID: deviceID})
+	}
+	return result, checkProductsExist(tx, ids)
+}
+
+// checkProductsExist trả về lỗi nếu có id không thuộc sản phẩm nào (hoặc sản phẩm đã nằm trong thùng rác)
+func checkProductsExist(tx *gorm.DB, ids map[uuid.UUID]bool) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	list := make([]uuid.UUID, 0, len(ids))
+	for id := range ids {
+		list = append(list, id)
+	}
+
+	var found []uuid.UUID
+	if err := tx.Model(&models.Product{}).Where("id IN ?", list).Pluck("id", &found).Error; err != nil {
+		return err
+	}
+	for _, id := range found {
+		delete(ids, id)
+	}
+	for id := range ids {
+		return fmt.Errorf("product %s not found", id)
+	}
+	return nil
+}
+
+var errCompatibilityBatchTooLarge = errors.New("too many compatibility pairs in one request")
+
+// BulkUpdateCompatibility (admin) thêm và xoá nhiều cặp phụ kiện - thiết bị trong một transaction.
+// Cặp đã tồn tại được bỏ qua khi thêm.
+func BulkUpdateCompatibility(c *gin.Context) {
+	var input models.BulkCompatibilityInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid request payload", err.Error())
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+	if len(input.Add)+len(input.Remove) > maxCompatibilityBatch {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid request payload", errCompatibilityBatchTooLarge.Error())
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+
+	var added, removed int64
+	var validationErr error
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		additions, err := parseCompatibilityPairs(tx, input.Add)
+		if err != nil {
+			validationErr = err
+			return err
+		}
+
+		now := time.Now()
+		for i := range additions {
+			additions[i].CreatedAt = now
+		}
+		if len(additions) > 0 {
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&additions)
+			if result.Error != nil {
+				return result.Error
+			}
+			added = result.RowsAffected
+		}
+
+		for _, pair := range input.Remove {
+			result := tx.Where("accessory_id = ? AND device_id = ?", pair.AccessoryID, pair.DeviceID).
+				Delete(&models.ProductCompatibility{})
+			if result.Error != nil {
+				return result.Error
+			}
+			removed += result.RowsAffected
+		}
+		return nil
+	})
+	if validationErr != nil {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid compatibility pair", validationErr.Error())
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+	if err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to update compatibility", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"added": added, "removed": removed})
+}
+
+// SetCompatibleDevices (admin) thay thế toàn bộ danh sách thiết bị tương thích của phụ kiện :id
+func SetCompatibleDevices(c *gin.Context) {
+	accessoryID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid product id", err.Error())
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+
+	var input models.SetCompatibleDevicesInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid request payload", err.Error())
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+	if len(input.DeviceIDs) > maxCompatibilityBatch {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid request payload", errCompatibilityBatchTooLarge.Error())
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+
+	pairs := make([]models.CompatibilityPair, 0, len(input.DeviceIDs))
+	for _, deviceID := range input.DeviceIDs {
+		pairs = append(pairs, models.CompatibilityPair{AccessoryID: accessoryID.String(), DeviceID: deviceID})
+	}
+
+	var validationErr error
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := checkProductsExist(tx, map[uuid.UUID]bool{accessoryID: true}); err != nil {
+			validationErr = err
+			return err
+		}
+		records, err := parseCompatibilityPairs(tx, pairs)
+		if err != nil {
+			validationErr = err
+			return err
+		}
+
+		if err := tx.Where("accessory_id = ?", accessoryID).Delete(&models.ProductCompatibility{}).Error; err != nil {
+			return err
+		}
+		if len(records) == 0 {
+			return nil
+		}
+		now := time.Now()
+		for i := range records {
+			records[i].CreatedAt = now
+		}
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&records).Error
+	})
+	if validationErr != nil {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid compatibility pair", validationErr.Error())
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+	if err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to update compatibility", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	var deviceIDs []uuid.UUID
+	config.DB.Model(&models.ProductCompatibility{}).Where("accessory_id = ?", accessoryID).Pluck("device_id", &deviceIDs)
+	c.JSON(http.StatusOK, gin.H{"accessory_id": accessoryID, "device_ids": deviceIDs})
+}
+
+// GetProductAccessories trả về các phụ kiện đang bán dùng được với thiết bị :id (có phân trang và bộ lọc như GetProducts)
+func GetProductAccessories(c *gin.Context) {
+	deviceID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid product id", err.Error())
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+	listProducts(c, config.DB.Scopes(models.VisibleProducts, compatibleAccessories(deviceID)))
+}
+
+// GetCompatibleDevices trả về các thiết bị đang bán mà phụ kiện :id dùng được (có phân trang và bộ lọc như GetProducts)
+func GetCompatibleDevices(c *gin.Context) {
+	accessoryID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid product id", err.Error())
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+	listProducts(c, config.DB.Scopes(models.VisibleProducts, compatibleDevices(accessoryID)))
+}
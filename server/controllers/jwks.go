@@ -0,0 +1,16 @@
+package controllers
+
+import (
+	"net/http"
+
+	"ecommerce-project/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JWKS công bố các khoá công khai dùng để xác thực access token (RFC 7517),
+// các service khác dùng endpoint này để kiểm tra token mà không cần secret.
+func JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, gin.H{"keys": utils.PublicJWKS()})
+}
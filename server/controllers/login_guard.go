@@ -0,0 +1,213 @@
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"ecommerce-project/bruteforce"
+	"ecommerce-project/config"
+	"ecommerce-project/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+var (
+	loginGuardOnce sync.Once
+	loginGuard     *bruteforce.Guard
+	accountPolicy  bruteforce.Policy
+	ipPolicy       bruteforce.Policy
+)
+
+// getLoginGuard khởi tạo (một lần) bộ chống dò mật khẩu với cấu hình từ biến môi trường.
+// Bộ đếm mặc định nằm trong database (dùng chung giữa các instance); LOGIN_ATTEMPT_STORE=memory
+// giữ bộ đếm trong bộ nhớ, chỉ có hiệu lực trên một instance.
+func getLoginGuard() *bruteforce.Guard {
+	loginGuardOnce.Do(func() {
+		accountPolicy = bruteforce.Policy{
+			FreeAttempts:     config.GetEnvInt("LOGIN_ACCOUNT_FREE_ATTEMPTS", 3),
+			BaseDelay:        config.GetEnvDuration("LOGIN_BASE_DELAY", time.Second),
+			MaxDelay:         config.GetEnvDuration("LOGIN_MAX_DELAY", 5*time.Minute),
+			LockoutThreshold: config.GetEnvInt("LOGIN_ACCOUNT_LOCKOUT_THRESHOLD", 10),
+			LockoutDuration:  config.GetEnvDuration("LOGIN_ACCOUNT_LOCKOUT_DURATION", 15*time.Minute),
+			Window:           config.GetEnvDuration("LOGIN_ATTEMPT_WINDOW", time.Hour),
+		}
+		// Một IP có thể dùng chung cho nhiều người (NAT), nên ngưỡng cao hơn tài khoản
+		ipPolicy = bruteforce.Policy{
+			FreeAttempts:     config.GetEnvInt("LOGIN_IP_FREE_ATTEMPTS", 10),
+			BaseDelay:        config.GetEnvDuration("LOGIN_BASE_DELAY", time.Second),
+			MaxDelay:         config.GetEnvDuration("LOGIN_MAX_DELAY", 5*time.Minute),
+			LockoutThreshold: config.GetEnvInt("LOGIN_IP_LOCKOUT_THRESHOLD", 50),
+			LockoutDuration:  config.GetEnvDuration("LOGIN_IP_LOCKOUT_DURATION", time.Hour),
+			Window:           config.GetEnvDuration("LOGIN_ATTEMPT_WINDOW", time.Hour),
+		}
+		var store bruteforce.AttemptStore = bruteforce.NewDBStore(config.DB)
+		if config.GetEnvDefault("LOGIN_ATTEMPT_STORE", "database") == "memory" {
+			store = bruteforce.NewMemoryStore()
+		}
+		loginGuard = bruteforce.NewGuard(store)
+	})
+	return loginGuard
+}
+
+func accountAttemptKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipAttemptKey(ip string) string {
+	return "ip:" + ip
+}
+
+// checkLoginAllowed trả về false và phản hồi 429 (kèm Retry-After) khi tài khoản
+// hoặc IP đang phải chờ do đăng nhập sai nhiều lần.
+func checkLoginAllowed(c *gin.Context, email string) bool {
+	guard := getLoginGuard()
+
+	wait, err := guard.RetryAfter(accountAttemptKey(email), accountPolicy)
+	if err != nil {
+		log.Println("Login guard check failed:", err)
+	}
+	ipWait, err := guard.RetryAfter(ipAttemptKey(c.ClientIP()), ipPolicy)
+	if err != nil {
+		log.Println("Login guard check failed:", err)
+	}
+	if ipWait > wait {
+		wait = ipWait
+	}
+	if wait <= 0 {
+		return true
+	}
+
+	seconds := int(math.Ceil(wait.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":       "Too many failed login attempts, please try again later",
+		"retry_after": seconds,
+	})
+	return false
+}
+
+// recordLoginFailure tăng bộ đếm đăng nhập sai của tài khoản và IP,
+// ghi sự kiện bảo mật khi tài khoản hoặc IP bị khoá tạm thời.
+func recordLoginFailure(c *gin.Context, email string, userID *uuid.UUID) {
+	guard := getLoginGuard()
+	ip := c.ClientIP()
+
+	locked, err := guard.RecordFailure(accountAttemptKey(email), accountPolicy)
+	if err != nil {
+		log.Println("Login guard update failed:", err)
+	}
+	if locked {
+		recordSecurityEvent(models.SecurityEvent{
+			Type:      models.SecurityEventAccountLocked,
+			UserID:    userID,
+			Email:     email,
+			IPAddress: ip,
+			Details:   fmt.Sprintf("locked for %s after repeated failed logins", accountPolicy.LockoutDuration),
+		})
+	}
+
+	locked, err = guard.RecordFailure(ipAttemptKey(ip), ipPolicy)
+	if err != nil {
+		log.Println("Login guard update failed:", err)
+	}
+	if locked {
+		recordSecurityEvent(models.SecurityEvent{
+			Type:      models.SecurityEventIPLocked,
+			IPAddress: ip,
+			Details:   fmt.Sprintf("locked for %s after repeated failed logins", ipPolicy.LockoutDuration),
+		})
+	}
+}
+
+// recordLoginSuccess xoá bộ đếm của tài khoản. Bộ đếm theo IP được giữ nguyên để kẻ tấn công
+// không thể xoá nó bằng cách đăng nhập vào tài khoản của chính mình.
+func recordLoginSuccess(email string) {
+	if err := getLoginGuard().Reset(accountAttemptKey(email)); err != nil {
+		log.Println("Login guard reset failed:", err)
+	}
+}
+
+// recordSecurityEvent lưu sự kiện bảo mật, lỗi chỉ được ghi log để không chặn luồng đăng nhập
+func recordSecurityEvent(event models.SecurityEvent) {
+	event.ID = uuid.New()
+	log.Printf("Security event %s: user=%v email=%s ip=%s %s", event.Type, event.UserID, event.Email, event.IPAddress, event.Details)
+	if err := config.DB.Create(&event).Error; err != nil {
+		log.Println("Failed to record security event:", err)
+	}
+}
+
+// currentActorID trả về id của người dùng đang thực hiện request (admin hoặc nhân viên)
+func currentActorID(c *gin.Context) *uuid.UUID {
+	if actorID, ok := c.Get("userID"); ok {
+		if id, ok := actorID.(uuid.UUID); ok {
+			return &id
+		}
+	}
+	return nil
+}
+
+// UnlockUser (admin) xoá khoá tạm thời và bộ đếm đăng nhập sai của một tài khoản.
+func UnlockUser(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid user id", err.Error())
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, "id = ?", userID).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusNotFound, "User not found", err.Error())
+		c.JSON(http.StatusNotFound, errResp)
+		return
+	}
+
+	if err := getLoginGuard().Reset(accountAttemptKey(user.Email)); err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to unlock user", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	event := models.SecurityEvent{
+		Type:      models.SecurityEventAccountUnlocked,
+		UserID:    &user.ID,
+		Email:     user.Email,
+		IPAddress: c.ClientIP(),
+	}
+	event.ActorID = currentActorID(c)
+	recordSecurityEvent(event)
+
+	c.JSON(http.StatusOK, gin.H{"message": "User unlocked successfully"})
+}
+
+// GetSecurityEvents (admin) liệt kê các sự kiện bảo mật mới nhất, có thể lọc theo user_id và type.
+func GetSecurityEvents(c *gin.Context) {
+	query := config.DB.Model(&models.SecurityEvent{})
+	if userID := c.Query("user_id"); userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+	if eventType := c.Query("type"); eventType != "" {
+		query = query.Where("type = ?", eventType)
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit <= 0 || limit > 500 {
+		limit = 100
+	}
+
+	var events []models.SecurityEvent
+	if err := query.Order("created_at DESC").Limit(limit).Find(&events).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to fetch security events", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	c.JSON(http.StatusOK, events)
+}
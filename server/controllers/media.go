@@ -1,34 +1,376 @@
 package controllers
 
 import (
-	"ecommerce-project/config"
-	"fmt"
+	"errors"
+	"io"
+	"log"
+	"math"
 	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"ecommerce-project/config"
+	"ecommerce-project/media"
+	"ecommerce-project/models"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
-func UploadImage(c *gin.Context) {
+// mediaExtensions là đuôi file của ảnh gốc theo định dạng đã xác định từ nội dung
+var mediaExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+// mediaLimits đọc giới hạn upload từ MEDIA_MAX_UPLOAD_SIZE (byte) và MEDIA_MAX_PIXELS.
+// Ảnh được giải mã toàn bộ khi tạo bản thu nhỏ (khoảng 4 byte mỗi điểm ảnh), nên mặc định
+// 16 triệu điểm ảnh giữ bộ nhớ cho mỗi lần upload ở mức khoảng 64MB.
+func mediaLimits() media.Limits {
+	return media.Limits{
+		MaxSize:   int64(config.GetEnvInt("MEDIA_MAX_UPLOAD_SIZE", 10<<20)),
+		MaxPixels: config.GetEnvInt("MEDIA_MAX_PIXELS", 16_000_000),
+	}
+}
+
+// mediaOriginalKey là đường dẫn của ảnh gốc trong storage
+func mediaOriginalKey(id uuid.UUID, contentType string) string {
+	return "media/" + id.String() + "/original" + mediaExtensions[contentType]
+}
+
+// deleteStorageKeys xoá các file khỏi storage, lỗi chỉ được ghi log
+func deleteStorageKeys(stor config.Storage, keys ...string) {
+	for _, key := range keys {
+		if err := stor.DeleteFile(key); err != nil {
+			log.Println("Failed to delete file:", err)
+		}
+	}
+}
+
+// findMediaByChecksum tìm media đã có cùng nội dung
+func findMediaByChecksum(checksum string) (models.Media, bool) {
+	var record models.Media
+	err := config.DB.Where("checksum = ?", checksum).First(&record).Error
+	return record, err == nil
+}
+
+// storeMedia kiểm tra ảnh, upload ảnh gốc cùng các bản thu nhỏ và tạo bản ghi Media. File được đọc
+// theo dạng stream (kiểm tra, upload, tạo bản thu nhỏ) nên không bị giữ toàn bộ trong bộ nhớ.
+// Ảnh trùng nội dung (cùng checksum) với media đã có thì trả về media đó (existing = true).
+func storeMedia(ownerID uuid.UUID, filename string, file io.ReadSeeker, altText string) (models.Media, bool, error) {
+	info, err := media.Inspect(file, mediaLimits())
+	if err != nil {
+		return models.Media{}, false, err
+	}
+	if record, ok := findMediaByChecksum(info.Checksum); ok {
+		return record, true, nil
+	}
+
+	stor, err := config.GetStorage()
+	if err != nil {
+		return models.Media{}, false, err
+	}
+
+	id := uuid.New()
+	originalKey := mediaOriginalKey(id, info.ContentType)
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return models.Media{}, false, err
+	}
+	if _, err := stor.UploadStream(originalKey, file, info.Size, info.ContentType); err != nil {
+		return models.Media{}, false, err
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		deleteStorageKeys(stor, originalKey)
+		return models.Media{}, false, err
+	}
+	record, existing, err := registerMedia(stor, id, ownerID, filename, originalKey, info, file, altText, nil)
+	if err != nil || existing {
+		deleteStorageKeys(stor, originalKey)
+	}
+	return record, existing, err
+}
+
+// registerMedia tạo các bản thu nhỏ từ ảnh gốc (đã nằm trong storage tại originalKey, nội dung đọc từ source)
+// và lưu bản ghi Media. Nếu thất bại, các bản thu nhỏ đã upload được xoá; ảnh gốc do bên gọi xử lý.
+// Khi một upload đồng thời đã lưu ảnh cùng checksum trước (vi phạm unique index), media đó được
+// trả về với existing = true và bên gọi xoá ảnh gốc vừa upload.
+// link (nếu có) được gọi với media trả về, trong cùng transaction với việc tạo bản ghi Media.
+func registerMedia(stor config.Storage, id, ownerID uuid.UUID, filename, originalKey string, info media.Info, source io.Reader, altText string, link func(tx *gorm.DB, record models.Media) error) (models.Media, bool, error) {
+	var record models.Media
+
+	renditions, err := media.Renditions(source)
+	if err != nil {
+		return record, false, err
+	}
+
+	var uploaded []string
+	stored := map[string]models.MediaRendition{}
+	for _, rendition := range renditions {
+		key := "media/" + id.String() + "/" + rendition.Name + ".jpg"
+		renditionURL, err := stor.UploadBytes(key, rendition.Data, rendition.ContentType)
+		if err != nil {
+			deleteStorageKeys(stor, uploaded...)
+			return record, false, err
+		}
+		uploaded = append(uploaded, key)
+		stored[rendition.Name] = models.MediaRendition{
+			URL:         renditionURL,
+			StorageKey:  key,
+			ContentType: rendition.ContentType,
+			Width:       rendition.Width,
+			Height:      rendition.Height,
+			Size:        int64(len(rendition.Data)),
+		}
+	}
+
+	now := time.Now()
+	record = models.Media{
+		ID:          id,
+		OwnerID:     ownerID,
+		Filename:    filepath.Base(filename),
+		ContentType: info.ContentType,
+		Size:        info.Size,
+		Width:       info.Width,
+		Height:      info.Height,
+		Checksum:    info.Checksum,
+		AltText:     altText,
+		StorageKey:  originalKey,
+		URL:         stor.FileURL(originalKey),
+		Renditions:  stored,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&record).Error; err != nil {
+			return err
+		}
+		if link != nil {
+			return link(tx, record)
+		}
+		return nil
+	})
+	if err != nil {
+		deleteStorageKeys(stor, uploaded...)
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			if existing, ok := findMediaByChecksum(info.Checksum); ok {
+				if link != nil {
+					if err := link(config.DB, existing); err != nil {
+						return models.Media{}, false, err
+					}
+				}
+				return existing, true, nil
+			}
+		}
+		return models.Media{}, false, err
+	}
+	return record, false, nil
+}
+
+// respondMediaError trả về mã lỗi phù hợp cho lỗi kiểm tra ảnh, false nếu không phải lỗi kiểm tra
+func respondMediaError(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, media.ErrTooLarge):
+		errResp := models.NewErrorResponse(http.StatusRequestEntityTooLarge, "File is too large", err.Error())
+		c.JSON(http.StatusRequestEntityTooLarge, errResp)
+	case errors.Is(err, media.ErrUnsupportedType):
+		errResp := models.NewErrorResponse(http.StatusUnsupportedMediaType, "Unsupported file type", err.Error())
+		c.JSON(http.StatusUnsupportedMediaType, errResp)
+	default:
+		return false
+	}
+	return true
+}
+
+// uploadFormMedia lưu file trong trường "file" của form multipart vào thư viện media
+func uploadFormMedia(c *gin.Context) (models.Media, bool, bool) {
 	file, err := c.FormFile("file")
-    if err != nil {
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error message": "No image uploaded"})
+		return models.Media{}, false, false
+	}
+	if file.Size > mediaLimits().MaxSize {
+		respondMediaError(c, media.ErrTooLarge)
+		return models.Media{}, false, false
+	}
+
+	src, err := file.Open()
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error message": "No image uploaded"})
+		return models.Media{}, false, false
+	}
+	defer src.Close()
+
+	ownerID, _ := c.Get("userID")
+	record, existing, err := storeMedia(ownerID.(uuid.UUID), file.Filename, src, c.PostForm("alt_text"))
+	if err != nil {
+		if !respondMediaError(c, err) {
+			errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to upload image", err.Error())
+			c.JSON(http.StatusInternalServerError, errResp)
+		}
+		return record, false, false
+	}
+	return record, existing, true
+}
+
+// UploadImage upload ảnh qua thư viện media và trả về URL ảnh gốc (giữ cho client cũ)
+func UploadImage(c *gin.Context) {
+	record, _, ok := uploadFormMedia(c)
+	if !ok {
 		return
-    }
+	}
 
-	stor, err := config.GetStorage()
-    if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error message": "Failed to get storage client"})
+	c.JSON(http.StatusOK, gin.H{"url": record.URL, "media": record})
+}
+
+// UploadMedia upload ảnh vào thư viện media (trường "file", tuỳ chọn "alt_text").
+// Nội dung phải là ảnh JPEG, PNG hoặc GIF trong giới hạn dung lượng; các bản thu nhỏ
+// thumbnail, medium và large được tạo tự động.
+func UploadMedia(c *gin.Context) {
+	record, existing, ok := uploadFormMedia(c)
+	if !ok {
 		return
-    }
+	}
 
-	url, err := stor.UploadFile(file)
-	fmt.Println("url:",url)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error message": "Failed to upload image"})
+	status := http.StatusCreated
+	if existing {
+		status = http.StatusOK
+	}
+	c.JSON(status, record)
+}
+
+// GetMediaList trả về thư viện media (có phân trang), lọc theo tên file (q) và người upload (owner_id)
+func GetMediaList(c *gin.Context) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if err != nil || pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+	offset := (page - 1) * pageSize
+
+	query := config.DB.Model(&models.Media{})
+	if q := c.Query("q"); q != "" {
+		query = query.Where("filename ILIKE ? OR alt_text ILIKE ?", "%"+q+"%", "%"+q+"%")
+	}
+	if ownerID := c.Query("owner_id"); ownerID != "" {
+		query = query.Where("owner_id = ?", ownerID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to count media", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	var items []models.Media
+	if err := query.Order("created_at DESC").Offset(offset).Limit(pageSize).Find(&items).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to fetch media", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	totalPages := int(math.Ceil(float64(total) / float64(pageSize)))
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": items,
+		"pagination": gin.H{
+			"total":       total,
+			"page":        page,
+			"page_size":   pageSize,
+			"total_pages": totalPages,
+		},
+	})
+}
+
+// GetMedia trả về chi tiết một media
+func GetMedia(c *gin.Context) {
+	var record models.Media
+	if err := config.DB.First(&record, "id = ?", c.Param("id")).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusNotFound, "Media not found", err.Error())
+		c.JSON(http.StatusNotFound, errResp)
+		return
+	}
+
+	c.JSON(http.StatusOK, record)
+}
+
+// UpdateMedia cập nhật alt text của media
+func UpdateMedia(c *gin.Context) {
+	var record models.Media
+	if err := config.DB.First(&record, "id = ?", c.Param("id")).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusNotFound, "Media not found", err.Error())
+		c.JSON(http.StatusNotFound, errResp)
+		return
+	}
+
+	var input models.UpdateMediaInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid request payload", err.Error())
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+
+	if input.AltText != nil {
+		record.AltText = *input.AltText
+	}
+	record.UpdatedAt = time.Now()
+
+	if err := config.DB.Model(&record).Select("alt_text", "updated_at").Updates(&record).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to update media", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	c.JSON(http.StatusOK, record)
+}
+
+// DeleteMedia xoá media cùng các file trong storage. Media đang được dùng làm ảnh sản phẩm không thể xoá.
+func DeleteMedia(c *gin.Context) {
+	var record models.Media
+	if err := config.DB.First(&record, "id = ?", c.Param("id")).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusNotFound, "Media not found", err.Error())
+		c.JSON(http.StatusNotFound, errResp)
+		return
+	}
+
+	var usage int64
+	if err := config.DB.Model(&models.ProductImage{}).Where("media_id = ?", record.ID).Count(&usage).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to check media usage", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+	if usage > 0 {
+		errResp := models.NewErrorResponse(http.StatusConflict, "Media is used by products")
+		c.JSON(http.StatusConflict, errResp)
+		return
+	}
+
+	if err := config.DB.Delete(&record).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to delete media", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"url": url})
+	// File trong storage được xoá sau khi bản ghi đã xoá, lỗi chỉ được ghi log
+	stor, err := config.GetStorage()
+	if err != nil {
+		log.Println("Failed to get storage client:", err)
+	} else {
+		keys := []string{record.StorageKey}
+		for _, rendition := range record.Renditions {
+			keys = append(keys, rendition.StorageKey)
+		}
+		deleteStorageKeys(stor, keys...)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Media deleted successfully"})
 }
 
 func DeleteImage(c *gin.Context) {
@@ -56,4 +398,4 @@ func DeleteImage(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Image deleted successfully"})
-}
\ No newline at end of file
+}
@@ -0,0 +1,229 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"ecommerce-project/config"
+	"ecommerce-project/media"
+	"ecommerce-project/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Thời gian client được phép gọi hoàn tất sau khi URL upload hết hạn
+const mediaUploadCompleteWindow = time.Hour
+
+var (
+	errMediaUploadInProgress   = errors.New("upload is already being completed")
+	errMediaUploadSizeMismatch = errors.New("uploaded file size does not match the declared size")
+)
+
+// mediaUploadKey là đường dẫn tạm trong storage mà client upload trực tiếp lên. File chỉ được
+// sao chép sang vị trí của media (mediaOriginalKey) sau khi đã được kiểm tra.
+func mediaUploadKey(id uuid.UUID) string {
+	return "uploads/" + id.String()
+}
+
+// CreateMediaUpload cấp URL được ký trước (hạn dùng MEDIA_UPLOAD_URL_TTL, mặc định 15 phút) để client
+// upload ảnh trực tiếp lên khu vực tạm của storage mà không đi qua server. URL chỉ nhận đúng dung lượng
+// đã khai báo (trừ Supabase, dung lượng được kiểm tra lại khi hoàn tất). Sau khi upload xong, client gọi
+// CompleteMediaUpload để server kiểm tra file và thêm vào thư viện media.
+func CreateMediaUpload(c *gin.Context) {
+	var input models.CreateMediaUploadInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid request payload", err.Error())
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+	if !media.Supported(input.ContentType) {
+		respondMediaError(c, media.ErrUnsupportedType)
+		return
+	}
+	if input.Size > mediaLimits().MaxSize {
+		respondMediaError(c, media.ErrTooLarge)
+		return
+	}
+
+	stor, err := config.GetStorage()
+	if err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to get storage client", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	id := uuid.New()
+	key := mediaUploadKey(id)
+	presigned, err := stor.PresignUpload(key, input.ContentType, input.Size, config.GetEnvDuration("MEDIA_UPLOAD_URL_TTL", 15*time.Minute))
+	if err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to create upload url", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	ownerID, _ := c.Get("userID")
+	upload := models.MediaUpload{
+		ID:          id,
+		OwnerID:     ownerID.(uuid.UUID),
+		Filename:    filepath.Base(input.Filename),
+		ContentType: input.ContentType,
+		Size:        input.Size,
+		StorageKey:  key,
+		ExpiresAt:   presigned.ExpiresAt.Add(mediaUploadCompleteWindow),
+		CreatedAt:   time.Now(),
+	}
+	if err := config.DB.Create(&upload).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to create upload", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"upload": upload, "request": presigned})
+}
+
+// CompleteMediaUpload kiểm tra file đã được upload trực tiếp (định dạng theo nội dung, dung lượng so với
+// dung lượng đã khai báo, số điểm ảnh), tạo các bản thu nhỏ và thêm vào thư viện media. File không hợp lệ
+// bị xoá khỏi storage. Gọi lại sau khi đã hoàn tất trả về media đã tạo.
+func CompleteMediaUpload(c *gin.Context) {
+	var input models.CompleteMediaUploadInput
+	if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid request payload", err.Error())
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+
+	ownerID, _ := c.Get("userID")
+	var upload models.MediaUpload
+	if err := config.DB.First(&upload, "id = ? AND owner_id = ?", c.Param("id"), ownerID).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusNotFound, "Upload not found", err.Error())
+		c.JSON(http.StatusNotFound, errResp)
+		return
+	}
+
+	if upload.MediaID != nil {
+		var record models.Media
+		if err := config.DB.First(&record, "id = ?", *upload.MediaID).Error; err != nil {
+			errResp := models.NewErrorResponse(http.StatusNotFound, "Media not found", err.Error())
+			c.JSON(http.StatusNotFound, errResp)
+			return
+		}
+		c.JSON(http.StatusOK, record)
+		return
+	}
+	if time.Now().After(upload.ExpiresAt) {
+		errResp := models.NewErrorResponse(http.StatusGone, "Upload has expired")
+		c.JSON(http.StatusGone, errResp)
+		return
+	}
+
+	// Đánh dấu lượt upload đang được xử lý để hai lần gọi đồng thời không cùng tạo media
+	claim := config.DB.Model(&models.MediaUpload{}).
+		Where("id = ? AND completed_at IS NULL", upload.ID).
+		Update("completed_at", time.Now())
+	if claim.Error != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to complete upload", claim.Error.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+	if claim.RowsAffected == 0 {
+		errResp := models.NewErrorResponse(http.StatusConflict, "Failed to complete upload", errMediaUploadInProgress.Error())
+		c.JSON(http.StatusConflict, errResp)
+		return
+	}
+
+	record, existing, err := verifyMediaUpload(upload, input.AltText)
+	if err != nil {
+		// Trả lại trạng thái chưa hoàn tất để client có thể upload lại và gọi hoàn tất lần nữa
+		if resetErr := config.DB.Model(&models.MediaUpload{}).Where("id = ?", upload.ID).Update("completed_at", nil).Error; resetErr != nil {
+			errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to complete upload", errors.Join(err, resetErr).Error())
+			c.JSON(http.StatusInternalServerError, errResp)
+			return
+		}
+
+		switch {
+		case errors.Is(err, config.ErrObjectNotFound):
+			errResp := models.NewErrorResponse(http.StatusConflict, "Uploaded file not found", err.Error())
+			c.JSON(http.StatusConflict, errResp)
+		case errors.Is(err, errMediaUploadSizeMismatch):
+			errResp := models.NewErrorResponse(http.StatusBadRequest, "Uploaded file size does not match", err.Error())
+			c.JSON(http.StatusBadRequest, errResp)
+		case !respondMediaError(c, err):
+			errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to complete upload", err.Error())
+			c.JSON(http.StatusInternalServerError, errResp)
+		}
+		return
+	}
+
+	status := http.StatusCreated
+	if existing {
+		status = http.StatusOK
+	}
+	c.JSON(status, record)
+}
+
+// verifyMediaUpload đọc file đã upload từ khu vực tạm (dạng stream) để kiểm tra và tạo media; media_id của
+// lượt upload được gán trong cùng transaction với việc tạo media. File không phải ảnh hợp lệ, khác định dạng
+// hoặc dung lượng đã khai báo bị xoá. File tạm chỉ bị xoá sau khi media đã được tạo, để client có thể gọi
+// hoàn tất lại nếu một bước ở giữa thất bại.
+func verifyMediaUpload(upload models.MediaUpload, altText string) (models.Media, bool, error) {
+	stor, err := config.GetStorage()
+	if err != nil {
+		return models.Media{}, false, err
+	}
+
+	content, err := stor.OpenFile(upload.StorageKey)
+	if err != nil {
+		return models.Media{}, false, err
+	}
+	info, err := media.Inspect(content, mediaLimits())
+	content.Close()
+	if err == nil && info.ContentType != upload.ContentType {
+		err = fmt.Errorf("%w: uploaded file is %s, not %s", media.ErrUnsupportedType, info.ContentType, upload.ContentType)
+	}
+	if err == nil && info.Size != upload.Size {
+		err = fmt.Errorf("%w: got %d bytes, declared %d", errMediaUploadSizeMismatch, info.Size, upload.Size)
+	}
+	if err != nil {
+		if errors.Is(err, media.ErrTooLarge) || errors.Is(err, media.ErrUnsupportedType) || errors.Is(err, errMediaUploadSizeMismatch) {
+			deleteStorageKeys(stor, upload.StorageKey)
+		}
+		return models.Media{}, false, err
+	}
+
+	link := func(tx *gorm.DB, record models.Media) error {
+		return tx.Model(&models.MediaUpload{}).Where("id = ?", upload.ID).Update("media_id", record.ID).Error
+	}
+	if record, ok := findMediaByChecksum(info.Checksum); ok {
+		if err := link(config.DB, record); err != nil {
+			return models.Media{}, false, err
+		}
+		deleteStorageKeys(stor, upload.StorageKey)
+		return record, true, nil
+	}
+
+	originalKey := mediaOriginalKey(upload.ID, info.ContentType)
+	if err := stor.CopyFile(upload.StorageKey, originalKey); err != nil {
+		return models.Media{}, false, err
+	}
+	content, err = stor.OpenFile(originalKey)
+	if err != nil {
+		deleteStorageKeys(stor, originalKey)
+		return models.Media{}, false, err
+	}
+	defer content.Close()
+
+	record, existing, err := registerMedia(stor, upload.ID, upload.OwnerID, upload.Filename, originalKey, info, content, altText, link)
+	if err != nil || existing {
+		deleteStorageKeys(stor, originalKey)
+	}
+	if err == nil {
+		deleteStorageKeys(stor, upload.StorageKey)
+	}
+	return record, existing, err
+}
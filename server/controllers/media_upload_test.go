@@ -0,0 +1,291 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"ecommerce-project/config"
+	"ecommerce-project/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// mediaUploadRouter gắn các handler upload trực tiếp vào router, request được coi như của ownerID
+func mediaUploadRouter(ownerID uuid.UUID) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("userID", ownerID)
+		c.Next()
+	})
+	router.POST("/media/uploads", CreateMediaUpload)
+	router.POST("/media/uploads/:id/complete", CompleteMediaUpload)
+	return router
+}
+
+// postJSON gửi body dạng JSON tới router và đọc phản hồi vào out (nếu khác nil)
+func postJSON(t *testing.T, router http.Handler, path string, body interface{}, out interface{}) int {
+	t.Helper()
+	payload, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, path, bytes.NewReader(payload)))
+	if out != nil && recorder.Code < 300 {
+		if err := json.Unmarshal(recorder.Body.Bytes(), out); err != nil {
+			t.Fatalf("POST %s: %v: %s", path, err, recorder.Body)
+		}
+	}
+	return recorder.Code
+}
+
+func TestCreateMediaUploadRejectsInvalidInput(t *testing.T) {
+	router := mediaUploadRouter(uuid.New())
+	tests := []struct {
+		name  string
+		input gin.H
+		want  int
+	}{
+		{name: "missing size", input: gin.H{"filename": "a.png", "content_type": "image/png"}, want: http.StatusBadRequest},
+		{name: "unsupported type", input: gin.H{"filename": "a.webp", "content_type": "image/webp", "size": 100}, want: http.StatusUnsupportedMediaType},
+		{name: "too large", input: gin.H{"filename": "a.png", "content_type": "image/png", "size": 1 << 40}, want: http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status := postJSON(t, router, "/media/uploads", tt.input, nil); status != tt.want {
+				t.Fatalf("got status %d, want %d", status, tt.want)
+			}
+		})
+	}
+}
+
+var mediaTestStorage struct {
+	once  sync.Once
+	local *config.LocalStorage
+}
+
+// setupMediaUploadTest dùng Postgres ở MEDIA_TEST_DATABASE_DSN và storage cục bộ phục vụ qua httptest.
+// Storage được khởi tạo một lần (config.GetStorage) và dùng chung cho các kiểm thử.
+func setupMediaUploadTest(t *testing.T) (uuid.UUID, *config.LocalStorage) {
+	t.Helper()
+	dsn := os.Getenv("MEDIA_TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("MEDIA_TEST_DATABASE_DSN is not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{TranslateError: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.Media{}, &models.MediaUpload{}); err != nil {
+		t.Fatal(err)
+	}
+	config.DB = db
+
+	mediaTestStorage.once.Do(func() {
+		mux := http.NewServeMux()
+		server := httptest.NewServer(mux)
+		dir, err := os.MkdirTemp("", "media-upload-test-")
+		if err != nil {
+			return
+		}
+		os.Setenv("STORAGE_BACKEND", config.StorageBackendLocal)
+		os.Setenv("LOCAL_STORAGE_DIR", dir)
+		os.Setenv("LOCAL_STORAGE_PUBLIC_URL", server.URL)
+		if local, ok := config.LocalFileServer(); ok {
+			mux.Handle(local.URLPath()+"/", local.Handler())
+			mediaTestStorage.local = local
+		}
+	})
+	if mediaTestStorage.local == nil {
+		t.Fatal("local storage is not available")
+	}
+
+	ownerID := uuid.New()
+	t.Cleanup(func() {
+		db.Where("owner_id = ?", ownerID).Delete(&models.MediaUpload{})
+		db.Where("owner_id = ?", ownerID).Delete(&models.Media{})
+	})
+	return ownerID, mediaTestStorage.local
+}
+
+// testPNG tạo ảnh PNG có nội dung riêng theo seed để không trùng checksum với ảnh của kiểm thử khác
+func testPNG(t *testing.T, seed uuid.UUID) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 64, 48))
+	for i := range img.Pix {
+		img.Pix[i] = seed[i%len(seed)]
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+type createdUpload struct {
+	Upload  models.MediaUpload     `json:"upload"`
+	Request config.PresignedUpload `json:"request"`
+}
+
+// createUpload tạo lượt upload với dung lượng khai báo size
+func createUpload(t *testing.T, router http.Handler, size int) createdUpload {
+	t.Helper()
+	var created createdUpload
+	input := gin.H{"filename": "photo.png", "content_type": "image/png", "size": size}
+	if status := postJSON(t, router, "/media/uploads", input, &created); status != http.StatusCreated {
+		t.Fatalf("create upload: got status %d, want 201", status)
+	}
+	return created
+}
+
+// sendUpload gửi nội dung theo request đã được ký, như client upload trực tiếp lên storage
+func sendUpload(t *testing.T, request config.PresignedUpload, content []byte) {
+	t.Helper()
+	req, err := http.NewRequest(request.Method, request.URL, bytes.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, value := range request.Headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("upload: got status %d, want 200", resp.StatusCode)
+	}
+}
+
+// expectMissing kiểm tra key không còn trong storage
+func expectMissing(t *testing.T, stor config.Storage, key string) {
+	t.Helper()
+	file, err := stor.OpenFile(key)
+	if err == nil {
+		file.Close()
+		t.Fatalf("%s still exists", key)
+	}
+	if !errors.Is(err, config.ErrObjectNotFound) {
+		t.Fatal(err)
+	}
+}
+
+func TestMediaUploadComplete(t *testing.T) {
+	ownerID, stor := setupMediaUploadTest(t)
+	router := mediaUploadRouter(ownerID)
+	content := testPNG(t, ownerID)
+
+	created := createUpload(t, router, len(content))
+	stagingKey := mediaUploadKey(created.Upload.ID)
+	if !strings.Contains(created.Request.URL, "/"+stagingKey+"?") {
+		t.Fatalf("upload url %s does not target the staging key %s", created.Request.URL, stagingKey)
+	}
+	sendUpload(t, created.Request, content)
+
+	completePath := "/media/uploads/" + created.Upload.ID.String() + "/complete"
+	var record models.Media
+	if status := postJSON(t, router, completePath, gin.H{"alt_text": "photo"}, &record); status != http.StatusCreated {
+		t.Fatalf("complete: got status %d, want 201", status)
+	}
+	if record.ID != created.Upload.ID || record.Size != int64(len(content)) || record.AltText != "photo" {
+		t.Fatalf("got media %+v", record)
+	}
+	if _, ok := record.Renditions["thumbnail"]; !ok {
+		t.Fatalf("media has no thumbnail: %+v", record.Renditions)
+	}
+
+	var upload models.MediaUpload
+	if err := config.DB.First(&upload, "id = ?", created.Upload.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if upload.MediaID == nil || *upload.MediaID != record.ID || upload.CompletedAt == nil {
+		t.Fatalf("got upload %+v", upload)
+	}
+	expectMissing(t, stor, stagingKey)
+	file, err := stor.OpenFile(mediaOriginalKey(record.ID, "image/png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	// Gọi hoàn tất lần nữa trả về media đã tạo
+	var again models.Media
+	if status := postJSON(t, router, completePath, gin.H{}, &again); status != http.StatusOK || again.ID != record.ID {
+		t.Fatalf("complete again: got status %d, media %s", status, again.ID)
+	}
+
+	// Cùng nội dung upload lần nữa dùng lại media đã có và xoá file tạm
+	duplicate := createUpload(t, router, len(content))
+	sendUpload(t, duplicate.Request, content)
+	var existing models.Media
+	if status := postJSON(t, router, "/media/uploads/"+duplicate.Upload.ID.String()+"/complete", gin.H{}, &existing); status != http.StatusOK {
+		t.Fatalf("complete duplicate: got status %d, want 200", status)
+	}
+	if existing.ID != record.ID {
+		t.Fatalf("duplicate upload created media %s, want %s", existing.ID, record.ID)
+	}
+	expectMissing(t, stor, mediaUploadKey(duplicate.Upload.ID))
+}
+
+func TestMediaUploadCompleteRejectsSizeMismatch(t *testing.T) {
+	ownerID, stor := setupMediaUploadTest(t)
+	router := mediaUploadRouter(ownerID)
+	content := testPNG(t, ownerID)
+
+	// URL cục bộ chấp nhận nội dung ngắn hơn dung lượng đã ký; việc hoàn tất phải phát hiện
+	created := createUpload(t, router, len(content)+10)
+	sendUpload(t, created.Request, content)
+
+	status := postJSON(t, router, "/media/uploads/"+created.Upload.ID.String()+"/complete", gin.H{}, nil)
+	if status != http.StatusBadRequest {
+		t.Fatalf("got status %d, want 400", status)
+	}
+	expectMissing(t, stor, mediaUploadKey(created.Upload.ID))
+
+	var upload models.MediaUpload
+	if err := config.DB.First(&upload, "id = ?", created.Upload.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if upload.MediaID != nil || upload.CompletedAt != nil {
+		t.Fatalf("got upload %+v, want it reset to not completed", upload)
+	}
+}
+
+func TestMediaUploadCompleteWithoutFile(t *testing.T) {
+	ownerID, _ := setupMediaUploadTest(t)
+	router := mediaUploadRouter(ownerID)
+
+	created := createUpload(t, router, 1024)
+	status := postJSON(t, router, "/media/uploads/"+created.Upload.ID.String()+"/complete", gin.H{}, nil)
+	if status != http.StatusConflict {
+		t.Fatalf("got status %d, want 409", status)
+	}
+
+	var upload models.MediaUpload
+	if err := config.DB.First(&upload, "id = ?", created.Upload.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if upload.CompletedAt != nil {
+		t.Fatal("upload is still marked as being completed")
+	}
+
+	// Lượt upload của người khác không được hoàn tất
+	other := mediaUploadRouter(uuid.New())
+	if status := postJSON(t, other, "/media/uploads/"+created.Upload.ID.String()+"/complete", gin.H{}, nil); status != http.StatusNotFound {
+		t.Fatalf("complete by another user: got status %d, want 404", status)
+	}
+}
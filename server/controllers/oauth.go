@@ -0,0 +1,438 @@
+package controllers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"ecommerce-project/config"
+	"ecommerce-project/models"
+	"ecommerce-project/notification"
+	"ecommerce-project/oauth"
+	"ecommerce-project/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errInvalidOAuthState    = errors.New("invalid or expired oauth state")
+	errOAuthEmailUnverified = errors.New("provider did not return a verified email")
+	errOAuthEmailTaken      = errors.New("an account with this email already exists")
+	errIdentityLinked       = errors.New("identity is linked to another account")
+	errOAuthLinkUnavailable = errors.New("account to link is disabled or deleted")
+)
+
+// Thời gian người dùng có để hoàn tất đăng nhập ở trang của provider
+const oauthStateTTL = 10 * time.Minute
+
+const (
+	oauthStateCookie     = "oauth_state"
+	oauthStateCookiePath = "/api"
+)
+
+// GetOAuthProviders trả về danh sách provider đăng nhập mạng xã hội đã được cấu hình
+func GetOAuthProviders(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"providers": oauth.ProviderNames()})
+}
+
+// StartOAuthLogin tạo state, nonce và PKCE code verifier rồi trả về URL đăng nhập của provider.
+// Provider chuyển hướng người dùng về REDIRECT_URL (trang của client) kèm code và state,
+// client gửi tiếp hai giá trị này tới OAuthCallback.
+func StartOAuthLogin(c *gin.Context) {
+	startOAuth(c, nil)
+}
+
+// StartOAuthLink bắt đầu liên kết provider vào tài khoản đang đăng nhập. Callback dùng chung
+// OAuthCallback; danh tính chỉ được liên kết qua luồng này, không tự liên kết theo email.
+func StartOAuthLink(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	id := userID.(uuid.UUID)
+	startOAuth(c, &id)
+}
+
+// startOAuth lưu state (kèm userID với luồng liên kết) và gắn state vào trình duyệt bằng
+// cookie HttpOnly để callback chỉ chấp nhận state do chính trình duyệt đó khởi tạo.
+func startOAuth(c *gin.Context, userID *uuid.UUID) {
+	provider, err := oauth.GetProvider(c.Param("provider"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown login provider"})
+		return
+	}
+
+	state, err := oauth.RandomString(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+	nonce, err := oauth.RandomString(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+	codeVerifier, err := oauth.RandomString(48)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+
+	authorizationURL, err := provider.AuthorizationURL(c.Request.Context(), state, nonce, codeVerifier)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Login provider is unavailable"})
+		return
+	}
+
+	// Dọn các state đã hết hạn
+	now := time.Now()
+	config.DB.Where("expires_at < ?", now).Delete(&models.OAuthState{})
+
+	record := models.OAuthState{
+		ID:           uuid.New(),
+		StateHash:    utils.HashToken(state),
+		Provider:     provider.Name,
+		UserID:       userID,
+		Nonce:        nonce,
+		CodeVerifier: codeVerifier,
+		ExpiresAt:    now.Add(oauthStateTTL),
+		CreatedAt:    now,
+	}
+	if err := config.DB.Create(&record).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+
+	setOAuthStateCookie(c, state, int(oauthStateTTL.Seconds()))
+	c.JSON(http.StatusOK, gin.H{"authorization_url": authorizationURL})
+}
+
+// setOAuthStateCookie ghi (hoặc xoá khi maxAge < 0) cookie chứa state, chỉ gửi kèm request dưới /api
+func setOAuthStateCookie(c *gin.Context, state string, maxAge int) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oauthStateCookie, state, maxAge, oauthStateCookiePath, "", config.SecureCookies(), true)
+}
+
+// consumeOAuthState kiểm tra state còn hạn, đúng provider và xoá nó để không dùng lại được
+func consumeOAuthState(tx *gorm.DB, providerName, state string) (models.OAuthState, error) {
+	var record models.OAuthState
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("state_hash = ? AND provider = ?", utils.HashToken(state), providerName).
+		First(&record).Error
+	if err == gorm.ErrRecordNotFound {
+		return record, errInvalidOAuthState
+	}
+	if err != nil {
+		return record, err
+	}
+
+	if err := tx.Delete(&record).Error; err != nil {
+		return record, err
+	}
+	if time.Now().After(record.ExpiresAt) {
+		return record, errInvalidOAuthState
+	}
+	return record, nil
+}
+
+// findOrCreateOAuthUser tìm tài khoản đã liên kết với danh tính ở provider. Nếu chưa có liên kết
+// thì tạo tài khoản mới không có mật khẩu với email đã được provider xác minh. Danh tính không
+// bao giờ tự liên kết vào tài khoản có sẵn theo email: nếu email đã được dùng, người dùng phải
+// đăng nhập rồi liên kết provider (StartOAuthLink). Trả về true nếu tài khoản vừa được tạo.
+func findOrCreateOAuthUser(tx *gorm.DB, identity *oauth.Identity) (models.User, bool, error) {
+	var user models.User
+
+	var link models.UserIdentity
+	err := tx.Where("provider = ? AND subject = ?", identity.Provider, identity.Subject).First(&link).Error
+	if err == nil {
+		return user, false, tx.First(&user, "id = ?", link.UserID).Error
+	}
+	if err != gorm.ErrRecordNotFound {
+		return user, false, err
+	}
+
+	if identity.Email == "" || !identity.EmailVerified {
+		return user, false, errOAuthEmailUnverified
+	}
+
+	var count int64
+	if err := tx.Model(&models.User{}).Where("email = ?", identity.Email).Count(&count).Error; err != nil {
+		return user, false, err
+	}
+	if count > 0 {
+		return user, false, errOAuthEmailTaken
+	}
+
+	now := time.Now()
+	username := identity.Name
+	if username == "" {
+		username = strings.Split(identity.Email, "@")[0]
+	}
+	user = models.User{
+		Username:        truncate(username, 50),
+		Email:           identity.Email,
+		FullName:        truncate(identity.Name, 100),
+		Locale:          notification.DefaultLocale,
+		EmailVerifiedAt: &now,
+	}
+	if err := tx.Create(&user).Error; err != nil {
+		return user, false, err
+	}
+	if err := notification.Enqueue(tx, notification.TemplateWelcome, user.Locale, user.Email, map[string]interface{}{
+		"Username": user.Username,
+	}); err != nil {
+		return user, false, err
+	}
+
+	return user, true, linkOAuthIdentity(tx, user.ID, identity)
+}
+
+// linkOAuthIdentity liên kết danh tính vào tài khoản userID. Danh tính đã liên kết với
+// tài khoản khác trả về errIdentityLinked; liên kết lại vào chính tài khoản đó không làm gì.
+func linkOAuthIdentity(tx *gorm.DB, userID uuid.UUID, identity *oauth.Identity) error {
+	var existing models.UserIdentity
+	err := tx.Where("provider = ? AND subject = ?", identity.Provider, identity.Subject).First(&existing).Error
+	if err == nil {
+		if existing.UserID != userID {
+			return errIdentityLinked
+		}
+		return nil
+	}
+	if err != gorm.ErrRecordNotFound {
+		return err
+	}
+
+	link := models.UserIdentity{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Provider:  identity.Provider,
+		Subject:   identity.Subject,
+		Email:     identity.Email,
+		CreatedAt: time.Now(),
+	}
+	err = tx.Create(&link).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return errIdentityLinked
+	}
+	return err
+}
+
+// truncate cắt chuỗi còn tối đa n ký tự
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) > n {
+		return string(runes[:n])
+	}
+	return s
+}
+
+// OAuthCallback hoàn tất đăng nhập qua provider: kiểm tra state, đổi code lấy token (kèm PKCE
+// code verifier), xác thực danh tính rồi cấp access token và refresh token như Login.
+func OAuthCallback(c *gin.Context) {
+	type Request struct {
+		Code       string `json:"code" validate:"required"`
+		State      string `json:"state" validate:"required"`
+		DeviceName string `json:"device_name" validate:"max=100"`
+	}
+
+	var req Request
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.HandleValidationError(c, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(req); err != nil {
+		utils.HandleValidationError(c, err)
+		return
+	}
+
+	provider, err := oauth.GetProvider(c.Param("provider"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown login provider"})
+		return
+	}
+
+	// State phải khớp với cookie của trình duyệt đã bắt đầu đăng nhập (chống login CSRF)
+	cookieState, err := c.Cookie(oauthStateCookie)
+	setOAuthStateCookie(c, "", -1)
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookieState), []byte(req.State)) != 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired login state"})
+		return
+	}
+
+	var state models.OAuthState
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		state, err = consumeOAuthState(tx, provider.Name, req.State)
+		return err
+	})
+	if err == errInvalidOAuthState {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired login state"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify login state"})
+		return
+	}
+
+	token, err := provider.Exchange(c.Request.Context(), req.Code, state.CodeVerifier)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Failed to exchange authorization code"})
+		return
+	}
+
+	identity, err := provider.Identity(c.Request.Context(), token, state.Nonce)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Failed to verify identity with provider"})
+		return
+	}
+
+	// Luồng liên kết: gắn danh tính vào tài khoản đã bắt đầu liên kết, không cấp phiên mới
+	if state.UserID != nil {
+		err := config.DB.Transaction(func(tx *gorm.DB) error {
+			// State sống tới 10 phút: tài khoản có thể đã bị vô hiệu hoá hoặc xoá trong lúc đó
+			var user models.User
+			err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").
+				Where("id = ? AND deleted_at IS NULL AND disabled_at IS NULL", *state.UserID).
+				First(&user).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errOAuthLinkUnavailable
+			}
+			if err != nil {
+				return err
+			}
+			return linkOAuthIdentity(tx, user.ID, identity)
+		})
+		if err == errOAuthLinkUnavailable {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Account is disabled or no longer exists"})
+			return
+		}
+		if err == errIdentityLinked {
+			c.JSON(http.StatusConflict, gin.H{"error": "This login is already linked to another account"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to link account"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Account linked successfully", "provider": provider.Name})
+		return
+	}
+
+	var user models.User
+	var created bool
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		user, created, err = findOrCreateOAuthUser(tx, identity)
+		return err
+	})
+	if err == errOAuthEmailUnverified {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Login provider did not return a verified email"})
+		return
+	}
+	if err == errOAuthEmailTaken {
+		c.JSON(http.StatusConflict, gin.H{"error": "An account with this email already exists. Sign in and link this provider from your account settings"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign in with provider"})
+		return
+	}
+
+	if user.DisabledAt != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account is disabled"})
+		return
+	}
+
+	// Tài khoản đã bật 2FA vẫn phải qua bước /auth/login/2fa
+	if user.TOTPEnabled {
+		challengeToken, err := issueUserToken(config.DB, user.ID, models.TokenPurposeTwoFactorChallenge, 5*time.Minute)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create two-factor challenge"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"two_factor_required": true,
+			"challenge_token":     challengeToken,
+		})
+		return
+	}
+
+	accessToken, refreshToken, err := startSession(c, user, req.DeviceName, false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate tokens"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"access_token":  accessToken,
+		"refresh_token": refreshToken,
+		"created":       created,
+	})
+}
+
+// GetLinkedIdentities liệt kê các provider đã liên kết với tài khoản hiện tại
+func GetLinkedIdentities(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var identities []models.UserIdentity
+	if err := config.DB.Where("user_id = ?", userID).Order("created_at ASC").Find(&identities).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to fetch linked accounts", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	c.JSON(http.StatusOK, identities)
+}
+
+// UnlinkIdentity huỷ liên kết một provider. Tài khoản không có mật khẩu phải giữ lại
+// ít nhất một liên kết để còn cách đăng nhập.
+func UnlinkIdentity(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	identityID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid identity id", err.Error())
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, "id = ?", userID).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusNotFound, "User not found", err.Error())
+		c.JSON(http.StatusNotFound, errResp)
+		return
+	}
+
+	if user.PasswordHash == "" {
+		var count int64
+		if err := config.DB.Model(&models.UserIdentity{}).Where("user_id = ?", user.ID).Count(&count).Error; err != nil {
+			errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to unlink account", err.Error())
+			c.JSON(http.StatusInternalServerError, errResp)
+			return
+		}
+		if count <= 1 {
+			errResp := models.NewErrorResponse(http.StatusConflict, "Set a password before unlinking the last login provider")
+			c.JSON(http.StatusConflict, errResp)
+			return
+		}
+	}
+
+	result := config.DB.Where("id = ? AND user_id = ?", identityID, user.ID).Delete(&models.UserIdentity{})
+	if result.Error != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to unlink account", result.Error.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+	if result.RowsAffected == 0 {
+		errResp := models.NewErrorResponse(http.StatusNotFound, "Linked account not found")
+		c.JSON(http.StatusNotFound, errResp)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Account unlinked successfully"})
+}
@@ -0,0 +1,258 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"ecommerce-project/config"
+	"ecommerce-project/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errInvalidOrderTransition = errors.New("order cannot move to this status")
+
+// orderTransitions là các bước chuyển trạng thái giao hàng hợp lệ
+var orderTransitions = map[string]string{
+	models.OrderStatusShipped:   models.OrderStatusPlaced,
+	models.OrderStatusDelivered: models.OrderStatusShipped,
+}
+
+// insufficientStockError cho biết variant không đủ hàng khi đặt đơn
+type insufficientStockError struct {
+	variantID uuid.UUID
+}
+
+func (e insufficientStockError) Error() string {
+	return fmt.Sprintf("insufficient stock for variant %s", e.variantID)
+}
+
+// placeOrder tạo đơn hàng từ các CartItem (đã preload Variant) và trừ tồn kho trong tx.
+// Các variant được khoá theo thứ tự id để hai lần thanh toán đồng thời không deadlock.
+func placeOrder(tx *gorm.DB, order *models.Order, cartItems []models.CartItem) error {
+	productIDs := make([]uuid.UUID, 0, len(cartItems))
+	for _, item := range cartItems {
+		productIDs = append(productIDs, item.Variant.ProductID)
+	}
+	var products []models.Product
+	if err := tx.Select("id", "name").Where("id IN ?", productIDs).Find(&products).Error; err != nil {
+		return err
+	}
+	names := make(map[uuid.UUID]string, len(products))
+	for _, product := range products {
+		names[product.ID] = product.Name
+	}
+
+	items := append([]models.CartItem(nil), cartItems...)
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].VariantID.String() < items[j].VariantID.String()
+	})
+
+	order.Lines = make([]models.OrderLine, 0, len(items))
+	for _, item := range items {
+		_, err := changeVariantStock(tx, item.VariantID, -item.Quantity, models.StockMovementSale, &order.ID, &order.UserID)
+		if errors.Is(err, errNegativeStock) {
+			return insufficientStockError{variantID: item.VariantID}
+		}
+		if err != nil {
+			return err
+		}
+
+		order.Lines = append(order.Lines, models.OrderLine{
+			ID:          uuid.New(),
+			OrderID:     order.ID,
+			ProductID:   item.Variant.ProductID,
+			VariantID:   item.VariantID,
+			ProductName: names[item.Variant.ProductID],
+			Color:       item.Variant.Color,
+			Capacity:    item.Variant.Capacity,
+			UnitPrice:   item.Variant.Price,
+			Quantity:    item.Quantity,
+		})
+	}
+
+	return tx.Create(order).Error
+}
+
+// GetMyOrders trả về danh sách đơn hàng của người dùng hiện tại (mới nhất trước)
+func GetMyOrders(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", "10"))
+	if err != nil || pageSize < 1 {
+		pageSize = 10
+	}
+	offset := (page - 1) * pageSize
+
+	query := config.DB.Model(&models.Order{}).Where("user_id = ?", userID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to count orders", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	var orders []models.Order
+	if err := query.Preload("Lines").Order("placed_at DESC").Offset(offset).Limit(pageSize).Find(&orders).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to fetch orders", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	totalPages := int(math.Ceil(float64(total) / float64(pageSize)))
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": orders,
+		"pagination": gin.H{
+			"total":       total,
+			"page":        page,
+			"page_size":   pageSize,
+			"total_pages": totalPages,
+		},
+	})
+}
+
+// GetMyOrder trả về chi tiết một đơn hàng của người dùng hiện tại
+func GetMyOrder(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var order models.Order
+	if err := config.DB.Preload("Lines").First(&order, "id = ? AND user_id = ?", c.Param("id"), userID).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusNotFound, "Order not found", err.Error())
+		c.JSON(http.StatusNotFound, errResp)
+		return
+	}
+
+	c.JSON(http.StatusOK, order)
+}
+
+// AdminGetOrders trả về danh sách đơn hàng cho admin, có thể lọc theo status và user_id
+func AdminGetOrders(c *gin.Context) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if err != nil || pageSize < 1 {
+		pageSize = 20
+	}
+	offset := (page - 1) * pageSize
+
+	query := config.DB.Model(&models.Order{})
+	if status := c.Query("status"); status != "" {
+		switch status {
+		case models.OrderStatusPlaced, models.OrderStatusShipped, models.OrderStatusDelivered:
+			query = query.Where("status = ?", status)
+		default:
+			errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid status", status)
+			c.JSON(http.StatusBadRequest, errResp)
+			return
+		}
+	}
+	if userIDParam := c.Query("user_id"); userIDParam != "" {
+		userID, err := uuid.Parse(userIDParam)
+		if err != nil {
+			errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid user id", err.Error())
+			c.JSON(http.StatusBadRequest, errResp)
+			return
+		}
+		query = query.Where("user_id = ?", userID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to count orders", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	var orders []models.Order
+	if err := query.Preload("Lines").Order("placed_at DESC").Offset(offset).Limit(pageSize).Find(&orders).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to fetch orders", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	totalPages := int(math.Ceil(float64(total) / float64(pageSize)))
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": orders,
+		"pagination": gin.H{
+			"total":       total,
+			"page":        page,
+			"page_size":   pageSize,
+			"total_pages": totalPages,
+		},
+	})
+}
+
+// AdminUpdateOrderStatus chuyển đơn hàng sang đã gửi (shipped) hoặc đã giao (delivered).
+// Thời hạn trả hàng được tính từ thời điểm đơn chuyển sang delivered.
+func AdminUpdateOrderStatus(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid order id", err.Error())
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+
+	var input models.UpdateOrderStatusInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid request payload", err.Error())
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+
+	var order models.Order
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if order.Status != orderTransitions[input.Status] {
+			return errInvalidOrderTransition
+		}
+
+		now := time.Now()
+		updates := map[string]interface{}{"status": input.Status, "updated_at": now}
+		switch input.Status {
+		case models.OrderStatusShipped:
+			updates["shipped_at"] = now
+		case models.OrderStatusDelivered:
+			updates["delivered_at"] = now
+		}
+		if err := tx.Model(&order).Updates(updates).Error; err != nil {
+			return err
+		}
+		return tx.Preload("Lines").First(&order, "id = ?", id).Error
+	})
+	if err == gorm.ErrRecordNotFound {
+		errResp := models.NewErrorResponse(http.StatusNotFound, "Order not found", err.Error())
+		c.JSON(http.StatusNotFound, errResp)
+		return
+	}
+	if err == errInvalidOrderTransition {
+		errResp := models.NewErrorResponse(http.StatusConflict, "Invalid status transition", fmt.Sprintf("%s -> %s", order.Status, input.Status))
+		c.JSON(http.StatusConflict, errResp)
+		return
+	}
+	if err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to update order", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	c.JSON(http.StatusOK, order)
+}
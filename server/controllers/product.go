@@ -1,19 +1,52 @@
 package controllers
 
 import (
+	"errors"
 	"math"
 	"net/http"
+	"slices"
 	"strconv"
 	"time"
 
 	"ecommerce-project/config"
 	"ecommerce-project/models"
+	"ecommerce-project/recommend"
+	"ecommerce-project/specs"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
+// productSortOrders ánh xạ giá trị sort hợp lệ sang mệnh đề ORDER BY
+var productSortOrders = map[string]string{
+    "rating": "rating_average DESC, rating_count DESC",
+    "newest": "created_at DESC",
+}
+
+// GetProducts trả về danh sách sản phẩm đang hiển thị công khai (có phân trang)
 func GetProducts(c *gin.Context) {
+    listProducts(c, config.DB.Scopes(models.VisibleProducts))
+}
+
+// AdminGetProducts (admin) trả về mọi sản phẩm, kể cả bản nháp và đã lưu trữ, lọc theo status
+func AdminGetProducts(c *gin.Context) {
+    query := config.DB
+    if status := c.Query("status"); status != "" {
+        switch status {
+        case models.ProductStatusDraft, models.ProductStatusScheduled, models.ProductStatusPublished, models.ProductStatusArchived:
+            query = query.Where("status = ?", status)
+        default:
+            errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid status", "status must be one of draft, scheduled, published, archived")
+            c.JSON(http.StatusBadRequest, errResp)
+            return
+        }
+    }
+    listProducts(c, query)
+}
+
+// listProducts phân trang và sắp xếp danh sách sản phẩm trong phạm vi base
+func listProducts(c *gin.Context, base *gorm.DB) {
     // Lấy tham số page và page_size từ query string
     page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
     if err != nil || page < 1 {
@@ -25,17 +58,60 @@ func GetProducts(c *gin.Context) {
     }
     offset := (page - 1) * pageSize
 
+    // Lọc theo danh mục và thông số kỹ thuật (spec.<key>, spec.<key>_min, spec.<key>_max)
+    if categoryParam := c.Query("category_id"); categoryParam != "" {
+        categoryID, err := uuid.Parse(categoryParam)
+        if err != nil {
+            errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid category id", err.Error())
+            c.JSON(http.StatusBadRequest, errResp)
+            return
+        }
+        base = base.Where("products.category_id = ?", categoryID)
+    }
+    // compatible_with: phụ kiện dùng được với thiết bị; fits_accessory: thiết bị mà phụ kiện dùng được
+    if deviceParam := c.Query("compatible_with"); deviceParam != "" {
+        deviceID, err := uuid.Parse(deviceParam)
+        if err != nil {
+            errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid compatible_with", err.Error())
+            c.JSON(http.StatusBadRequest, errResp)
+            return
+        }
+        base = base.Scopes(compatibleAccessories(deviceID))
+    }
+    if accessoryParam := c.Query("fits_accessory"); accessoryParam != "" {
+        accessoryID, err := uuid.Parse(accessoryParam)
+        if err != nil {
+            errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid fits_accessory", err.Error())
+            c.JSON(http.StatusBadRequest, errResp)
+            return
+        }
+        base = base.Scopes(compatibleDevices(accessoryID))
+    }
+    specFilters, err := specs.ParseFilters(c.Request.URL.Query())
+    if err != nil {
+        errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid spec filter", err.Error())
+        c.JSON(http.StatusBadRequest, errResp)
+        return
+    }
+    base = specs.Apply(base, specFilters)
+
     // Lấy tổng số bản ghi sản phẩm
     var total int64
-    if err := config.DB.Model(&models.Product{}).Count(&total).Error; err != nil {
+    if err := base.Session(&gorm.Session{}).Model(&models.Product{}).Count(&total).Error; err != nil {
         errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to count products", err.Error())
         c.JSON(http.StatusInternalServerError, errResp)
         return
     }
 
+    // Sắp xếp theo tham số sort (rating, newest), mặc định giữ nguyên thứ tự
+    query := base.Session(&gorm.Session{}).Preload("Variants").Preload("Category").Scopes(orderedProductImages)
+    if order, ok := productSortOrders[c.Query("sort")]; ok {
+        query = query.Order(order)
+    }
+
     // Lấy danh sách sản phẩm theo phân trang, preload luôn các Variants và Category
     var products []models.Product
-    if err := config.DB.Preload("Variants").Preload("Category").Offset(offset).Limit(pageSize).Find(&products).Error; err != nil {
+    if err := query.Offset(offset).Limit(pageSize).Find(&products).Error; err != nil {
         errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to fetch products", err.Error())
         c.JSON(http.StatusInternalServerError, errResp)
         return
@@ -54,18 +130,43 @@ func GetProducts(c *gin.Context) {
     })
 }
 
+// GetProduct trả về chi tiết một sản phẩm đang hiển thị công khai và ghi nhận lượt xem (ở nền)
 func GetProduct(c *gin.Context) {
+    // Cookie khách phải được đặt trước khi response được ghi
+    subject, userID := interactionSubject(c)
+    product, ok := showProduct(c, config.DB.Scopes(models.VisibleProducts))
+    if ok && subject != "" {
+        recommend.RecordView(subject, userID, product.ID)
+    }
+}
+
+// AdminGetProduct (admin) xem trước sản phẩm ở mọi trạng thái, kể cả bản nháp
+func AdminGetProduct(c *gin.Context) {
+    showProduct(c, config.DB)
+}
+
+func showProduct(c *gin.Context, base *gorm.DB) (models.Product, bool) {
     id := c.Param("id")
     var product models.Product
 
-    // Preload các Variants và Category của sản phẩm
-    if err := config.DB.Preload("Variants").Preload("Category").First(&product, "id = ?", id).Error; err != nil {
+    // Preload các Variants, Category và ảnh của sản phẩm
+    if err := base.Preload("Variants").Preload("Category").Scopes(orderedProductImages).First(&product, "id = ?", id).Error; err != nil {
         errResp := models.NewErrorResponse(http.StatusNotFound, "Product not found", err.Error())
         c.JSON(http.StatusNotFound, errResp)
-        return
+        return product, false
+    }
+
+    // Thống kê số đánh giá theo từng mức sao
+    histogram, err := getRatingHistogram(product.ID)
+    if err != nil {
+        errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to fetch product rating", err.Error())
+        c.JSON(http.StatusInternalServerError, errResp)
+        return product, false
     }
+    product.RatingHistogram = histogram
 
     c.JSON(http.StatusOK, product)
+    return product, true
 }
 
 func CreateProduct(c *gin.Context) {
@@ -78,25 +179,72 @@ func CreateProduct(c *gin.Context) {
         return
     }
 
-    // Tạo đối tượng product với mảng ảnh và category
+    // Tạo đối tượng product với category; image_urls được suy ra từ ảnh trong thư viện media
     product := models.Product{
         ID:          uuid.New(),
         Name:        input.Name,
         Description: input.Description,
-        ImageURLs:   input.ImageURLs,
         CategoryID:  uuid.MustParse(input.CategoryID),
         CreatedAt:   time.Now(),
         UpdatedAt:   time.Now(),
     }
 
-    // Lưu product vào database
-    if err := config.DB.Create(&product).Error; err != nil {
+    // Thông số kỹ thuật phải khớp schema của danh mục
+    productSpecs, err := validateProductSpecs(product.CategoryID, input.Specs)
+    if err != nil {
+        errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid product specs", err.Error())
+        c.JSON(http.StatusBadRequest, errResp)
+        return
+    }
+    product.Specs = productSpecs
+
+    // Sản phẩm mới là bản nháp trừ khi chỉ định trạng thái khác
+    status := input.Status
+    if status == "" {
+        status = models.ProductStatusDraft
+        if input.Public != nil && *input.Public {
+            status = models.ProductStatusPublished
+        }
+    }
+    if err := setProductStatus(&product, status, input.PublishAt, input.UnpublishAt); err != nil {
+        errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid product status", err.Error())
+        c.JSON(http.StatusBadRequest, errResp)
+        return
+    }
+
+    // Lưu product vào database cùng ảnh (URL phải thuộc thư viện media) và phiên bản nội dung đầu tiên
+    var imagesErr error
+    err = config.DB.Transaction(func(tx *gorm.DB) error {
+        images, records, err := productImagesFromURLs(tx, product.ID, input.ImageURLs)
+        if err != nil {
+            imagesErr = err
+            return err
+        }
+        if err := tx.Create(&product).Error; err != nil {
+            return err
+        }
+        if product.ImageURLs, err = replaceProductImages(tx, product.ID, images, records); err != nil {
+            return err
+        }
+        if err := tx.Model(&product).Update("image_urls", product.ImageURLs).Error; err != nil {
+            return err
+        }
+        _, err = recordProductRevision(tx, product.ID, currentActorID(c), nil)
+        return err
+    })
+    if imagesErr != nil {
+        errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid product images", imagesErr.Error())
+        c.JSON(http.StatusBadRequest, errResp)
+        return
+    }
+    if err != nil {
         errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to create product", err.Error())
         c.JSON(http.StatusInternalServerError, errResp)
         return
     }
 
     // Trả về product đã tạo
+    product.Public = product.IsVisible(time.Now())
     c.JSON(http.StatusCreated, product)
 }
 
@@ -123,40 +271,175 @@ func UpdateProduct(c *gin.Context) {
     if input.Description != nil {
         product.Description = *input.Description
     }
-    // Cập nhật mảng ảnh nếu có
-    if input.ImageURLs != nil {
-        product.ImageURLs = *input.ImageURLs
-    }
-    if input.Public != nil {
-        product.Public = *input.Public
+    // Mảng ảnh mới (nếu khác hiện tại) được chuyển thành ảnh trong thư viện media khi lưu
+    imageURLsChanged := input.ImageURLs != nil && !slices.Equal(*input.ImageURLs, product.ImageURLs)
+    // Client cũ gửi public: chỉ đổi trạng thái khi khác với trạng thái hiển thị hiện tại
+    if input.Public != nil && *input.Public != product.IsVisible(time.Now()) {
+        status := models.ProductStatusDraft
+        if *input.Public {
+            status = models.ProductStatusPublished
+        }
+        if err := setProductStatus(&product, status, nil, nil); err != nil {
+            errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid product status", err.Error())
+            c.JSON(http.StatusBadRequest, errResp)
+            return
+        }
     }
     if input.CategoryID != nil {
         product.CategoryID = uuid.MustParse(*input.CategoryID)
     }
 
+    // Kiểm tra lại thông số khi đổi thông số hoặc đổi danh mục (schema có thể khác)
+    if input.Specs != nil || input.CategoryID != nil {
+        values := product.Specs
+        if input.Specs != nil {
+            values = *input.Specs
+        }
+        productSpecs, err := validateProductSpecs(product.CategoryID, values)
+        if err != nil {
+            errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid product specs", err.Error())
+            c.JSON(http.StatusBadRequest, errResp)
+            return
+        }
+        product.Specs = productSpecs
+    }
+
     product.UpdatedAt = time.Now()
 
-    if err := config.DB.Save(&product).Error; err != nil {
+    // Lưu thay đổi và ghi nhận phiên bản nội dung mới
+    var imagesErr error
+    err := config.DB.Transaction(func(tx *gorm.DB) error {
+        if err := ensureBaselineRevision(tx, product.ID); err != nil {
+            return err
+        }
+        if imageURLsChanged {
+            images, records, err := productImagesFromURLs(tx, product.ID, *input.ImageURLs)
+            if err != nil {
+                imagesErr = err
+                return err
+            }
+            if product.ImageURLs, err = replaceProductImages(tx, product.ID, images, records); err != nil {
+                return err
+            }
+        }
+        if err := tx.Save(&product).Error; err != nil {
+            return err
+        }
+        _, err := recordProductRevision(tx, product.ID, currentActorID(c), nil)
+        return err
+    })
+    if imagesErr != nil {
+        errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid product images", imagesErr.Error())
+        c.JSON(http.StatusBadRequest, errResp)
+        return
+    }
+    if err != nil {
         errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to update product", err.Error())
         c.JSON(http.StatusInternalServerError, errResp)
         return
     }
 
+    product.Public = product.IsVisible(time.Now())
     c.JSON(http.StatusOK, product)
 }
 
-func DeleteProduct(c *gin.Context) {
+// validateProductSpecs kiểm tra thông số kỹ thuật theo schema của danh mục categoryID
+func validateProductSpecs(categoryID uuid.UUID, values map[string]interface{}) (map[string]interface{}, error) {
+    var category models.Category
+    if err := config.DB.First(&category, "id = ?", categoryID).Error; err != nil {
+        return nil, errors.New("category not found")
+    }
+    return specs.ValidateValues(category.SpecSchema, values)
+}
+
+// UpdateProductStatus (admin) chuyển trạng thái vòng đời của sản phẩm:
+// draft, scheduled (hiển thị từ publish_at), published hoặc archived.
+func UpdateProductStatus(c *gin.Context) {
     id := c.Param("id")
+    var product models.Product
+
+    if err := config.DB.First(&product, "id = ?", id).Error; err != nil {
+        errResp := models.NewErrorResponse(http.StatusNotFound, "Product not found", err.Error())
+        c.JSON(http.StatusNotFound, errResp)
+        return
+    }
+
+    var input models.ProductStatusInput
+    if err := c.ShouldBindJSON(&input); err != nil {
+        errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid request payload", err.Error())
+        c.JSON(http.StatusBadRequest, errResp)
+        return
+    }
+
+    if err := setProductStatus(&product, input.Status, input.PublishAt, input.UnpublishAt); err != nil {
+        errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid product status", err.Error())
+        c.JSON(http.StatusBadRequest, errResp)
+        return
+    }
+    product.UpdatedAt = time.Now()
 
-    // Delete all variants associated with the product
-    if err := config.DB.Where("product_id = ?", id).Delete(&models.ProductVariant{}).Error; err != nil {
-        errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to delete product variants", err.Error())
+    if err := config.DB.Model(&product).Select("status", "publish_at", "unpublish_at", "updated_at").Updates(&product).Error; err != nil {
+        errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to update product status", err.Error())
         c.JSON(http.StatusInternalServerError, errResp)
         return
     }
 
-    // Delete the product
-    if err := config.DB.Delete(&models.Product{}, "id = ?", id).Error; err != nil {
+    product.Public = product.IsVisible(time.Now())
+    c.JSON(http.StatusOK, product)
+}
+
+// setProductStatus kiểm tra và gán trạng thái vòng đời cùng các mốc thời gian cho product
+func setProductStatus(product *models.Product, status string, publishAt, unpublishAt *time.Time) error {
+    now := time.Now()
+    switch status {
+    case models.ProductStatusScheduled:
+        if publishAt == nil {
+            return errors.New("publish_at is required for scheduled products")
+        }
+    case models.ProductStatusPublished:
+        // Xuất bản ngay: ghi nhận thời điểm xuất bản
+        if publishAt == nil || publishAt.After(now) {
+            publishAt = &now
+        }
+    case models.ProductStatusDraft, models.ProductStatusArchived:
+        publishAt = nil
+        unpublishAt = nil
+    default:
+        return errors.New("unknown status " + status)
+    }
+    if publishAt != nil && unpublishAt != nil && !unpublishAt.After(*publishAt) {
+        return errors.New("unpublish_at must be after publish_at")
+    }
+
+    product.Status = status
+    product.PublishAt = publishAt
+    product.UnpublishAt = unpublishAt
+    return nil
+}
+
+// DeleteProduct chuyển sản phẩm và các variant của nó vào thùng rác (soft delete).
+// Sản phẩm và variant dùng chung thời điểm xoá để RestoreProduct khôi phục đúng các variant bị xoá cùng.
+func DeleteProduct(c *gin.Context) {
+    id := c.Param("id")
+
+    err := config.DB.Transaction(func(tx *gorm.DB) error {
+        now := time.Now()
+        result := tx.Model(&models.Product{}).Where("id = ?", id).Update("deleted_at", now)
+        if result.Error != nil {
+            return result.Error
+        }
+        if result.RowsAffected == 0 {
+            return gorm.ErrRecordNotFound
+        }
+
+        return tx.Model(&models.ProductVariant{}).Where("product_id = ?", id).Update("deleted_at", now).Error
+    })
+    if err == gorm.ErrRecordNotFound {
+        errResp := models.NewErrorResponse(http.StatusNotFound, "Product not found", err.Error())
+        c.JSON(http.StatusNotFound, errResp)
+        return
+    }
+    if err != nil {
         errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to delete product", err.Error())
         c.JSON(http.StatusInternalServerError, errResp)
         return
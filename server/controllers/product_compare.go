@@ -0,0 +1,173 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+
+	"ecommerce-project/config"
+	"ecommerce-project/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Các kiểu hàng so sánh không thuộc schema thông số
+const (
+	comparisonTypePriceRange = "price_range"
+	comparisonTypeList       = "list"
+)
+
+// CompareProducts trả về bảng so sánh các sản phẩm trong tham số ids (cách nhau bởi dấu phẩy):
+// khoảng giá, màu và dung lượng của các variant đang bán, rồi các thông số kỹ thuật được căn theo
+// schema của danh mục. Số sản phẩm tối đa được cấu hình bởi COMPARE_MAX_PRODUCTS.
+func CompareProducts(c *gin.Context) {
+	maxProducts := config.GetEnvInt("COMPARE_MAX_PRODUCTS", 4)
+
+	var ids []uuid.UUID
+	seen := map[uuid.UUID]bool{}
+	for _, value := range strings.Split(c.Query("ids"), ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		id, err := uuid.Parse(value)
+		if err != nil {
+			errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid product id", err.Error())
+			c.JSON(http.StatusBadRequest, errResp)
+			return
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) < 2 || len(ids) > maxProducts {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid number of products",
+			fmt.Sprintf("compare between 2 and %d products", maxProducts))
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+
+	var found []models.Product
+	if err := config.DB.Scopes(models.VisibleProducts).
+		Preload("Variants", "active = ?", true).Preload("Category").
+		Where("id IN ?", ids).Find(&found).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to fetch products", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	// Giữ thứ tự sản phẩm theo tham số ids
+	byID := map[uuid.UUID]models.Product{}
+	for _, product := range found {
+		byID[product.ID] = product
+	}
+	products := make([]models.Product, 0, len(ids))
+	for _, id := range ids {
+		product, ok := byID[id]
+		if !ok {
+			errResp := models.NewErrorResponse(http.StatusNotFound, "Product not found", id.String())
+			c.JSON(http.StatusNotFound, errResp)
+			return
+		}
+		products = append(products, product)
+	}
+
+	c.JSON(http.StatusOK, buildComparison(products))
+}
+
+// buildComparison dựng bảng so sánh cho các sản phẩm đã preload Variants và Category
+func buildComparison(products []models.Product) models.ProductComparison {
+	comparison := models.ProductComparison{Products: []models.ComparisonProduct{}}
+
+	priceRow := models.ComparisonRow{Key: "price", Label: "Price", Type: comparisonTypePriceRange}
+	colorRow := models.ComparisonRow{Key: "colors", Label: "Colors", Type: comparisonTypeList}
+	capacityRow := models.ComparisonRow{Key: "capacities", Label: "Capacities", Type: comparisonTypeList}
+
+	for _, product := range products {
+		column := models.ComparisonProduct{ID: product.ID, Name: product.Name, CategoryID: product.CategoryID}
+		if len(product.ImageURLs) > 0 {
+			column.ImageURL = product.ImageURLs[0]
+		}
+
+		var price *models.PriceRange
+		colors := []string{}
+		capacities := []string{}
+		for _, variant := range product.Variants {
+			if variant.Stock > 0 {
+				column.InStock = true
+			}
+			if price == nil {
+				price = &models.PriceRange{Min: variant.Price, Max: variant.Price}
+			}
+			price.Min = min(price.Min, variant.Price)
+			price.Max = max(price.Max, variant.Price)
+			if !slices.Contains(colors, variant.Color) {
+				colors = append(colors, variant.Color)
+			}
+			if !slices.Contains(capacities, variant.Capacity) {
+				capacities = append(capacities, variant.Capacity)
+			}
+		}
+		// Sắp xếp để cùng tập giá trị không bị đánh dấu là khác nhau
+		sort.Strings(colors)
+		sort.Strings(capacities)
+
+		comparison.Products = append(comparison.Products, column)
+		if price != nil {
+			priceRow.Values = append(priceRow.Values, *price)
+		} else {
+			priceRow.Values = append(priceRow.Values, nil)
+		}
+		colorRow.Values = append(colorRow.Values, colors)
+		capacityRow.Values = append(capacityRow.Values, capacities)
+	}
+	comparison.Rows = append(comparison.Rows, priceRow, colorRow, capacityRow)
+
+	// Hợp các schema thông số theo thứ tự xuất hiện; sản phẩm khác danh mục có thể thiếu hàng
+	var definitions []models.SpecDefinition
+	defined := map[string]bool{}
+	for _, product := range products {
+		for _, definition := range product.Category.SpecSchema {
+			if !defined[definition.Key] {
+				defined[definition.Key] = true
+				definitions = append(definitions, definition)
+			}
+		}
+	}
+	for _, definition := range definitions {
+		row := models.ComparisonRow{
+			Key:   "specs." + definition.Key,
+			Label: definition.Label,
+			Type:  definition.Type,
+			Unit:  definition.Unit,
+		}
+		for _, product := range products {
+			row.Values = append(row.Values, product.Specs[definition.Key])
+		}
+		comparison.Rows = append(comparison.Rows, row)
+	}
+
+	for i := range comparison.Rows {
+		comparison.Rows[i].Differs = valuesDiffer(comparison.Rows[i].Values)
+	}
+	return comparison
+}
+
+// valuesDiffer cho biết các giá trị trong một hàng có khác nhau hay không (so theo JSON)
+func valuesDiffer(values []interface{}) bool {
+	var first string
+	for i, value := range values {
+		encoded, _ := json.Marshal(value)
+		if i == 0 {
+			first = string(encoded)
+		} else if string(encoded) != first {
+			return true
+		}
+	}
+	return false
+}
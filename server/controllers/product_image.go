@@ -0,0 +1,205 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"ecommerce-project/config"
+	"ecommerce-project/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errMultiplePrimaryImages = errors.New("only one image can be primary")
+	errImageNotInLibrary     = errors.New("image is not in the media library")
+)
+
+// orderedProductImages preload ảnh của sản phẩm theo thứ tự hiển thị, kèm thông tin media
+func orderedProductImages(db *gorm.DB) *gorm.DB {
+	return db.Preload("Images", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position ASC")
+	}).Preload("Images.Media")
+}
+
+// parseProductImages kiểm tra danh sách ảnh: không trùng media, tối đa một ảnh chính và mọi media đều tồn tại.
+// Nếu không có ảnh nào được đánh dấu chính thì ảnh đầu tiên là ảnh chính.
+func parseProductImages(tx *gorm.DB, productID uuid.UUID, inputs []models.ProductImageInput) ([]models.ProductImage, map[uuid.UUID]models.Media, error) {
+	images := make([]models.ProductImage, 0, len(inputs))
+	ids := make([]uuid.UUID, 0, len(inputs))
+	seen := map[uuid.UUID]bool{}
+	primary := -1
+	now := time.Now()
+
+	for i, input := range inputs {
+		mediaID := uuid.MustParse(input.MediaID)
+		if seen[mediaID] {
+			return nil, nil, fmt.Errorf("media %s is listed more than once", mediaID)
+		}
+		seen[mediaID] = true
+		if input.Primary {
+			if primary >= 0 {
+				return nil, nil, errMultiplePrimaryImages
+			}
+			primary = i
+		}
+		ids = append(ids, mediaID)
+		images = append(images, models.ProductImage{
+			ID:        uuid.New(),
+			ProductID: productID,
+			MediaID:   mediaID,
+			Position:  i,
+			CreatedAt: now,
+		})
+	}
+	if len(images) > 0 {
+		images[max(primary, 0)].Primary = true
+	}
+
+	found := map[uuid.UUID]models.Media{}
+	if len(ids) > 0 {
+		var records []models.Media
+		if err := tx.Where("id IN ?", ids).Find(&records).Error; err != nil {
+			return nil, nil, err
+		}
+		for _, record := range records {
+			found[record.ID] = record
+		}
+	}
+	for _, id := range ids {
+		if _, ok := found[id]; !ok {
+			return nil, nil, fmt.Errorf("media %s not found", id)
+		}
+	}
+	return images, found, nil
+}
+
+// productImagesFromURLs chuyển danh sách image_urls (client cũ) thành ảnh trong thư viện media theo URL
+// của ảnh gốc; URL đầu tiên là ảnh chính. URL không thuộc thư viện media trả về errImageNotInLibrary.
+func productImagesFromURLs(tx *gorm.DB, productID uuid.UUID, urls []string) ([]models.ProductImage, map[uuid.UUID]models.Media, error) {
+	byURL := map[string]uuid.UUID{}
+	if len(urls) > 0 {
+		var records []models.Media
+		if err := tx.Select("id", "url").Where("url IN ?", urls).Find(&records).Error; err != nil {
+			return nil, nil, err
+		}
+		for _, record := range records {
+			byURL[record.URL] = record.ID
+		}
+	}
+
+	inputs := make([]models.ProductImageInput, 0, len(urls))
+	for i, url := range urls {
+		id, ok := byURL[url]
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: %s", errImageNotInLibrary, url)
+		}
+		inputs = append(inputs, models.ProductImageInput{MediaID: id.String(), Primary: i == 0})
+	}
+	return parseProductImages(tx, productID, inputs)
+}
+
+// replaceProductImages thay toàn bộ ảnh của sản phẩm và trả về image_urls tương ứng (ảnh chính đứng đầu),
+// để image_urls luôn được suy ra từ ảnh trong thư viện media
+func replaceProductImages(tx *gorm.DB, productID uuid.UUID, images []models.ProductImage, records map[uuid.UUID]models.Media) ([]string, error) {
+	if err := tx.Where("product_id = ?", productID).Delete(&models.ProductImage{}).Error; err != nil {
+		return nil, err
+	}
+	if len(images) > 0 {
+		if err := tx.Create(&images).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	urls := make([]string, 0, len(images))
+	for _, image := range images {
+		if image.Primary {
+			urls = append(urls, records[image.MediaID].URL)
+		}
+	}
+	for _, image := range images {
+		if !image.Primary {
+			urls = append(urls, records[image.MediaID].URL)
+		}
+	}
+	return urls, nil
+}
+
+// SetProductImages (admin) thay thế toàn bộ ảnh của sản phẩm bằng các media trong thư viện.
+// Thứ tự trong danh sách là thứ tự hiển thị; image_urls của sản phẩm được đồng bộ theo
+// (ảnh chính đứng đầu) để client cũ vẫn hiển thị đúng, và thay đổi được lưu thành phiên bản mới.
+func SetProductImages(c *gin.Context) {
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid product id", err.Error())
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+
+	var input models.SetProductImagesInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid request payload", err.Error())
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+
+	var validationErr error
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		var product models.Product
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&product, "id = ?", productID).Error; err != nil {
+			return err
+		}
+
+		images, records, err := parseProductImages(tx, productID, input.Images)
+		if err != nil {
+			validationErr = err
+			return err
+		}
+
+		if err := ensureBaselineRevision(tx, productID); err != nil {
+			return err
+		}
+
+		urls, err := replaceProductImages(tx, productID, images, records)
+		if err != nil {
+			return err
+		}
+		product.ImageURLs = urls
+		product.UpdatedAt = time.Now()
+		if err := tx.Model(&product).Select("image_urls", "updated_at").Updates(&product).Error; err != nil {
+			return err
+		}
+
+		_, err = recordProductRevision(tx, productID, currentActorID(c), nil)
+		return err
+	})
+	if validationErr != nil {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid product images", validationErr.Error())
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		errResp := models.NewErrorResponse(http.StatusNotFound, "Product not found", err.Error())
+		c.JSON(http.StatusNotFound, errResp)
+		return
+	}
+	if err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to update product images", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	var product models.Product
+	if err := config.DB.Scopes(orderedProductImages).First(&product, "id = ?", productID).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to fetch product", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"product_id": productID, "images": product.Images, "image_urls": product.ImageURLs})
+}
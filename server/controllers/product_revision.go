@@ -0,0 +1,281 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"ecommerce-project/audit"
+	"ecommerce-project/config"
+	"ecommerce-project/models"
+	"ecommerce-project/specs"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errRevisionCategoryDeleted = errors.New("category of this revision is deleted")
+	errRevisionImagesDeleted   = errors.New("images of this revision are no longer in the media library")
+	errRevisionSpecsInvalid    = errors.New("specs of this revision do not match the category schema")
+)
+
+// recordProductRevision lưu nội dung hiện tại của sản phẩm thành phiên bản mới.
+// rolledBackFrom khác nil khi phiên bản được tạo bởi rollback.
+func recordProductRevision(tx *gorm.DB, productID uuid.UUID, actorID *uuid.UUID, rolledBackFrom *int) (models.ProductRevision, error) {
+	var revision models.ProductRevision
+
+	// Khoá sản phẩm để hai lần cập nhật đồng thời không lấy trùng số phiên bản
+	var product models.Product
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&product, "id = ?", productID).Error; err != nil {
+		return revision, err
+	}
+
+	var last int
+	if err := tx.Model(&models.ProductRevision{}).Where("product_id = ?", productID).
+		Select("COALESCE(MAX(number), 0)").Scan(&last).Error; err != nil {
+		return revision, err
+	}
+
+	revision = models.ProductRevision{
+		ID:             uuid.New(),
+		ProductID:      productID,
+		Number:         last + 1,
+		Snapshot:       models.NewProductSnapshot(product),
+		ActorID:        actorID,
+		RolledBackFrom: rolledBackFrom,
+		CreatedAt:      time.Now(),
+	}
+	return revision, tx.Create(&revision).Error
+}
+
+// ensureBaselineRevision lưu nội dung hiện tại làm phiên bản đầu tiên cho sản phẩm được tạo
+// trước khi có lịch sử phiên bản, để lần cập nhật đầu tiên vẫn có thể được rollback.
+func ensureBaselineRevision(tx *gorm.DB, productID uuid.UUID) error {
+	var count int64
+	if err := tx.Model(&models.ProductRevision{}).Where("product_id = ?", productID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	_, err := recordProductRevision(tx, productID, nil, nil)
+	return err
+}
+
+// diffSnapshots so sánh hai phiên bản theo từng trường, thông số có khoá specs.<tên>
+func diffSnapshots(before, after *models.ProductSnapshot) map[string]models.AuditChange {
+	changes := map[string]models.AuditChange{}
+	if before == nil {
+		before = &models.ProductSnapshot{}
+	}
+
+	addChange := func(key string, old, new interface{}) {
+		if !audit.JSONEqual(old, new) {
+			changes[key] = models.AuditChange{Before: old, After: new}
+		}
+	}
+	addChange("name", before.Name, after.Name)
+	addChange("description", before.Description, after.Description)
+	addChange("image_urls", before.ImageURLs, after.ImageURLs)
+	addChange("category_id", before.CategoryID, after.CategoryID)
+	for key := range mergeKeys(before.Specs, after.Specs) {
+		addChange("specs."+key, before.Specs[key], after.Specs[key])
+	}
+	return changes
+}
+
+// mergeKeys trả về hợp các khoá của hai map
+func mergeKeys(a, b map[string]interface{}) map[string]bool {
+	keys := map[string]bool{}
+	for key := range a {
+		keys[key] = true
+	}
+	for key := range b {
+		keys[key] = true
+	}
+	return keys
+}
+
+// GetProductRevisions (admin) liệt kê các phiên bản của sản phẩm, mới nhất trước,
+// mỗi phiên bản kèm các trường thay đổi so với phiên bản liền trước.
+func GetProductRevisions(c *gin.Context) {
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid product id", err.Error())
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+
+	var revisions []models.ProductRevision
+	if err := config.DB.Where("product_id = ?", productID).Order("number ASC").Find(&revisions).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to fetch revisions", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	response := make([]models.ProductRevisionResponse, len(revisions))
+	var previous *models.ProductSnapshot
+	for i, revision := range revisions {
+		response[len(revisions)-1-i] = models.ProductRevisionResponse{
+			ProductRevision: revision,
+			Changes:         diffSnapshots(previous, &revisions[i].Snapshot),
+		}
+		previous = &revisions[i].Snapshot
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// GetProductRevision (admin) trả về một phiên bản kèm các trường thay đổi so với phiên bản
+// compare (mặc định là phiên bản liền trước).
+func GetProductRevision(c *gin.Context) {
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid product id", err.Error())
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+	number, err := strconv.Atoi(c.Param("number"))
+	if err != nil {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid revision number", err.Error())
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+	compare, err := strconv.Atoi(c.DefaultQuery("compare", strconv.Itoa(number-1)))
+	if err != nil {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid compare revision", err.Error())
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+
+	var revision models.ProductRevision
+	if err := config.DB.First(&revision, "product_id = ? AND number = ?", productID, number).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusNotFound, "Revision not found", err.Error())
+		c.JSON(http.StatusNotFound, errResp)
+		return
+	}
+
+	var base *models.ProductSnapshot
+	if compare > 0 {
+		var other models.ProductRevision
+		if err := config.DB.First(&other, "product_id = ? AND number = ?", productID, compare).Error; err != nil {
+			errResp := models.NewErrorResponse(http.StatusNotFound, "Compare revision not found", err.Error())
+			c.JSON(http.StatusNotFound, errResp)
+			return
+		}
+		base = &other.Snapshot
+	}
+
+	c.JSON(http.StatusOK, models.ProductRevisionResponse{
+		ProductRevision: revision,
+		Changes:         diffSnapshots(base, &revision.Snapshot),
+	})
+}
+
+// RollbackProductRevision (admin) khôi phục nội dung sản phẩm (tên, mô tả, ảnh, danh mục, thông số)
+// về một phiên bản cũ và lưu kết quả thành phiên bản mới. Variant, giá và tồn kho không bị thay đổi.
+// Thông số cũ không còn khớp schema hiện tại của danh mục bị từ chối với 409.
+func RollbackProductRevision(c *gin.Context) {
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid product id", err.Error())
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+	number, err := strconv.Atoi(c.Param("number"))
+	if err != nil {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid revision number", err.Error())
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+
+	var target models.ProductRevision
+	if err := config.DB.First(&target, "product_id = ? AND number = ?", productID, number).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusNotFound, "Revision not found", err.Error())
+		c.JSON(http.StatusNotFound, errResp)
+		return
+	}
+	snapshot := target.Snapshot
+
+	var revision models.ProductRevision
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		var product models.Product
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&product, "id = ?", productID).Error; err != nil {
+			return err
+		}
+
+		// Schema của danh mục có thể đã đổi sau khi phiên bản được lưu: thông số phải khớp schema
+		// hiện tại như khi tạo và cập nhật. Khoá danh mục để schema không đổi cho tới khi commit.
+		var category models.Category
+		err := tx.Clauses(clause.Locking{Strength: "SHARE"}).First(&category, "id = ?", snapshot.CategoryID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errRevisionCategoryDeleted
+		}
+		if err != nil {
+			return err
+		}
+		productSpecs, err := specs.ValidateValues(category.SpecSchema, snapshot.Specs)
+		if err != nil {
+			return fmt.Errorf("%w: %v", errRevisionSpecsInvalid, err)
+		}
+
+		// Ảnh được khôi phục qua thư viện media để image_urls và ảnh sản phẩm không lệch nhau
+		if !slices.Equal(product.ImageURLs, snapshot.ImageURLs) {
+			images, records, err := productImagesFromURLs(tx, productID, snapshot.ImageURLs)
+			if errors.Is(err, errImageNotInLibrary) {
+				return errRevisionImagesDeleted
+			}
+			if err != nil {
+				return err
+			}
+			if product.ImageURLs, err = replaceProductImages(tx, productID, images, records); err != nil {
+				return err
+			}
+		}
+
+		product.Name = snapshot.Name
+		product.Description = snapshot.Description
+		product.CategoryID = snapshot.CategoryID
+		product.Specs = productSpecs
+		product.UpdatedAt = time.Now()
+		if err := tx.Model(&product).Select("name", "description", "image_urls", "category_id", "specs", "updated_at").Updates(&product).Error; err != nil {
+			return err
+		}
+
+		revision, err = recordProductRevision(tx, productID, currentActorID(c), &target.Number)
+		return err
+	})
+	if err == gorm.ErrRecordNotFound {
+		errResp := models.NewErrorResponse(http.StatusNotFound, "Product not found", err.Error())
+		c.JSON(http.StatusNotFound, errResp)
+		return
+	}
+	if err == errRevisionCategoryDeleted {
+		errResp := models.NewErrorResponse(http.StatusConflict, "Category of this revision is deleted, restore it first")
+		c.JSON(http.StatusConflict, errResp)
+		return
+	}
+	if errors.Is(err, errRevisionSpecsInvalid) {
+		errResp := models.NewErrorResponse(http.StatusConflict, "Specs of this revision do not match the current category schema", err.Error())
+		c.JSON(http.StatusConflict, errResp)
+		return
+	}
+	if err == errRevisionImagesDeleted {
+		errResp := models.NewErrorResponse(http.StatusConflict, "Images of this revision are no longer in the media library")
+		c.JSON(http.StatusConflict, errResp)
+		return
+	}
+	if err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to roll back product", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	c.JSON(http.StatusOK, revision)
+}
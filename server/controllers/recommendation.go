@@ -0,0 +1,314 @@
+package controllers
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"ecommerce-project/config"
+	"ecommerce-project/models"
+	"ecommerce-project/recommend"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Cookie định danh khách chưa đăng nhập, do server cấp và ký bằng HMAC nên client không tự chọn được
+const (
+	visitorCookie    = "visitor_id"
+	visitorCookieTTL = 365 * 24 * time.Hour
+)
+
+var (
+	visitorSecretOnce sync.Once
+	visitorSecret     []byte
+)
+
+// visitorKey trả về khoá ký cookie khách từ VISITOR_COOKIE_SECRET. Nếu không cấu hình, khoá ngẫu nhiên
+// được sinh khi khởi động: cookie cũ mất hiệu lực sau khi khởi động lại và không dùng chung giữa các instance.
+func visitorKey() []byte {
+	visitorSecretOnce.Do(func() {
+		visitorSecret = []byte(config.GetEnv("VISITOR_COOKIE_SECRET"))
+		if len(visitorSecret) == 0 {
+			log.Println("VISITOR_COOKIE_SECRET is not set, using a random key")
+			visitorSecret = make([]byte, 32)
+			if _, err := rand.Read(visitorSecret); err != nil {
+				log.Fatal("Failed to generate visitor cookie key:", err)
+			}
+		}
+	})
+	return visitorSecret
+}
+
+// signVisitorID trả về giá trị cookie "<id>.<chữ ký>"
+func signVisitorID(id string) string {
+	mac := hmac.New(sha256.New, visitorKey())
+	mac.Write([]byte(id))
+	return id + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verifyVisitorCookie kiểm tra chữ ký cookie và trả về id khách
+func verifyVisitorCookie(value string) (string, bool) {
+	id, _, ok := strings.Cut(value, ".")
+	if !ok || id == "" {
+		return "", false
+	}
+	if !hmac.Equal([]byte(signVisitorID(id)), []byte(value)) {
+		return "", false
+	}
+	return id, true
+}
+
+// RecommendedProduct là một sản phẩm gợi ý kèm lý do và điểm
+type RecommendedProduct struct {
+	models.Product
+	Reason string  `json:"reason"`
+	Score  float64 `json:"score"`
+}
+
+// interactionSubject xác định người tương tác: người dùng đã đăng nhập hoặc khách theo cookie
+// visitor_id. Khách chưa có cookie hợp lệ được cấp cookie mới nhưng lượt xem đầu tiên không được
+// ghi nhận, để client bỏ cookie ở mỗi request không tạo ra vô số khách giả. Phải gọi trước khi
+// ghi response.
+func interactionSubject(c *gin.Context) (string, *uuid.UUID) {
+	if userID := currentActorID(c); userID != nil {
+		return "user:" + userID.String(), userID
+	}
+	if value, err := c.Cookie(visitorCookie); err == nil {
+		if visitorID, ok := verifyVisitorCookie(value); ok {
+			return "visitor:" + visitorID, nil
+		}
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(visitorCookie, signVisitorID(uuid.NewString()), int(visitorCookieTTL.Seconds()), "/", "", config.SecureCookies(), true)
+	return "", nil
+}
+
+// recordPurchases ghi nhận các sản phẩm được mua cùng nhau trong một lần thanh toán
+func recordPurchases(tx *gorm.DB, userID uuid.UUID, items []models.CartItem, now time.Time) error {
+	seen := map[uuid.UUID]bool{}
+	var interactions []models.ProductInteraction
+	for _, item := range items {
+		if seen[item.Variant.ProductID] {
+			continue
+		}
+		seen[item.Variant.ProductID] = true
+		interactions = append(interactions, models.ProductInteraction{
+			ID:         uuid.New(),
+			SubjectKey: "user:" + userID.String(),
+			UserID:     &userID,
+			ProductID:  item.Variant.ProductID,
+			Kind:       models.InteractionPurchase,
+			CreatedAt:  now,
+		})
+	}
+	if len(interactions) == 0 {
+		return nil
+	}
+	return tx.Create(&interactions).Error
+}
+
+// recommendationLimit đọc tham số limit (mặc định 10, tối đa 50)
+func recommendationLimit(c *gin.Context) int {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit < 1 || limit > 50 {
+		return 10
+	}
+	return limit
+}
+
+// loadRecommendedProducts nạp các sản phẩm đang hiển thị theo thứ tự của ranked, tối đa limit sản phẩm
+func loadRecommendedProducts(ranked []models.ProductRecommendation, limit int) ([]RecommendedProduct, error) {
+	ids := make([]uuid.UUID, 0, len(ranked))
+	for _, recommendation := range ranked {
+		ids = append(ids, recommendation.RelatedProductID)
+	}
+
+	var products []models.Product
+	if err := config.DB.Scopes(models.VisibleProducts).Preload("Variants").
+		Where("id IN ?", ids).Find(&products).Error; err != nil {
+		return nil, err
+	}
+	byID := map[uuid.UUID]models.Product{}
+	for _, product := range products {
+		byID[product.ID] = product
+	}
+
+	result := []RecommendedProduct{}
+	for _, recommendation := range ranked {
+		product, ok := byID[recommendation.RelatedProductID]
+		if !ok {
+			continue
+		}
+		result = append(result, RecommendedProduct{Product: product, Reason: recommendation.Reason, Score: recommendation.Score})
+		if len(result) == limit {
+			break
+		}
+	}
+	return result, nil
+}
+
+// GetRelatedProducts trả về các sản phẩm liên quan đã được tính sẵn (mua cùng, xem cùng,
+// cùng danh mục và tầm giá). Sản phẩm mới chưa có trong bảng gợi ý được bổ sung bằng
+// các sản phẩm cùng danh mục có đánh giá cao.
+func GetRelatedProducts(c *gin.Context) {
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid product id", err.Error())
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+	limit := recommendationLimit(c)
+
+	var product models.Product
+	if err := config.DB.Scopes(models.VisibleProducts).First(&product, "id = ?", productID).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusNotFound, "Product not found", err.Error())
+		c.JSON(http.StatusNotFound, errResp)
+		return
+	}
+
+	// Lấy dư để bù các sản phẩm đã ngừng hiển thị sau lần tính gần nhất
+	var ranked []models.ProductRecommendation
+	if err := config.DB.Where("product_id = ?", productID).Order("score DESC").Limit(limit * 2).Find(&ranked).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to fetch related products", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	if len(ranked) == 0 {
+		var similar []models.Product
+		if err := config.DB.Scopes(models.VisibleProducts).Preload("Variants").
+			Where("category_id = ? AND id <> ?", product.CategoryID, product.ID).
+			Order("rating_average DESC, rating_count DESC").Limit(limit).Find(&similar).Error; err != nil {
+			errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to fetch related products", err.Error())
+			c.JSON(http.StatusInternalServerError, errResp)
+			return
+		}
+		result := []RecommendedProduct{}
+		for _, item := range similar {
+			result = append(result, RecommendedProduct{Product: item, Reason: models.RecommendationSimilar})
+		}
+		c.JSON(http.StatusOK, gin.H{"data": result})
+		return
+	}
+
+	result, err := loadRecommendedProducts(ranked, limit)
+	if err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to fetch related products", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": result})
+}
+
+// GetUserRecommendations trả về gợi ý cá nhân hoá: tổng hợp gợi ý của các sản phẩm người dùng
+// đã xem hoặc mua gần đây (mua có trọng số cao hơn), bỏ các sản phẩm đó. Người dùng chưa có
+// tương tác nhận các sản phẩm được đánh giá cao nhất.
+func GetUserRecommendations(c *gin.Context) {
+	userID := currentActorID(c)
+	if userID == nil {
+		errResp := models.NewErrorResponse(http.StatusUnauthorized, "Unauthorized", "Unauthorized")
+		c.JSON(http.StatusUnauthorized, errResp)
+		return
+	}
+	limit := recommendationLimit(c)
+	settings := recommend.LoadSettings()
+
+	var interactions []models.ProductInteraction
+	if err := config.DB.Where("user_id = ? AND created_at >= ?", *userID, time.Now().Add(-settings.Window)).
+		Order("created_at DESC").Limit(200).Find(&interactions).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to fetch recommendations", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	seeds := map[uuid.UUID]float64{}
+	for _, interaction := range interactions {
+		seeds[interaction.ProductID] = max(seeds[interaction.ProductID], recommend.Weight(interaction.Kind))
+	}
+
+	var ranked []models.ProductRecommendation
+	if len(seeds) > 0 {
+		seedIDs := make([]uuid.UUID, 0, len(seeds))
+		for id := range seeds {
+			seedIDs = append(seedIDs, id)
+		}
+
+		var candidates []models.ProductRecommendation
+		if err := config.DB.Where("product_id IN ? AND related_product_id NOT IN ?", seedIDs, seedIDs).
+			Find(&candidates).Error; err != nil {
+			errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to fetch recommendations", err.Error())
+			c.JSON(http.StatusInternalServerError, errResp)
+			return
+		}
+
+		// Cộng dồn điểm của cùng một sản phẩm được gợi ý từ nhiều sản phẩm gốc,
+		// lý do là lý do của đóng góp lớn nhất
+		totals := map[uuid.UUID]*models.ProductRecommendation{}
+		best := map[uuid.UUID]float64{}
+		for _, candidate := range candidates {
+			contribution := candidate.Score * seeds[candidate.ProductID]
+			total := totals[candidate.RelatedProductID]
+			if total == nil {
+				total = &models.ProductRecommendation{RelatedProductID: candidate.RelatedProductID}
+				totals[candidate.RelatedProductID] = total
+			}
+			total.Score += contribution
+			if contribution > best[candidate.RelatedProductID] {
+				best[candidate.RelatedProductID] = contribution
+				total.Reason = candidate.Reason
+			}
+		}
+		for _, total := range totals {
+			ranked = append(ranked, *total)
+		}
+		sort.Slice(ranked, func(i, j int) bool { return ranked[i].Score > ranked[j].Score })
+		if len(ranked) > limit*2 {
+			ranked = ranked[:limit*2]
+		}
+	}
+
+	if len(ranked) == 0 {
+		var popular []models.Product
+		query := config.DB.Scopes(models.VisibleProducts).Preload("Variants").
+			Order("rating_average DESC, rating_count DESC").Limit(limit)
+		if len(seeds) > 0 {
+			seedIDs := make([]uuid.UUID, 0, len(seeds))
+			for id := range seeds {
+				seedIDs = append(seedIDs, id)
+			}
+			query = query.Where("id NOT IN ?", seedIDs)
+		}
+		if err := query.Find(&popular).Error; err != nil {
+			errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to fetch recommendations", err.Error())
+			c.JSON(http.StatusInternalServerError, errResp)
+			return
+		}
+		result := []RecommendedProduct{}
+		for _, item := range popular {
+			result = append(result, RecommendedProduct{Product: item, Reason: models.RecommendationPopular})
+		}
+		c.JSON(http.StatusOK, gin.H{"data": result})
+		return
+	}
+
+	result, err := loadRecommendedProducts(ranked, limit)
+	if err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to fetch recommendations", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": result})
+}
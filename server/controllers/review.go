@@ -0,0 +1,402 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"time"
+
+	"ecommerce-project/config"
+	"ecommerce-project/media"
+	"ecommerce-project/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Số ảnh tối đa được đính kèm trong một đánh giá
+const maxReviewImages = 5
+
+// isVerifiedPurchase kiểm tra người dùng đã nhận được sản phẩm trong một đơn hàng đã giao hay chưa.
+func isVerifiedPurchase(db *gorm.DB, userID, productID uuid.UUID) (bool, error) {
+	var count int64
+	err := db.Model(&models.OrderLine{}).
+		Joins("JOIN orders ON orders.id = order_lines.order_id").
+		Where("orders.user_id = ? AND orders.status = ? AND order_lines.product_id = ?", userID, models.OrderStatusDelivered, productID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// reviewImage là ảnh đính kèm đã được kiểm tra, chờ upload lên storage
+type reviewImage struct {
+	file        *multipart.FileHeader
+	contentType string
+}
+
+// inspectReviewImages kiểm tra định dạng, dung lượng và số điểm ảnh của các ảnh đính kèm
+// trước khi upload, để ảnh không hợp lệ không bao giờ được lưu lên storage.
+func inspectReviewImages(files []*multipart.FileHeader) ([]reviewImage, error) {
+	images := make([]reviewImage, 0, len(files))
+	for _, file := range files {
+		src, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		info, err := media.Inspect(src, mediaLimits())
+		src.Close()
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, reviewImage{file: file, contentType: info.ContentType})
+	}
+	return images, nil
+}
+
+// uploadReviewImages upload các ảnh đính kèm tới reviews/<review id>/<thứ tự>.<đuôi file> và trả về
+// URL cùng key đã upload (để xoá khi không lưu được đánh giá).
+func uploadReviewImages(stor config.Storage, reviewID uuid.UUID, images []reviewImage) ([]string, []string, error) {
+	urls := make([]string, 0, len(images))
+	keys := make([]string, 0, len(images))
+	for i, image := range images {
+		src, err := image.file.Open()
+		if err != nil {
+			return nil, keys, err
+		}
+		key := fmt.Sprintf("reviews/%s/%d%s", reviewID, i+1, mediaExtensions[image.contentType])
+		url, err := stor.UploadStream(key, src, image.file.Size, image.contentType)
+		src.Close()
+		if err != nil {
+			return nil, keys, err
+		}
+		urls = append(urls, url)
+		keys = append(keys, key)
+	}
+	return urls, keys, nil
+}
+
+// refreshProductRating tính lại điểm trung bình và số lượng đánh giá đã duyệt của sản phẩm.
+// Cần được gọi trong transaction: dòng sản phẩm bị khoá (FOR UPDATE) trước khi tính, nên hai lần duyệt
+// đồng thời được tính lần lượt và lần sau luôn thấy kết quả của lần trước.
+func refreshProductRating(db *gorm.DB, productID uuid.UUID) error {
+	var product models.Product
+	if err := db.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").
+		First(&product, "id = ?", productID).Error; err != nil {
+		return err
+	}
+
+	var stats struct {
+		Average float64
+		Count   int
+	}
+	if err := db.Model(&models.Review{}).
+		Select("COALESCE(AVG(rating), 0) AS average, COUNT(*) AS count").
+		Where("product_id = ? AND status = ?", productID, models.ReviewStatusApproved).
+		Scan(&stats).Error; err != nil {
+		return err
+	}
+
+	return db.Model(&models.Product{}).
+		Where("id = ?", productID).
+		Updates(map[string]interface{}{
+			"rating_average": math.Round(stats.Average*100) / 100,
+			"rating_count":   stats.Count,
+		}).Error
+}
+
+// getRatingHistogram đếm số đánh giá đã duyệt theo từng mức sao của sản phẩm.
+func getRatingHistogram(productID uuid.UUID) (map[int]int64, error) {
+	var rows []struct {
+		Rating int
+		Count  int64
+	}
+	if err := config.DB.Model(&models.Review{}).
+		Select("rating, COUNT(*) AS count").
+		Where("product_id = ? AND status = ?", productID, models.ReviewStatusApproved).
+		Group("rating").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	histogram := map[int]int64{1: 0, 2: 0, 3: 0, 4: 0, 5: 0}
+	for _, row := range rows {
+		histogram[row.Rating] = row.Count
+	}
+	return histogram, nil
+}
+
+// CreateReview cho phép người dùng đã đăng nhập đánh giá một sản phẩm.
+// Đánh giá mới ở trạng thái chờ duyệt cho tới khi admin phê duyệt.
+func CreateReview(c *gin.Context) {
+	userIDInterface, exists := c.Get("userID")
+	if !exists {
+		errResp := models.NewErrorResponse(http.StatusUnauthorized, "Unauthorized", "Unauthorized")
+		c.JSON(http.StatusUnauthorized, errResp)
+		return
+	}
+	userID, ok := userIDInterface.(uuid.UUID)
+	if !ok {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Invalid user id", "Invalid user id")
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid product id", err.Error())
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+
+	var product models.Product
+	if err := config.DB.Scopes(models.VisibleProducts).First(&product, "id = ?", productID).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusNotFound, "Product not found", err.Error())
+		c.JSON(http.StatusNotFound, errResp)
+		return
+	}
+
+	var input models.CreateReviewInput
+	if err := c.ShouldBind(&input); err != nil {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid request payload", err.Error())
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+
+	// Mỗi người dùng chỉ được đánh giá một lần cho mỗi sản phẩm
+	var count int64
+	if err := config.DB.Model(&models.Review{}).
+		Where("product_id = ? AND user_id = ?", productID, userID).
+		Count(&count).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to check existing review", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+	if count > 0 {
+		errResp := models.NewErrorResponse(http.StatusConflict, "You have already reviewed this product")
+		c.JSON(http.StatusConflict, errResp)
+		return
+	}
+
+	// Kiểm tra ảnh đính kèm (nếu có) trước khi upload lên storage
+	var images []reviewImage
+	if form, err := c.MultipartForm(); err == nil {
+		files := form.File["images"]
+		if len(files) > maxReviewImages {
+			errResp := models.NewErrorResponse(http.StatusBadRequest, "Too many images", "A review can have at most "+strconv.Itoa(maxReviewImages)+" images")
+			c.JSON(http.StatusBadRequest, errResp)
+			return
+		}
+		images, err = inspectReviewImages(files)
+		if err != nil {
+			if !respondMediaError(c, err) {
+				errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid review image", err.Error())
+				c.JSON(http.StatusBadRequest, errResp)
+			}
+			return
+		}
+	}
+
+	verified, err := isVerifiedPurchase(config.DB, userID, productID)
+	if err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to check purchase", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	review := models.Review{
+		ID:               uuid.New(),
+		ProductID:        productID,
+		UserID:           userID,
+		Rating:           input.Rating,
+		Title:            input.Title,
+		Body:             input.Body,
+		ImageURLs:        []string{},
+		VerifiedPurchase: verified,
+		Status:           models.ReviewStatusPending,
+		CreatedAt:        time.Now(),
+		UpdatedAt:        time.Now(),
+	}
+
+	var stor config.Storage
+	var imageKeys []string
+	if len(images) > 0 {
+		stor, err = config.GetStorage()
+		if err != nil {
+			errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to get storage client", err.Error())
+			c.JSON(http.StatusInternalServerError, errResp)
+			return
+		}
+		review.ImageURLs, imageKeys, err = uploadReviewImages(stor, review.ID, images)
+		if err != nil {
+			deleteStorageKeys(stor, imageKeys...)
+			errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to upload review image", err.Error())
+			c.JSON(http.StatusInternalServerError, errResp)
+			return
+		}
+	}
+
+	if err := config.DB.Create(&review).Error; err != nil {
+		// Đánh giá không được lưu: xoá các ảnh vừa upload
+		if stor != nil {
+			deleteStorageKeys(stor, imageKeys...)
+		}
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			errResp := models.NewErrorResponse(http.StatusConflict, "You have already reviewed this product")
+			c.JSON(http.StatusConflict, errResp)
+			return
+		}
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to create review", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	c.JSON(http.StatusCreated, review)
+}
+
+// GetProductReviews trả về danh sách đánh giá đã được duyệt của một sản phẩm (có phân trang).
+func GetProductReviews(c *gin.Context) {
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid product id", err.Error())
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", "10"))
+	if err != nil || pageSize < 1 {
+		pageSize = 10
+	}
+	offset := (page - 1) * pageSize
+
+	query := config.DB.Model(&models.Review{}).
+		Where("product_id = ? AND status = ?", productID, models.ReviewStatusApproved)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to count reviews", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	var reviews []models.Review
+	if err := query.Order("created_at DESC").Offset(offset).Limit(pageSize).Find(&reviews).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to fetch reviews", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	totalPages := int(math.Ceil(float64(total) / float64(pageSize)))
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": reviews,
+		"pagination": gin.H{
+			"total":       total,
+			"page":        page,
+			"page_size":   pageSize,
+			"total_pages": totalPages,
+		},
+	})
+}
+
+// GetReviewsForModeration trả về danh sách đánh giá cho admin, lọc theo trạng thái (mặc định: pending).
+func GetReviewsForModeration(c *gin.Context) {
+	status := c.DefaultQuery("status", models.ReviewStatusPending)
+	switch status {
+	case models.ReviewStatusPending, models.ReviewStatusApproved, models.ReviewStatusHidden:
+	default:
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid status", status)
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if err != nil || pageSize < 1 {
+		pageSize = 20
+	}
+	offset := (page - 1) * pageSize
+
+	query := config.DB.Model(&models.Review{}).Where("status = ?", status)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to count reviews", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	var reviews []models.Review
+	if err := query.Order("created_at ASC").Offset(offset).Limit(pageSize).Find(&reviews).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to fetch reviews", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	totalPages := int(math.Ceil(float64(total) / float64(pageSize)))
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": reviews,
+		"pagination": gin.H{
+			"total":       total,
+			"page":        page,
+			"page_size":   pageSize,
+			"total_pages": totalPages,
+		},
+	})
+}
+
+// ModerateReview cho phép admin duyệt hoặc ẩn một đánh giá,
+// sau đó cập nhật lại điểm đánh giá tổng hợp của sản phẩm.
+func ModerateReview(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid review id", err.Error())
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+
+	var input models.ModerateReviewInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid request payload", err.Error())
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+
+	var review models.Review
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&review, "id = ?", id).Error; err != nil {
+			return err
+		}
+
+		review.Status = input.Status
+		review.UpdatedAt = time.Now()
+		if err := tx.Save(&review).Error; err != nil {
+			return err
+		}
+
+		return refreshProductRating(tx, review.ProductID)
+	})
+	if err == gorm.ErrRecordNotFound {
+		errResp := models.NewErrorResponse(http.StatusNotFound, "Review not found", err.Error())
+		c.JSON(http.StatusNotFound, errResp)
+		return
+	}
+	if err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to moderate review", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	c.JSON(http.StatusOK, review)
+}
@@ -0,0 +1,533 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"ecommerce-project/config"
+	"ecommerce-project/models"
+	"ecommerce-project/payment"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errOrderNotDelivered       = errors.New("only delivered orders can be returned")
+	errReturnWindowClosed      = errors.New("return window has closed")
+	errInvalidReturnTransition = errors.New("return cannot move to this status")
+	errRefundExceedsBalance    = errors.New("refund exceeds the refundable amount")
+)
+
+// returnLineError cho biết một dòng trả hàng không thuộc đơn hoặc vượt quá số lượng còn được trả
+type returnLineError struct {
+	orderLineID string
+	reason      string
+}
+
+func (e returnLineError) Error() string {
+	return fmt.Sprintf("order line %s: %s", e.orderLineID, e.reason)
+}
+
+// returnWindow là thời hạn trả hàng tính từ lúc đơn được giao (RMA_RETURN_WINDOW, mặc định 14 ngày)
+func returnWindow() time.Duration {
+	return config.GetEnvDuration("RMA_RETURN_WINDOW", 14*24*time.Hour)
+}
+
+// roundAmount làm tròn số tiền tới đơn vị nhỏ nhất (2 chữ số thập phân) như cột numeric
+func roundAmount(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// preloadReturn nạp các dòng, lịch sử trạng thái và các lần hoàn tiền của yêu cầu trả hàng
+func preloadReturn(db *gorm.DB) *gorm.DB {
+	return db.Preload("Lines").
+		Preload("History", func(db *gorm.DB) *gorm.DB { return db.Order("created_at ASC") }).
+		Preload("Refunds", func(db *gorm.DB) *gorm.DB { return db.Order("created_at ASC") })
+}
+
+// recordReturnStatus ghi một bước chuyển trạng thái vào lịch sử của yêu cầu trả hàng
+func recordReturnStatus(tx *gorm.DB, returnID uuid.UUID, from, to string, actorID *uuid.UUID, note string) error {
+	return tx.Create(&models.ReturnStatusChange{
+		ID:         uuid.New(),
+		ReturnID:   returnID,
+		FromStatus: from,
+		ToStatus:   to,
+		ActorID:    actorID,
+		Note:       note,
+		CreatedAt:  time.Now(),
+	}).Error
+}
+
+// returnedQuantities trả về số lượng đã được yêu cầu trả (trừ các yêu cầu bị từ chối) theo từng dòng đơn hàng
+func returnedQuantities(tx *gorm.DB, orderID uuid.UUID) (map[uuid.UUID]int, error) {
+	var rows []struct {
+		OrderLineID uuid.UUID
+		Quantity    int
+	}
+	err := tx.Model(&models.ReturnLine{}).
+		Select("return_lines.order_line_id, SUM(return_lines.quantity) AS quantity").
+		Joins("JOIN return_requests ON return_requests.id = return_lines.return_id").
+		Where("return_requests.order_id = ? AND return_requests.status <> ?", orderID, models.ReturnStatusRejected).
+		Group("return_lines.order_line_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	quantities := make(map[uuid.UUID]int, len(rows))
+	for _, row := range rows {
+		quantities[row.OrderLineID] = row.Quantity
+	}
+	return quantities, nil
+}
+
+// CreateReturn cho phép khách yêu cầu trả các dòng của một đơn đã giao trong thời hạn trả hàng.
+// Số lượng trả của mỗi dòng không vượt quá số đã mua trừ đi các yêu cầu trước đó (trừ yêu cầu bị từ chối).
+func CreateReturn(c *gin.Context) {
+	userID := *currentActorID(c)
+
+	orderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid order id", err.Error())
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+
+	var input models.CreateReturnInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid request payload", err.Error())
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+
+	rma := models.ReturnRequest{
+		ID:        uuid.New(),
+		OrderID:   orderID,
+		UserID:    userID,
+		Status:    models.ReturnStatusRequested,
+		Reason:    input.Reason,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		// Khoá đơn hàng để hai yêu cầu trả đồng thời không cùng vượt số lượng đã mua
+		var order models.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Lines").
+			First(&order, "id = ? AND user_id = ?", orderID, userID).Error; err != nil {
+			return err
+		}
+		if order.Status != models.OrderStatusDelivered || order.DeliveredAt == nil {
+			return errOrderNotDelivered
+		}
+		if time.Since(*order.DeliveredAt) > returnWindow() {
+			return errReturnWindowClosed
+		}
+
+		returned, err := returnedQuantities(tx, order.ID)
+		if err != nil {
+			return err
+		}
+		lines := make(map[uuid.UUID]models.OrderLine, len(order.Lines))
+		for _, line := range order.Lines {
+			lines[line.ID] = line
+		}
+
+		requested := map[uuid.UUID]int{}
+		for _, lineInput := range input.Lines {
+			lineID, _ := uuid.Parse(lineInput.OrderLineID)
+			line, ok := lines[lineID]
+			if !ok {
+				return returnLineError{orderLineID: lineInput.OrderLineID, reason: "not part of this order"}
+			}
+			requested[line.ID] += lineInput.Quantity
+			if requested[line.ID]+returned[line.ID] > line.Quantity {
+				return returnLineError{orderLineID: lineInput.OrderLineID, reason: "quantity exceeds the returnable quantity"}
+			}
+		}
+
+		for _, line := range order.Lines {
+			quantity, ok := requested[line.ID]
+			if !ok {
+				continue
+			}
+			rma.Lines = append(rma.Lines, models.ReturnLine{
+				ID:          uuid.New(),
+				ReturnID:    rma.ID,
+				OrderLineID: line.ID,
+				VariantID:   line.VariantID,
+				Quantity:    quantity,
+				UnitPrice:   line.UnitPrice,
+			})
+			rma.RefundableAmount += line.UnitPrice * float64(quantity)
+		}
+		rma.RefundableAmount = roundAmount(rma.RefundableAmount)
+
+		if err := tx.Create(&rma).Error; err != nil {
+			return err
+		}
+		return recordReturnStatus(tx, rma.ID, "", rma.Status, &userID, input.Reason)
+	})
+	var lineErr returnLineError
+	switch {
+	case err == gorm.ErrRecordNotFound:
+		errResp := models.NewErrorResponse(http.StatusNotFound, "Order not found", err.Error())
+		c.JSON(http.StatusNotFound, errResp)
+		return
+	case err == errOrderNotDelivered || err == errReturnWindowClosed:
+		errResp := models.NewErrorResponse(http.StatusConflict, "Order cannot be returned", err.Error())
+		c.JSON(http.StatusConflict, errResp)
+		return
+	case errors.As(err, &lineErr):
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid return line", lineErr.Error())
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	case err != nil:
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to create return", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	if err := preloadReturn(config.DB).First(&rma, "id = ?", rma.ID).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to fetch return", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+	c.JSON(http.StatusCreated, rma)
+}
+
+// GetMyReturns trả về các yêu cầu trả hàng của người dùng hiện tại
+func GetMyReturns(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var returns []models.ReturnRequest
+	if err := preloadReturn(config.DB).Where("user_id = ?", userID).Order("created_at DESC").Find(&returns).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to fetch returns", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	c.JSON(http.StatusOK, returns)
+}
+
+// GetMyReturn trả về chi tiết một yêu cầu trả hàng (kèm lịch sử trạng thái) của người dùng hiện tại
+func GetMyReturn(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var rma models.ReturnRequest
+	if err := preloadReturn(config.DB).First(&rma, "id = ? AND user_id = ?", c.Param("id"), userID).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusNotFound, "Return not found", err.Error())
+		c.JSON(http.StatusNotFound, errResp)
+		return
+	}
+
+	c.JSON(http.StatusOK, rma)
+}
+
+// AdminGetReturns trả về danh sách yêu cầu trả hàng cho admin, lọc theo trạng thái (mặc định: requested)
+func AdminGetReturns(c *gin.Context) {
+	status := c.DefaultQuery("status", models.ReturnStatusRequested)
+	switch status {
+	case models.ReturnStatusRequested, models.ReturnStatusApproved, models.ReturnStatusRejected,
+		models.ReturnStatusReceived, models.ReturnStatusPartiallyRefunded, models.ReturnStatusRefunded:
+	default:
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid status", status)
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if err != nil || pageSize < 1 {
+		pageSize = 20
+	}
+	offset := (page - 1) * pageSize
+
+	query := config.DB.Model(&models.ReturnRequest{}).Where("status = ?", status)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to count returns", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	var returns []models.ReturnRequest
+	if err := query.Preload("Lines").Order("created_at ASC").Offset(offset).Limit(pageSize).Find(&returns).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to fetch returns", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	totalPages := int(math.Ceil(float64(total) / float64(pageSize)))
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": returns,
+		"pagination": gin.H{
+			"total":       total,
+			"page":        page,
+			"page_size":   pageSize,
+			"total_pages": totalPages,
+		},
+	})
+}
+
+// AdminGetReturn trả về chi tiết một yêu cầu trả hàng kèm lịch sử trạng thái và các lần hoàn tiền
+func AdminGetReturn(c *gin.Context) {
+	var rma models.ReturnRequest
+	if err := preloadReturn(config.DB).First(&rma, "id = ?", c.Param("id")).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusNotFound, "Return not found", err.Error())
+		c.JSON(http.StatusNotFound, errResp)
+		return
+	}
+
+	c.JSON(http.StatusOK, rma)
+}
+
+// ApproveReturn duyệt yêu cầu trả hàng, khách có thể gửi hàng về
+func ApproveReturn(c *gin.Context) {
+	transitionReturn(c, models.ReturnStatusRequested, models.ReturnStatusApproved, nil)
+}
+
+// RejectReturn từ chối yêu cầu trả hàng; số lượng của yêu cầu được trả lại cho các lần yêu cầu sau
+func RejectReturn(c *gin.Context) {
+	transitionReturn(c, models.ReturnStatusRequested, models.ReturnStatusRejected, nil)
+}
+
+// ReceiveReturn xác nhận đã nhận hàng trả về và nhập lại kho qua StockMovement
+func ReceiveReturn(c *gin.Context) {
+	transitionReturn(c, models.ReturnStatusApproved, models.ReturnStatusReceived, func(tx *gorm.DB, rma *models.ReturnRequest) error {
+		var lines []models.ReturnLine
+		if err := tx.Where("return_id = ?", rma.ID).Order("variant_id").Find(&lines).Error; err != nil {
+			return err
+		}
+		for _, line := range lines {
+			if _, err := changeVariantStock(tx, line.VariantID, line.Quantity, models.StockMovementReturn, &rma.ID, currentActorID(c)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// transitionReturn chuyển yêu cầu trả hàng từ trạng thái from sang to (khoá dòng trong tx),
+// chạy apply nếu có và ghi lịch sử trạng thái.
+func transitionReturn(c *gin.Context, from, to string, apply func(tx *gorm.DB, rma *models.ReturnRequest) error) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid return id", err.Error())
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+
+	var input models.ReviewReturnInput
+	if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid request payload", err.Error())
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+
+	var rma models.ReturnRequest
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&rma, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if rma.Status != from {
+			return errInvalidReturnTransition
+		}
+		if apply != nil {
+			if err := apply(tx, &rma); err != nil {
+				return err
+			}
+		}
+
+		rma.Status = to
+		rma.UpdatedAt = time.Now()
+		if err := tx.Model(&rma).Updates(map[string]interface{}{
+			"status":     rma.Status,
+			"updated_at": rma.UpdatedAt,
+		}).Error; err != nil {
+			return err
+		}
+		return recordReturnStatus(tx, rma.ID, from, to, currentActorID(c), input.Note)
+	})
+	respondReturn(c, rma.ID, err, "Failed to update return")
+}
+
+// RefundReturn hoàn tiền (một phần hoặc toàn bộ số tiền còn lại) cho yêu cầu trả hàng đã nhận hàng.
+// Khoản hoàn được giữ chỗ ở trạng thái pending trước khi gọi nhà cung cấp thanh toán (ngoài transaction),
+// nên hai yêu cầu đồng thời không thể hoàn quá số tiền. Nếu không ghi được kết quả (thành công hoặc thất bại),
+// khoản hoàn giữ trạng thái pending và vẫn được tính vào số đã hoàn; khoản pending cũ hơn
+// REFUND_PENDING_TIMEOUT (mặc định 10 phút) được gửi lại nhà cung cấp với cùng idempotency key ở lần gọi
+// sau, trước khi tạo khoản hoàn mới, nên không bị hoàn trùng và không chặn mãi số tiền còn lại.
+func RefundReturn(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid return id", err.Error())
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+
+	var input models.RefundReturnInput
+	if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid request payload", err.Error())
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+
+	var order models.Order
+	refund := models.Refund{
+		ID:        uuid.New(),
+		ReturnID:  id,
+		Status:    models.RefundStatusPending,
+		ActorID:   currentActorID(c),
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		var rma models.ReturnRequest
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&rma, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if rma.Status != models.ReturnStatusReceived && rma.Status != models.ReturnStatusPartiallyRefunded {
+			return errInvalidReturnTransition
+		}
+		if err := tx.First(&order, "id = ?", rma.OrderID).Error; err != nil {
+			return err
+		}
+
+		// Khoản hoàn bị kẹt ở pending (không ghi được kết quả) được thử lại thay vì tạo khoản mới
+		var stale models.Refund
+		err := tx.Where("return_id = ? AND status = ? AND updated_at < ?",
+			rma.ID, models.RefundStatusPending, time.Now().Add(-config.GetEnvDuration("REFUND_PENDING_TIMEOUT", 10*time.Minute))).
+			Order("created_at ASC").First(&stale).Error
+		if err == nil {
+			refund = stale
+			refund.UpdatedAt = time.Now()
+			return tx.Model(&refund).Update("updated_at", refund.UpdatedAt).Error
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		var reserved float64
+		if err := tx.Model(&models.Refund{}).
+			Where("return_id = ? AND status IN ?", rma.ID, []string{models.RefundStatusPending, models.RefundStatusSucceeded}).
+			Select("COALESCE(SUM(amount), 0)").Scan(&reserved).Error; err != nil {
+			return err
+		}
+		remaining := roundAmount(rma.RefundableAmount - reserved)
+
+		refund.Amount = remaining
+		if input.Amount != nil {
+			refund.Amount = roundAmount(*input.Amount)
+		}
+		if refund.Amount <= 0 || refund.Amount > remaining {
+			return errRefundExceedsBalance
+		}
+		refund.Provider = order.PaymentProvider
+		return tx.Create(&refund).Error
+	})
+	if err == errRefundExceedsBalance {
+		errResp := models.NewErrorResponse(http.StatusConflict, "Invalid refund amount", err.Error())
+		c.JSON(http.StatusConflict, errResp)
+		return
+	}
+	if err != nil {
+		respondReturn(c, id, err, "Failed to refund return")
+		return
+	}
+
+	provider, err := payment.NewProvider(order.PaymentProvider)
+	if err == nil {
+		refund.ProviderRef, err = provider.Refund(order.PaymentReference, refund.ID, refund.Amount)
+	}
+	if err != nil {
+		refund.Error = err.Error()
+		if dbErr := config.DB.Model(&refund).Updates(map[string]interface{}{
+			"status":     models.RefundStatusFailed,
+			"error":      refund.Error,
+			"updated_at": time.Now(),
+		}).Error; dbErr != nil {
+			log.Printf("rma: failed to mark refund %s as failed, it will be retried after the pending timeout: %v", refund.ID, dbErr)
+		}
+		errResp := models.NewErrorResponse(http.StatusBadGateway, "Payment provider refused the refund", err.Error())
+		c.JSON(http.StatusBadGateway, errResp)
+		return
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		var rma models.ReturnRequest
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&rma, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&refund).Updates(map[string]interface{}{
+			"status":       models.RefundStatusSucceeded,
+			"provider_ref": refund.ProviderRef,
+			"updated_at":   time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+
+		from := rma.Status
+		rma.RefundedAmount = roundAmount(rma.RefundedAmount + refund.Amount)
+		rma.Status = models.ReturnStatusPartiallyRefunded
+		if rma.RefundedAmount >= rma.RefundableAmount {
+			rma.Status = models.ReturnStatusRefunded
+		}
+		rma.UpdatedAt = time.Now()
+		if err := tx.Model(&rma).Updates(map[string]interface{}{
+			"refunded_amount": rma.RefundedAmount,
+			"status":          rma.Status,
+			"updated_at":      rma.UpdatedAt,
+		}).Error; err != nil {
+			return err
+		}
+		note := fmt.Sprintf("refunded %.2f (%s)", refund.Amount, refund.ProviderRef)
+		if input.Note != "" {
+			note += ": " + input.Note
+		}
+		return recordReturnStatus(tx, rma.ID, from, rma.Status, refund.ActorID, note)
+	})
+	if err != nil {
+		log.Printf("rma: refund %s succeeded at provider (%s) but could not be recorded, it will be retried after the pending timeout: %v", refund.ID, refund.ProviderRef, err)
+	}
+	respondReturn(c, id, err, "Refund was issued but could not be recorded")
+}
+
+// respondReturn trả về yêu cầu trả hàng sau khi cập nhật, hoặc lỗi tương ứng
+func respondReturn(c *gin.Context, id uuid.UUID, err error, failure string) {
+	if err == gorm.ErrRecordNotFound {
+		errResp := models.NewErrorResponse(http.StatusNotFound, "Return not found", err.Error())
+		c.JSON(http.StatusNotFound, errResp)
+		return
+	}
+	if err == errInvalidReturnTransition {
+		errResp := models.NewErrorResponse(http.StatusConflict, "Invalid status transition", err.Error())
+		c.JSON(http.StatusConflict, errResp)
+		return
+	}
+	if err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, failure, err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	var rma models.ReturnRequest
+	if err := preloadReturn(config.DB).First(&rma, "id = ?", id).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to fetch return", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+	c.JSON(http.StatusOK, rma)
+}
@@ -0,0 +1,301 @@
+package controllers
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"ecommerce-project/config"
+	"ecommerce-project/models"
+	"ecommerce-project/rbac"
+	"ecommerce-project/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// validatePermissions trả về quyền đầu tiên không có trong danh mục (rỗng nếu tất cả hợp lệ)
+func validatePermissions(permissions []string) string {
+	for _, permission := range permissions {
+		if !rbac.IsValidPermission(permission) {
+			return permission
+		}
+	}
+	return ""
+}
+
+// GetPermissions (admin) trả về danh mục quyền
+func GetPermissions(c *gin.Context) {
+	type Permission struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+
+	permissions := make([]Permission, 0, len(rbac.Permissions))
+	for name, description := range rbac.Permissions {
+		permissions = append(permissions, Permission{Name: name, Description: description})
+	}
+	sort.Slice(permissions, func(i, j int) bool { return permissions[i].Name < permissions[j].Name })
+
+	c.JSON(http.StatusOK, permissions)
+}
+
+// GetRoles (admin) liệt kê các vai trò nhân viên
+func GetRoles(c *gin.Context) {
+	var roles []models.Role
+	if err := config.DB.Order("name ASC").Find(&roles).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to fetch roles", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	c.JSON(http.StatusOK, roles)
+}
+
+// CreateRole (admin) tạo vai trò tuỳ chỉnh từ các quyền trong danh mục
+func CreateRole(c *gin.Context) {
+	var input models.RoleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.HandleValidationError(c, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(input); err != nil {
+		utils.HandleValidationError(c, err)
+		return
+	}
+
+	if input.Name == "" {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Role name is required")
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+	if invalid := validatePermissions(input.Permissions); invalid != "" {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Unknown permission", invalid)
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+
+	var existing models.Role
+	if err := config.DB.First(&existing, "name = ?", input.Name).Error; err == nil {
+		errResp := models.NewErrorResponse(http.StatusConflict, "Role already exists")
+		c.JSON(http.StatusConflict, errResp)
+		return
+	}
+
+	role := models.Role{
+		Name:        input.Name,
+		Description: input.Description,
+		Permissions: input.Permissions,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+	if err := config.DB.Create(&role).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to create role", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	c.JSON(http.StatusCreated, role)
+}
+
+// bumpRoleHolders tăng TokenVersion của những người dùng có vai trò roleName để access token
+// cũ (chứa quyền cũ) bị từ chối; client dùng refresh token để lấy access token với quyền mới.
+func bumpRoleHolders(tx *gorm.DB, roleName string) error {
+	return tx.Model(&models.User{}).
+		Where("id IN (?)", tx.Model(&models.UserRole{}).Select("user_id").Where("role_name = ?", roleName)).
+		Update("token_version", gorm.Expr("token_version + 1")).Error
+}
+
+// UpdateRole (admin) cập nhật mô tả và quyền của vai trò tuỳ chỉnh. Vai trò có sẵn
+// được đồng bộ từ danh mục khi khởi động nên không được sửa qua API.
+func UpdateRole(c *gin.Context) {
+	var role models.Role
+	if err := config.DB.First(&role, "name = ?", c.Param("name")).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusNotFound, "Role not found", err.Error())
+		c.JSON(http.StatusNotFound, errResp)
+		return
+	}
+	if role.System {
+		errResp := models.NewErrorResponse(http.StatusConflict, "Built-in roles cannot be modified")
+		c.JSON(http.StatusConflict, errResp)
+		return
+	}
+
+	var input models.RoleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.HandleValidationError(c, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(input); err != nil {
+		utils.HandleValidationError(c, err)
+		return
+	}
+
+	if invalid := validatePermissions(input.Permissions); invalid != "" {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Unknown permission", invalid)
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+
+	role.Description = input.Description
+	role.Permissions = input.Permissions
+	role.UpdatedAt = time.Now()
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&role).Error; err != nil {
+			return err
+		}
+		return bumpRoleHolders(tx, role.Name)
+	})
+	if err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to update role", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	c.JSON(http.StatusOK, role)
+}
+
+// DeleteRole (admin) xoá vai trò tuỳ chỉnh và gỡ vai trò khỏi mọi người dùng
+func DeleteRole(c *gin.Context) {
+	var role models.Role
+	if err := config.DB.First(&role, "name = ?", c.Param("name")).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusNotFound, "Role not found", err.Error())
+		c.JSON(http.StatusNotFound, errResp)
+		return
+	}
+	if role.System {
+		errResp := models.NewErrorResponse(http.StatusConflict, "Built-in roles cannot be deleted")
+		c.JSON(http.StatusConflict, errResp)
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := bumpRoleHolders(tx, role.Name); err != nil {
+			return err
+		}
+		if err := tx.Where("role_name = ?", role.Name).Delete(&models.UserRole{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&role).Error
+	})
+	if err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to delete role", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role deleted successfully"})
+}
+
+// GetUserRoles (admin) trả về vai trò nhân viên và quyền hiện tại của một người dùng
+func GetUserRoles(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid user id", err.Error())
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, "id = ?", userID).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusNotFound, "User not found", err.Error())
+		c.JSON(http.StatusNotFound, errResp)
+		return
+	}
+
+	var roles []string
+	if err := config.DB.Model(&models.UserRole{}).Where("user_id = ?", user.ID).
+		Order("role_name ASC").Pluck("role_name", &roles).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to fetch roles", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	permissions, err := rbac.UserPermissions(config.DB, user)
+	if err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to resolve permissions", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"role":        user.Role,
+		"roles":       roles,
+		"permissions": permissions,
+	})
+}
+
+// AssignUserRoles (admin) thay thế toàn bộ vai trò nhân viên của người dùng. TokenVersion
+// được tăng để quyền mới có hiệu lực ngay ở lần refresh token tiếp theo.
+func AssignUserRoles(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid user id", err.Error())
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+
+	var input models.AssignRolesInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.HandleValidationError(c, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(input); err != nil {
+		utils.HandleValidationError(c, err)
+		return
+	}
+
+	var count int64
+	if err := config.DB.Model(&models.Role{}).Where("name IN ?", input.Roles).Count(&count).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to fetch roles", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+	unique := map[string]bool{}
+	for _, name := range input.Roles {
+		unique[name] = true
+	}
+	if int(count) != len(unique) {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Unknown role")
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.User{}).Where("id = ?", userID).
+			Update("token_version", gorm.Expr("token_version + 1"))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		if err := tx.Where("user_id = ?", userID).Delete(&models.UserRole{}).Error; err != nil {
+			return err
+		}
+		for name := range unique {
+			if err := tx.Create(&models.UserRole{UserID: userID, RoleName: name, CreatedAt: time.Now()}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err == gorm.ErrRecordNotFound {
+		errResp := models.NewErrorResponse(http.StatusNotFound, "User not found", err.Error())
+		c.JSON(http.StatusNotFound, errResp)
+		return
+	}
+	if err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to assign roles", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Roles assigned successfully"})
+}
@@ -0,0 +1,166 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"ecommerce-project/config"
+	"ecommerce-project/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// GetSessions liệt kê các phiên đăng nhập còn hiệu lực của người dùng.
+// Mỗi phiên tương ứng với refresh token chưa bị thu hồi của một family.
+func GetSessions(c *gin.Context) {
+	userIDInterface, exists := c.Get("userID")
+	if !exists {
+		errResp := models.NewErrorResponse(http.StatusUnauthorized, "Unauthorized", "Unauthorized")
+		c.JSON(http.StatusUnauthorized, errResp)
+		return
+	}
+	userID, ok := userIDInterface.(uuid.UUID)
+	if !ok {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Invalid user id", "Invalid user id")
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+	currentSessionID, _ := c.Get("sessionID")
+
+	var tokens []models.RefreshToken
+	if err := config.DB.
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_used_at DESC").
+		Find(&tokens).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to fetch sessions", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	sessions := make([]models.SessionResponse, 0, len(tokens))
+	for _, token := range tokens {
+		sessions = append(sessions, models.SessionResponse{
+			ID:           token.FamilyID,
+			DeviceName:   token.DeviceName,
+			UserAgent:    token.UserAgent,
+			IPAddress:    token.IPAddress,
+			LastUsedAt:   token.LastUsedAt,
+			ExpiresAt:    token.ExpiresAt,
+			Current:      token.FamilyID == currentSessionID,
+			Impersonated: token.ImpersonatorID != nil,
+		})
+	}
+
+	c.JSON(http.StatusOK, sessions)
+}
+
+// RevokeSession thu hồi một phiên đăng nhập của người dùng theo id phiên.
+func RevokeSession(c *gin.Context) {
+	userIDInterface, exists := c.Get("userID")
+	if !exists {
+		errResp := models.NewErrorResponse(http.StatusUnauthorized, "Unauthorized", "Unauthorized")
+		c.JSON(http.StatusUnauthorized, errResp)
+		return
+	}
+	userID, ok := userIDInterface.(uuid.UUID)
+	if !ok {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Invalid user id", "Invalid user id")
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	sessionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid session id", err.Error())
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+
+	result := config.DB.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND family_id = ? AND revoked_at IS NULL", userID, sessionID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to revoke session", result.Error.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+	if result.RowsAffected == 0 {
+		errResp := models.NewErrorResponse(http.StatusNotFound, "Session not found")
+		c.JSON(http.StatusNotFound, errResp)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked successfully"})
+}
+
+// RevokeOtherSessions thu hồi mọi phiên đăng nhập của người dùng trừ phiên hiện tại.
+func RevokeOtherSessions(c *gin.Context) {
+	userIDInterface, exists := c.Get("userID")
+	if !exists {
+		errResp := models.NewErrorResponse(http.StatusUnauthorized, "Unauthorized", "Unauthorized")
+		c.JSON(http.StatusUnauthorized, errResp)
+		return
+	}
+	userID, ok := userIDInterface.(uuid.UUID)
+	if !ok {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Invalid user id", "Invalid user id")
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+	currentSessionID, _ := c.Get("sessionID")
+
+	result := config.DB.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND family_id <> ? AND revoked_at IS NULL", userID, currentSessionID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to revoke sessions", result.Error.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Other sessions revoked successfully",
+		"revoked": result.RowsAffected,
+	})
+}
+
+// ForceLogoutUser (admin) thu hồi mọi phiên đăng nhập của một người dùng và tăng
+// TokenVersion để các access token đã cấp bị AuthMiddleware từ chối ngay lập tức.
+func ForceLogoutUser(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid user id", err.Error())
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.User{}).
+			Where("id = ?", userID).
+			Update("token_version", gorm.Expr("token_version + 1"))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		return tx.Model(&models.RefreshToken{}).
+			Where("user_id = ? AND revoked_at IS NULL", userID).
+			Update("revoked_at", time.Now()).Error
+	})
+	if err == gorm.ErrRecordNotFound {
+		errResp := models.NewErrorResponse(http.StatusNotFound, "User not found", err.Error())
+		c.JSON(http.StatusNotFound, errResp)
+		return
+	}
+	if err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to force logout user", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User logged out from all sessions"})
+}
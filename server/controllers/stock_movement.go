@@ -0,0 +1,56 @@
+package controllers
+
+import (
+	"time"
+
+	"ecommerce-project/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// changeVariantStock cộng delta vào tồn kho của variant (khoá dòng variant trong tx), ghi lại
+// StockMovement và phát thông báo có hàng trở lại nếu cần. Trả về errNegativeStock nếu không đủ hàng.
+// Variant đã bị xoá vẫn được cập nhật để hàng trả về không bị mất khỏi sổ kho.
+func changeVariantStock(tx *gorm.DB, variantID uuid.UUID, delta int, reason string, referenceID, actorID *uuid.UUID) (models.ProductVariant, error) {
+	var variant models.ProductVariant
+	if err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).First(&variant, "id = ?", variantID).Error; err != nil {
+		return variant, err
+	}
+
+	previousStock := variant.Stock
+	variant.Stock += delta
+	if variant.Stock < 0 {
+		return variant, errNegativeStock
+	}
+	variant.UpdatedAt = time.Now()
+
+	if err := tx.Unscoped().Model(&variant).Updates(map[string]interface{}{
+		"stock":      variant.Stock,
+		"updated_at": variant.UpdatedAt,
+	}).Error; err != nil {
+		return variant, err
+	}
+	if err := recordStockMovement(tx, variant, delta, reason, referenceID, actorID); err != nil {
+		return variant, err
+	}
+	return variant, notifyBackInStock(tx, variant, previousStock)
+}
+
+// recordStockMovement ghi một dòng vào sổ kho; variant.Stock là tồn kho sau thay đổi
+func recordStockMovement(tx *gorm.DB, variant models.ProductVariant, delta int, reason string, referenceID, actorID *uuid.UUID) error {
+	if delta == 0 {
+		return nil
+	}
+	return tx.Create(&models.StockMovement{
+		ID:          uuid.New(),
+		VariantID:   variant.ID,
+		Delta:       delta,
+		StockAfter:  variant.Stock,
+		Reason:      reason,
+		ReferenceID: referenceID,
+		ActorID:     actorID,
+		CreatedAt:   time.Now(),
+	}).Error
+}
@@ -0,0 +1,182 @@
+package controllers
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"ecommerce-project/config"
+	"ecommerce-project/models"
+	"ecommerce-project/rbac"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// trashModels ánh xạ giá trị type của thùng rác sang model tương ứng
+var trashModels = map[string]func() interface{}{
+	"products":   func() interface{} { return &[]models.Product{} },
+	"variants":   func() interface{} { return &[]models.ProductVariant{} },
+	"categories": func() interface{} { return &[]models.Category{} },
+}
+
+// trashPermissions là quyền cần có để xem từng loại mục trong thùng rác
+var trashPermissions = map[string]string{
+	"products":   rbac.ProductWrite,
+	"variants":   rbac.ProductWrite,
+	"categories": rbac.CategoryWrite,
+}
+
+// GetTrash (admin) liệt kê các sản phẩm, variant hoặc danh mục đã bị xoá mềm (query type),
+// xoá gần nhất trước. Các mục quá thời gian lưu giữ sẽ bị job dọn thùng rác xoá vĩnh viễn.
+func GetTrash(c *gin.Context) {
+	itemType := c.DefaultQuery("type", "products")
+	newItems, ok := trashModels[itemType]
+	if !ok {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid type", "type must be one of products, variants, categories")
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+
+	value, _ := c.Get("permissions")
+	granted, _ := value.([]string)
+	if !rbac.HasPermission(granted, trashPermissions[itemType]) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied", "missing_permission": trashPermissions[itemType]})
+		return
+	}
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if err != nil || pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+	offset := (page - 1) * pageSize
+
+	items := newItems()
+	query := config.DB.Unscoped().Model(items).Where("deleted_at IS NOT NULL")
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to count trash", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	if err := query.Order("deleted_at DESC").Offset(offset).Limit(pageSize).Find(items).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to fetch trash", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	totalPages := int(math.Ceil(float64(total) / float64(pageSize)))
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": items,
+		"pagination": gin.H{
+			"total":       total,
+			"page":        page,
+			"page_size":   pageSize,
+			"total_pages": totalPages,
+		},
+	})
+}
+
+// RestoreProduct (admin) khôi phục sản phẩm từ thùng rác cùng các variant bị xoá cùng lúc với nó.
+// Danh mục của sản phẩm phải còn tồn tại.
+func RestoreProduct(c *gin.Context) {
+	id := c.Param("id")
+
+	var product models.Product
+	if err := config.DB.Unscoped().Where("deleted_at IS NOT NULL").First(&product, "id = ?", id).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusNotFound, "Deleted product not found", err.Error())
+		c.JSON(http.StatusNotFound, errResp)
+		return
+	}
+
+	var categoryCount int64
+	if err := config.DB.Model(&models.Category{}).Where("id = ?", product.CategoryID).Count(&categoryCount).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to check category", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+	if categoryCount == 0 {
+		errResp := models.NewErrorResponse(http.StatusConflict, "Category of this product is deleted, restore it first")
+		c.JSON(http.StatusConflict, errResp)
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		// Chỉ khôi phục các variant bị xoá cùng sản phẩm, không khôi phục variant đã bị xoá riêng trước đó
+		if err := tx.Unscoped().Model(&models.ProductVariant{}).
+			Where("product_id = ? AND deleted_at = ?", product.ID, product.DeletedAt.Time).
+			Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Model(&models.Product{}).Where("id = ?", product.ID).Update("deleted_at", nil).Error
+	})
+	if err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to restore product", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	config.DB.Preload("Variants").Preload("Category").First(&product, "id = ?", product.ID)
+	c.JSON(http.StatusOK, product)
+}
+
+// RestoreVariant (admin) khôi phục variant từ thùng rác, sản phẩm của variant phải còn tồn tại
+func RestoreVariant(c *gin.Context) {
+	id := c.Param("id")
+
+	var variant models.ProductVariant
+	if err := config.DB.Unscoped().Where("deleted_at IS NOT NULL").First(&variant, "id = ?", id).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusNotFound, "Deleted variant not found", err.Error())
+		c.JSON(http.StatusNotFound, errResp)
+		return
+	}
+
+	var productCount int64
+	if err := config.DB.Model(&models.Product{}).Where("id = ?", variant.ProductID).Count(&productCount).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to check product", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+	if productCount == 0 {
+		errResp := models.NewErrorResponse(http.StatusConflict, "Product of this variant is deleted, restore it first")
+		c.JSON(http.StatusConflict, errResp)
+		return
+	}
+
+	if err := config.DB.Unscoped().Model(&models.ProductVariant{}).Where("id = ?", variant.ID).Update("deleted_at", nil).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to restore variant", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	variant.DeletedAt = gorm.DeletedAt{}
+	c.JSON(http.StatusOK, variant)
+}
+
+// RestoreCategory (admin) khôi phục danh mục từ thùng rác
+func RestoreCategory(c *gin.Context) {
+	id := c.Param("id")
+
+	result := config.DB.Unscoped().Model(&models.Category{}).
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		Update("deleted_at", nil)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Deleted category not found"})
+		return
+	}
+
+	var category models.Category
+	config.DB.First(&category, "id = ?", id)
+	c.JSON(http.StatusOK, category)
+}
@@ -0,0 +1,390 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"ecommerce-project/config"
+	"ecommerce-project/models"
+	"ecommerce-project/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Số mã khôi phục được cấp mỗi lần
+const recoveryCodeCount = 10
+
+var (
+	errInvalidTwoFactorCode = errors.New("invalid two-factor code")
+	errLoginThrottled       = errors.New("too many failed login attempts")
+)
+
+// verifySecondFactor kiểm tra mã TOTP hoặc mã khôi phục của user trong transaction tx.
+// Mã TOTP hợp lệ cập nhật TOTPLastStep, mã khôi phục hợp lệ bị đánh dấu đã dùng.
+func verifySecondFactor(tx *gorm.DB, user *models.User, code, recoveryCode string) error {
+	if code != "" {
+		step, ok := utils.ValidateTOTP(user.TOTPSecret, code, time.Now(), user.TOTPLastStep)
+		if !ok {
+			return errInvalidTwoFactorCode
+		}
+		user.TOTPLastStep = step
+		return tx.Model(user).Update("totp_last_step", step).Error
+	}
+
+	if recoveryCode != "" {
+		result := tx.Model(&models.RecoveryCode{}).
+			Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, utils.HashToken(utils.NormalizeRecoveryCode(recoveryCode))).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errInvalidTwoFactorCode
+		}
+		return nil
+	}
+
+	return errInvalidTwoFactorCode
+}
+
+// replaceRecoveryCodes xoá các mã khôi phục cũ và tạo bộ mã mới, trả về mã gốc để hiển thị một lần
+func replaceRecoveryCodes(tx *gorm.DB, userID uuid.UUID) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := utils.GenerateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		record := models.RecoveryCode{
+			ID:        uuid.New(),
+			UserID:    userID,
+			CodeHash:  utils.HashToken(utils.NormalizeRecoveryCode(code)),
+			CreatedAt: time.Now(),
+		}
+		if err := tx.Create(&record).Error; err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+// EnrollTwoFactor tạo secret TOTP mới và trả về URI otpauth:// để client hiển thị mã QR.
+// 2FA chỉ được bật sau khi người dùng xác nhận bằng ConfirmTwoFactor.
+func EnrollTwoFactor(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var user models.User
+	if err := config.DB.First(&user, "id = ?", userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if user.TOTPEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate secret"})
+		return
+	}
+
+	if err := config.DB.Model(&user).Updates(map[string]interface{}{
+		"totp_secret":    secret,
+		"totp_last_step": 0,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save secret"})
+		return
+	}
+
+	issuer := config.GetEnvDefault("APP_NAME", "PhoneStore")
+	c.JSON(http.StatusOK, gin.H{
+		"secret":           secret,
+		"provisioning_uri": utils.TOTPProvisioningURI(issuer, user.Email, secret),
+	})
+}
+
+// ConfirmTwoFactor bật 2FA khi người dùng nhập đúng mã TOTP đầu tiên và trả về bộ mã khôi phục.
+func ConfirmTwoFactor(c *gin.Context) {
+	type Request struct {
+		Code string `json:"code" validate:"required,len=6,numeric"`
+	}
+
+	var req Request
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.HandleValidationError(c, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(req); err != nil {
+		utils.HandleValidationError(c, err)
+		return
+	}
+
+	userID, _ := c.Get("userID")
+
+	var codes []string
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, "id = ?", userID).Error; err != nil {
+			return err
+		}
+		if user.TOTPEnabled || user.TOTPSecret == "" {
+			return errInvalidTwoFactorCode
+		}
+
+		if err := verifySecondFactor(tx, &user, req.Code, ""); err != nil {
+			return err
+		}
+		if err := tx.Model(&user).Update("totp_enabled", true).Error; err != nil {
+			return err
+		}
+
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err == errInvalidTwoFactorCode {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid code or two-factor enrollment not started"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable two-factor authentication"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Two-factor authentication enabled",
+		"recovery_codes": codes,
+	})
+}
+
+// DisableTwoFactor tắt 2FA sau khi kiểm tra mật khẩu và mã TOTP (hoặc mã khôi phục).
+// Khi chính sách REQUIRE_ADMIN_2FA được bật, admin không được tắt 2FA.
+func DisableTwoFactor(c *gin.Context) {
+	type Request struct {
+		Password     string `json:"password" validate:"required"`
+		Code         string `json:"code" validate:"omitempty,len=6,numeric"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+
+	var req Request
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.HandleValidationError(c, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(req); err != nil {
+		utils.HandleValidationError(c, err)
+		return
+	}
+
+	userID, _ := c.Get("userID")
+
+	var user models.User
+	if err := config.DB.First(&user, "id = ?", userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if user.Role == "admin" && config.RequireAdminTwoFactor() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Two-factor authentication is required for admin accounts"})
+		return
+	}
+
+	if !user.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := verifySecondFactor(tx, &user, req.Code, req.RecoveryCode); err != nil {
+			return err
+		}
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"totp_enabled":   false,
+			"totp_secret":    "",
+			"totp_last_step": 0,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error
+	})
+	if err == errInvalidTwoFactorCode {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid two-factor code"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// RegenerateRecoveryCodes cấp bộ mã khôi phục mới (bộ cũ bị vô hiệu hoá) sau khi kiểm tra mã TOTP.
+func RegenerateRecoveryCodes(c *gin.Context) {
+	type Request struct {
+		Code string `json:"code" validate:"required,len=6,numeric"`
+	}
+
+	var req Request
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.HandleValidationError(c, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(req); err != nil {
+		utils.HandleValidationError(c, err)
+		return
+	}
+
+	userID, _ := c.Get("userID")
+
+	var codes []string
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, "id = ?", userID).Error; err != nil {
+			return err
+		}
+		if !user.TOTPEnabled {
+			return errInvalidTwoFactorCode
+		}
+		if err := verifySecondFactor(tx, &user, req.Code, ""); err != nil {
+			return err
+		}
+
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err == errInvalidTwoFactorCode {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid two-factor code"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to regenerate recovery codes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// LoginTwoFactor là bước 2 của Login: đổi challenge token và mã TOTP (hoặc mã khôi phục)
+// lấy access token và refresh token. Challenge token chỉ bị tiêu thụ khi mã đúng.
+func LoginTwoFactor(c *gin.Context) {
+	type Request struct {
+		ChallengeToken string `json:"challenge_token" validate:"required"`
+		Code           string `json:"code" validate:"omitempty,len=6,numeric"`
+		RecoveryCode   string `json:"recovery_code"`
+		DeviceName     string `json:"device_name" validate:"max=100"`
+	}
+
+	var req Request
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.HandleValidationError(c, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(req); err != nil {
+		utils.HandleValidationError(c, err)
+		return
+	}
+
+	var user models.User
+	invalidCode := false
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		record, err := lockUserToken(tx, req.ChallengeToken, models.TokenPurposeTwoFactorChallenge)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, "id = ?", record.UserID).Error; err != nil {
+			return err
+		}
+		if user.DisabledAt != nil {
+			return errAccountDisabled
+		}
+
+		// Mã 2FA cũng bị giới hạn số lần thử sai theo tài khoản và IP
+		if !checkLoginAllowed(c, user.Email) {
+			return errLoginThrottled
+		}
+
+		err = verifySecondFactor(tx, &user, req.Code, req.RecoveryCode)
+		if err == errInvalidTwoFactorCode {
+			// Mã sai không làm thay đổi dữ liệu nào khác, transaction được commit để số lần thử
+			// của challenge được lưu lại; đủ TWO_FACTOR_MAX_ATTEMPTS lần thì challenge bị huỷ.
+			invalidCode = true
+			record.Attempts++
+			if record.Attempts >= config.GetEnvInt("TWO_FACTOR_MAX_ATTEMPTS", 5) {
+				now := time.Now()
+				record.UsedAt = &now
+			}
+			return tx.Model(&record).Updates(map[string]interface{}{
+				"attempts": record.Attempts,
+				"used_at":  record.UsedAt,
+			}).Error
+		}
+		if err != nil {
+			return err
+		}
+
+		// Challenge token chỉ dùng được một lần
+		now := time.Now()
+		return tx.Model(&record).Update("used_at", now).Error
+	})
+	if err == nil && invalidCode {
+		err = errInvalidTwoFactorCode
+	}
+	if err == errInvalidUserToken {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired challenge token"})
+		return
+	}
+	if err == errAccountDisabled {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account is disabled"})
+		return
+	}
+	if err == errLoginThrottled {
+		// checkLoginAllowed đã trả về 429
+		return
+	}
+	if err == errInvalidTwoFactorCode {
+		recordLoginFailure(c, user.Email, &user.ID)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid two-factor code"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify two-factor code"})
+		return
+	}
+
+	recordLoginSuccess(user.Email)
+
+	accessToken, refreshToken, err := startSession(c, user, req.DeviceName, true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate tokens"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"access_token":  accessToken,
+		"refresh_token": refreshToken,
+	})
+}
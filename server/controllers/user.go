@@ -1,11 +1,43 @@
 package controllers
 
 import (
+	"errors"
+	"fmt"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
 	"ecommerce-project/config"
 	"ecommerce-project/models"
-	"net/http"
+	"ecommerce-project/notification"
+	"ecommerce-project/utils"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// Kích thước tối đa của ảnh đại diện
+const maxAvatarSize = 2 << 20
+
+// Các định dạng ảnh đại diện được chấp nhận (theo nội dung file, không theo phần mở rộng)
+var avatarContentTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+var (
+	errEmailInUse      = errors.New("email already in use")
+	errInvalidAvatar   = errors.New("avatar must be a JPEG, PNG, GIF or WebP image up to 2MB")
+	errCurrentPassword = errors.New("current password is incorrect")
+	// Tài khoản chưa có mật khẩu phải gửi mã xác nhận nhận qua email
+	errConfirmationRequired = errors.New("confirmation token required")
 )
 
 func Me(c *gin.Context) {
@@ -21,5 +53,457 @@ func Me(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"user": user})
-}
\ No newline at end of file
+	c.JSON(http.StatusOK, gin.H{"user": models.NewUserResponse(user)})
+}
+
+// checkCurrentPassword kiểm tra mật khẩu hiện tại. Tài khoản chưa có mật khẩu (chỉ đăng nhập
+// qua mạng xã hội) phải chứng minh quyền sở hữu bằng mã xác nhận gửi qua email
+// (RequestAccountConfirmation); mã chỉ dùng được một lần.
+func checkCurrentPassword(user models.User, password, confirmationToken string) error {
+	if user.PasswordHash != "" {
+		if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+			return errCurrentPassword
+		}
+		return nil
+	}
+
+	if confirmationToken == "" {
+		return errConfirmationRequired
+	}
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		record, err := consumeUserToken(tx, confirmationToken, models.TokenPurposeAccountConfirmation)
+		if err != nil {
+			return err
+		}
+		if record.UserID != user.ID {
+			return errInvalidUserToken
+		}
+		return nil
+	})
+}
+
+// respondCurrentPassword trả lỗi của checkCurrentPassword cho client
+func respondCurrentPassword(c *gin.Context, err error) {
+	switch err {
+	case errCurrentPassword:
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Current password is incorrect"})
+	case errConfirmationRequired:
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Confirmation code required", "confirmation_required": true})
+	case errInvalidUserToken:
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired confirmation code"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify identity"})
+	}
+}
+
+// RequestAccountConfirmation gửi mã xác nhận tới email của tài khoản chưa có mật khẩu.
+// Mã dùng thay mật khẩu hiện tại khi đặt mật khẩu, đổi email hoặc xoá tài khoản.
+func RequestAccountConfirmation(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var user models.User
+	if err := config.DB.First(&user, "id = ?", userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if user.PasswordHash != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Use your current password to confirm this action"})
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		token, err := issueUserToken(tx, user.ID, models.TokenPurposeAccountConfirmation,
+			config.GetEnvDuration("ACCOUNT_CONFIRMATION_TTL", 15*time.Minute))
+		if err != nil {
+			return err
+		}
+
+		return notification.Enqueue(tx, notification.TemplateAccountConfirmation, user.Locale, user.Email, map[string]interface{}{
+			"Username": user.Username,
+			"Token":    token,
+		})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send confirmation code"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "A confirmation code has been sent to your email address"})
+}
+
+// uploadAvatar kiểm tra nội dung file là ảnh hợp lệ rồi upload qua config.Storage
+func uploadAvatar(file *multipart.FileHeader) (string, error) {
+	if file.Size > maxAvatarSize {
+		return "", errInvalidAvatar
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	header := make([]byte, 512)
+	n, _ := src.Read(header)
+	src.Close()
+	if !avatarContentTypes[http.DetectContentType(header[:n])] {
+		return "", errInvalidAvatar
+	}
+
+	stor, err := config.GetStorage()
+	if err != nil {
+		return "", err
+	}
+	return stor.UploadFile(file)
+}
+
+// deleteStoredFile xoá file cũ khỏi storage theo URL public, lỗi chỉ được ghi log
+func deleteStoredFile(url string) {
+	if url == "" {
+		return
+	}
+	stor, err := config.GetStorage()
+	if err != nil {
+		log.Println("Failed to get storage client:", err)
+		return
+	}
+	if err := stor.DeleteFile(path.Base(url)); err != nil {
+		log.Println("Failed to delete file:", err)
+	}
+}
+
+// UpdateMe cập nhật hồ sơ của người dùng hiện tại. Nhận JSON, hoặc multipart/form-data
+// khi có kèm ảnh đại diện (trường "avatar").
+func UpdateMe(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var input models.UpdateProfileInput
+	if err := c.ShouldBind(&input); err != nil {
+		utils.HandleValidationError(c, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(input); err != nil {
+		utils.HandleValidationError(c, err)
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, "id = ?", userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if input.FullName != nil {
+		updates["full_name"] = strings.TrimSpace(*input.FullName)
+	}
+	if input.PhoneNumber != nil {
+		updates["phone_number"] = *input.PhoneNumber
+	}
+	if input.Address != nil {
+		updates["address"] = *input.Address
+	}
+	if input.Locale != nil {
+		updates["locale"] = notification.NormalizeLocale(*input.Locale)
+	}
+
+	previousAvatar := ""
+	if file, err := c.FormFile("avatar"); err == nil {
+		url, err := uploadAvatar(file)
+		if err == errInvalidAvatar {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upload avatar"})
+			return
+		}
+		previousAvatar = user.AvatarURL
+		updates["avatar_url"] = url
+	}
+
+	if len(updates) > 0 {
+		updates["updated_at"] = time.Now()
+		if err := config.DB.Model(&user).Updates(updates).Error; err != nil {
+			// Ảnh vừa upload không được lưu vào hồ sơ nên xoá đi
+			if url, ok := updates["avatar_url"].(string); ok {
+				deleteStoredFile(url)
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
+			return
+		}
+	}
+	deleteStoredFile(previousAvatar)
+
+	if err := config.DB.First(&user, "id = ?", user.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load profile"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user": models.NewUserResponse(user)})
+}
+
+// ChangePassword đổi mật khẩu sau khi kiểm tra mật khẩu hiện tại
+// và thu hồi mọi phiên đăng nhập khác ngoài phiên hiện tại.
+func ChangePassword(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	sessionID, _ := c.Get("sessionID")
+
+	var input models.ChangePasswordInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.HandleValidationError(c, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(input); err != nil {
+		utils.HandleValidationError(c, err)
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, "id = ?", userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if err := checkCurrentPassword(user, input.CurrentPassword, input.ConfirmationToken); err != nil {
+		respondCurrentPassword(c, err)
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Password hashing failed"})
+		return
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"password_hash": string(hashedPassword),
+			"updated_at":    time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&models.RefreshToken{}).
+			Where("user_id = ? AND family_id <> ? AND revoked_at IS NULL", user.ID, sessionID).
+			Update("revoked_at", time.Now()).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password changed successfully"})
+}
+
+// RequestEmailChange lưu email mới vào PendingEmail và gửi link xác minh tới email mới.
+// Email đăng nhập chỉ thay đổi sau khi người dùng mở link (ConfirmEmailChange).
+func RequestEmailChange(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var input models.ChangeEmailInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.HandleValidationError(c, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(input); err != nil {
+		utils.HandleValidationError(c, err)
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, "id = ?", userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if err := checkCurrentPassword(user, input.Password, input.ConfirmationToken); err != nil {
+		respondCurrentPassword(c, err)
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.User{}).Where("email = ?", input.NewEmail).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return errEmailInUse
+		}
+
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"pending_email": input.NewEmail,
+			"updated_at":    time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+
+		token, err := issueUserToken(tx, user.ID, models.TokenPurposeEmailChange,
+			config.GetEnvDuration("EMAIL_VERIFICATION_TTL", 24*time.Hour))
+		if err != nil {
+			return err
+		}
+
+		return notification.Enqueue(tx, notification.TemplateEmailChange, user.Locale, input.NewEmail, map[string]interface{}{
+			"Username": user.Username,
+			"Token":    token,
+		})
+	})
+	if err == errEmailInUse {
+		c.JSON(http.StatusConflict, gin.H{"error": "Email already exists"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to request email change"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "A confirmation link has been sent to the new email address"})
+}
+
+// ConfirmEmailChange thay email đăng nhập bằng PendingEmail khi token xác minh hợp lệ
+func ConfirmEmailChange(c *gin.Context) {
+	type Request struct {
+		Token string `json:"token" validate:"required"`
+	}
+
+	var req Request
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.HandleValidationError(c, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(req); err != nil {
+		utils.HandleValidationError(c, err)
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		record, err := consumeUserToken(tx, req.Token, models.TokenPurposeEmailChange)
+		if err != nil {
+			return err
+		}
+
+		var user models.User
+		if err := tx.First(&user, "id = ?", record.UserID).Error; err != nil {
+			return err
+		}
+		if user.PendingEmail == "" {
+			return errInvalidUserToken
+		}
+
+		// Email có thể đã được tài khoản khác dùng trong lúc chờ xác minh
+		var count int64
+		if err := tx.Model(&models.User{}).Where("email = ? AND id <> ?", user.PendingEmail, user.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return errEmailInUse
+		}
+
+		now := time.Now()
+		return tx.Model(&user).Updates(map[string]interface{}{
+			"email":             user.PendingEmail,
+			"pending_email":     "",
+			"email_verified_at": now,
+			"updated_at":        now,
+		}).Error
+	})
+	if err == errInvalidUserToken {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired token"})
+		return
+	}
+	if err == errEmailInUse {
+		c.JSON(http.StatusConflict, gin.H{"error": "Email already exists"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email changed successfully"})
+}
+
+// DeleteMe xoá tài khoản của người dùng hiện tại bằng cách ẩn danh hoá dữ liệu cá nhân.
+// Bản ghi User được giữ lại (DeletedAt) để các dữ liệu liên quan như đánh giá vẫn hợp lệ;
+// phiên đăng nhập, liên kết mạng xã hội, giỏ hàng, wishlist và đăng ký nhận tin bị xoá.
+func DeleteMe(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var input models.DeleteAccountInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.HandleValidationError(c, err)
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, "id = ?", userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if err := checkCurrentPassword(user, input.Password, input.ConfirmationToken); err != nil {
+		respondCurrentPassword(c, err)
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"username":          "deleted-user",
+			"email":             fmt.Sprintf("deleted-%s@deleted.invalid", user.ID),
+			"pending_email":     "",
+			"password_hash":     "",
+			"full_name":         "",
+			"address":           "",
+			"phone_number":      "",
+			"avatar_url":        "",
+			"totp_secret":       "",
+			"totp_enabled":      false,
+			"email_verified_at": nil,
+			"token_version":     gorm.Expr("token_version + 1"),
+			"deleted_at":        now,
+			"updated_at":        now,
+		}).Error; err != nil {
+			return err
+		}
+
+		for _, model := range []interface{}{
+			&models.RefreshToken{},
+			&models.UserToken{},
+			&models.RecoveryCode{},
+			&models.UserIdentity{},
+			&models.UserRole{},
+			&models.WishlistItem{},
+			&models.StockSubscription{},
+			&models.ProductInteraction{},
+		} {
+			if err := tx.Where("user_id = ?", user.ID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+
+		var cartIDs []uuid.UUID
+		if err := tx.Model(&models.Cart{}).Where("user_id = ?", user.ID).Pluck("id", &cartIDs).Error; err != nil {
+			return err
+		}
+		if len(cartIDs) > 0 {
+			if err := tx.Where("cart_id IN ?", cartIDs).Delete(&models.CartItem{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("id IN ?", cartIDs).Delete(&models.Cart{}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete account"})
+		return
+	}
+	deleteStoredFile(user.AvatarURL)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Account deleted successfully"})
+}
@@ -3,13 +3,18 @@ package controllers
 import (
 	"ecommerce-project/config"
 	"ecommerce-project/models"
+	"errors"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+var errNegativeStock = errors.New("stock cannot be negative")
+
 // CreateVariantForProduct tạo một variant (phiên bản) cho sản phẩm
 func CreateVariantForProduct(c *gin.Context) {
 	// Lấy product id từ URL (tham số :id)
@@ -83,14 +88,6 @@ func UpdateVariant(c *gin.Context) {
 		return
 	}
 
-	// Tìm variant trong database
-	var variant models.ProductVariant
-	if err := config.DB.First(&variant, "id = ?", id).Error; err != nil {
-		errResp := models.NewErrorResponse(http.StatusNotFound, "Variant not found", err.Error())
-		c.JSON(http.StatusNotFound, errResp)
-		return
-	}
-
 	// Nhận dữ liệu cập nhật từ request
 	var input models.UpdateVariantInput
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -99,40 +96,128 @@ func UpdateVariant(c *gin.Context) {
 		return
 	}
 
-	// Nếu cập nhật default = true, cập nhật tất cả variant khác của cùng sản phẩm về default=false
-	if input.Default != nil && *input.Default {
-		if err := config.DB.Model(&models.ProductVariant{}).
-			Where("product_id = ? AND id <> ?", variant.ProductID, variant.ID).
-			Update("default", false).Error; err != nil {
-			errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to update default variants", err.Error())
-			c.JSON(http.StatusInternalServerError, errResp)
-			return
+	var variant models.ProductVariant
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		// Khoá variant để tồn kho cũ (dùng phát hiện có hàng trở lại) không bị thay đổi đồng thời
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&variant, "id = ?", id).Error; err != nil {
+			return err
 		}
-	}
 
-	if input.Color != nil {
-		variant.Color = *input.Color
-	}
-	if input.Capacity != nil {
-		variant.Capacity = *input.Capacity
+		// Nếu cập nhật default = true, cập nhật tất cả variant khác của cùng sản phẩm về default=false
+		if input.Default != nil && *input.Default {
+			if err := tx.Model(&models.ProductVariant{}).
+				Where("product_id = ? AND id <> ?", variant.ProductID, variant.ID).
+				Update("default", false).Error; err != nil {
+				return err
+			}
+		}
+
+		if input.Color != nil {
+			variant.Color = *input.Color
+		}
+		if input.Capacity != nil {
+			variant.Capacity = *input.Capacity
+		}
+		if input.Price != nil {
+			variant.Price = *input.Price
+		}
+		previousStock := variant.Stock
+		if input.Stock != nil {
+			variant.Stock = *input.Stock
+		}
+		if input.Default != nil {
+			variant.Default = *input.Default
+		}
+		if input.Active != nil {
+			variant.Active = *input.Active
+		}
+		variant.UpdatedAt = time.Now()
+
+		if err := tx.Save(&variant).Error; err != nil {
+			return err
+		}
+		if err := recordStockMovement(tx, variant, variant.Stock-previousStock, models.StockMovementAdjustment, nil, currentActorID(c)); err != nil {
+			return err
+		}
+		return notifyBackInStock(tx, variant, previousStock)
+	})
+	if err == gorm.ErrRecordNotFound {
+		errResp := models.NewErrorResponse(http.StatusNotFound, "Variant not found", err.Error())
+		c.JSON(http.StatusNotFound, errResp)
+		return
 	}
-	if input.Price != nil {
-		variant.Price = *input.Price
+	if err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to update variant", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
 	}
-	if input.Stock != nil {
-		variant.Stock = *input.Stock
+
+	c.JSON(http.StatusOK, variant)
+}
+
+
+// AdjustVariantStock điều chỉnh tồn kho của variant (đặt giá trị mới bằng "stock"
+// hoặc cộng/trừ bằng "delta"), dành cho nhân viên kho chỉ có quyền inventory:adjust.
+func AdjustVariantStock(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid variant id", err.Error())
+		c.JSON(http.StatusBadRequest, errResp)
+		return
 	}
-	if input.Default != nil {
-		variant.Default = *input.Default
+
+	var input models.AdjustStockInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid request payload", err.Error())
+		c.JSON(http.StatusBadRequest, errResp)
+		return
 	}
-	if input.Active != nil {
-		variant.Active = *input.Active
+	if (input.Stock == nil) == (input.Delta == nil) {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Exactly one of stock or delta is required")
+		c.JSON(http.StatusBadRequest, errResp)
+		return
 	}
 
-	variant.UpdatedAt = time.Now()
+	var variant models.ProductVariant
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&variant, "id = ?", id).Error; err != nil {
+			return err
+		}
+
+		previousStock := variant.Stock
+		if input.Stock != nil {
+			variant.Stock = *input.Stock
+		} else {
+			variant.Stock += *input.Delta
+		}
+		if variant.Stock < 0 {
+			return errNegativeStock
+		}
+		variant.UpdatedAt = time.Now()
 
-	if err := config.DB.Save(&variant).Error; err != nil {
-		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to update variant", err.Error())
+		if err := tx.Model(&variant).Updates(map[string]interface{}{
+			"stock":      variant.Stock,
+			"updated_at": variant.UpdatedAt,
+		}).Error; err != nil {
+			return err
+		}
+		if err := recordStockMovement(tx, variant, variant.Stock-previousStock, models.StockMovementAdjustment, nil, currentActorID(c)); err != nil {
+			return err
+		}
+		return notifyBackInStock(tx, variant, previousStock)
+	})
+	if err == gorm.ErrRecordNotFound {
+		errResp := models.NewErrorResponse(http.StatusNotFound, "Variant not found", err.Error())
+		c.JSON(http.StatusNotFound, errResp)
+		return
+	}
+	if err == errNegativeStock {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Stock cannot be negative")
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+	if err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to adjust stock", err.Error())
 		c.JSON(http.StatusInternalServerError, errResp)
 		return
 	}
@@ -153,7 +238,7 @@ func GetVariantsForProduct(c *gin.Context) {
 	}
 
 	var variants []models.ProductVariant
-	if err := config.DB.Where("product_id = ?", productID).Find(&variants).Error; err != nil {
+	if err := config.DB.Scopes(models.VisibleVariants).Where("product_id = ?", productID).Find(&variants).Error; err != nil {
 		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to retrieve variants", err.Error())
 		c.JSON(http.StatusInternalServerError, errResp)
 		return
@@ -173,7 +258,7 @@ func GetVariantByID(c *gin.Context) {
 	}
 
 	var variant models.ProductVariant
-	if err := config.DB.First(&variant, "id = ?", id).Error; err != nil {
+	if err := config.DB.Scopes(models.VisibleVariants).First(&variant, "id = ?", id).Error; err != nil {
 		errResp := models.NewErrorResponse(http.StatusNotFound, "Variant not found", err.Error())
 		c.JSON(http.StatusNotFound, errResp)
 		return
@@ -182,7 +267,7 @@ func GetVariantByID(c *gin.Context) {
 	c.JSON(http.StatusOK, variant)
 }
 
-// DeleteVariant xoá một variant theo id
+// DeleteVariant chuyển một variant vào thùng rác (soft delete), có thể khôi phục bằng RestoreVariant
 func DeleteVariant(c *gin.Context) {
 	idParam := c.Param("id")
 	id, err := uuid.Parse(idParam)
@@ -192,11 +277,17 @@ func DeleteVariant(c *gin.Context) {
 		return
 	}
 
-	if err := config.DB.Delete(&models.ProductVariant{}, "id = ?", id).Error; err != nil {
-		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to delete variant", err.Error())
+	result := config.DB.Delete(&models.ProductVariant{}, "id = ?", id)
+	if result.Error != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to delete variant", result.Error.Error())
 		c.JSON(http.StatusInternalServerError, errResp)
 		return
 	}
+	if result.RowsAffected == 0 {
+		errResp := models.NewErrorResponse(http.StatusNotFound, "Variant not found")
+		c.JSON(http.StatusNotFound, errResp)
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Variant deleted successfully"})
 }
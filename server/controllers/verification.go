@@ -0,0 +1,236 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"ecommerce-project/config"
+	"ecommerce-project/models"
+	"ecommerce-project/notification"
+	"ecommerce-project/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errInvalidUserToken = errors.New("invalid or expired token")
+
+// issueUserToken tạo token dùng một lần cho người dùng, các token cùng mục đích chưa dùng trước đó bị vô hiệu hoá.
+// Trả về token gốc để gửi qua email.
+func issueUserToken(tx *gorm.DB, userID uuid.UUID, purpose string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	if err := tx.Model(&models.UserToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+		Update("used_at", now).Error; err != nil {
+		return "", err
+	}
+
+	token, tokenHash, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	record := models.UserToken{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: tokenHash,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}
+	if err := tx.Create(&record).Error; err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// consumeUserToken kiểm tra token còn hạn, chưa dùng, đúng mục đích và đánh dấu đã dùng.
+// Bản ghi được khoá để một token không thể được dùng hai lần đồng thời.
+func consumeUserToken(tx *gorm.DB, token, purpose string) (models.UserToken, error) {
+	record, err := lockUserToken(tx, token, purpose)
+	if err != nil {
+		return record, err
+	}
+
+	now := time.Now()
+	record.UsedAt = &now
+	if err := tx.Save(&record).Error; err != nil {
+		return record, err
+	}
+
+	return record, nil
+}
+
+// lockUserToken tìm và khoá (FOR UPDATE) token còn hiệu lực theo mục đích, không đánh dấu đã dùng
+func lockUserToken(tx *gorm.DB, token, purpose string) (models.UserToken, error) {
+	var record models.UserToken
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("token_hash = ? AND purpose = ?", utils.HashToken(token), purpose).
+		First(&record).Error
+	if err == gorm.ErrRecordNotFound {
+		return record, errInvalidUserToken
+	}
+	if err != nil {
+		return record, err
+	}
+
+	if record.UsedAt != nil || time.Now().After(record.ExpiresAt) {
+		return record, errInvalidUserToken
+	}
+
+	return record, nil
+}
+
+// sendEmailVerification tạo token xác minh email và ghi email xác minh vào outbox
+func sendEmailVerification(tx *gorm.DB, user models.User) error {
+	token, err := issueUserToken(tx, user.ID, models.TokenPurposeEmailVerification,
+		config.GetEnvDuration("EMAIL_VERIFICATION_TTL", 24*time.Hour))
+	if err != nil {
+		return err
+	}
+
+	return notification.Enqueue(tx, notification.TemplateVerifyEmail, user.Locale, user.Email, map[string]interface{}{
+		"Username": user.Username,
+		"Token":    token,
+	})
+}
+
+// VerifyEmail xác minh email của người dùng bằng token nhận được qua email
+func VerifyEmail(c *gin.Context) {
+	type Request struct {
+		Token string `json:"token" validate:"required"`
+	}
+
+	var req Request
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.HandleValidationError(c, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(req); err != nil {
+		utils.HandleValidationError(c, err)
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		record, err := consumeUserToken(tx, req.Token, models.TokenPurposeEmailVerification)
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(&models.User{}).
+			Where("id = ? AND email_verified_at IS NULL", record.UserID).
+			Update("email_verified_at", time.Now()).Error
+	})
+	if err == errInvalidUserToken {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired token"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully"})
+}
+
+// ForgotPassword gửi email chứa link đặt lại mật khẩu.
+// Luôn trả về cùng một phản hồi để không lộ email nào đã đăng ký.
+func ForgotPassword(c *gin.Context) {
+	type Request struct {
+		Email string `json:"email" validate:"required,email"`
+	}
+
+	var req Request
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.HandleValidationError(c, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(req); err != nil {
+		utils.HandleValidationError(c, err)
+		return
+	}
+
+	var user models.User
+	if err := config.DB.Where("email = ?", req.Email).First(&user).Error; err == nil {
+		err := config.DB.Transaction(func(tx *gorm.DB) error {
+			token, err := issueUserToken(tx, user.ID, models.TokenPurposePasswordReset,
+				config.GetEnvDuration("PASSWORD_RESET_TTL", time.Hour))
+			if err != nil {
+				return err
+			}
+
+			return notification.Enqueue(tx, notification.TemplatePasswordReset, user.Locale, user.Email, map[string]interface{}{
+				"Username": user.Username,
+				"Token":    token,
+			})
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create password reset request"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "If the email is registered, a password reset link has been sent"})
+}
+
+// ResetPassword đặt lại mật khẩu bằng token nhận được qua email
+// và thu hồi toàn bộ refresh token của người dùng.
+func ResetPassword(c *gin.Context) {
+	type Request struct {
+		Token    string `json:"token" validate:"required"`
+		Password string `json:"password" validate:"required,min=6"`
+	}
+
+	var req Request
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.HandleValidationError(c, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(req); err != nil {
+		utils.HandleValidationError(c, err)
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Password hashing failed"})
+		return
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		record, err := consumeUserToken(tx, req.Token, models.TokenPurposePasswordReset)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.User{}).
+			Where("id = ?", record.UserID).
+			Updates(map[string]interface{}{
+				"password_hash": string(hashedPassword),
+				"updated_at":    time.Now(),
+			}).Error; err != nil {
+			return err
+		}
+
+		// Đăng xuất mọi thiết bị bằng cách thu hồi toàn bộ refresh token
+		return tx.Where("user_id = ?", record.UserID).Delete(&models.RefreshToken{}).Error
+	})
+	if err == errInvalidUserToken {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired token"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset successfully"})
+}
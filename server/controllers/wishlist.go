@@ -0,0 +1,368 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"ecommerce-project/config"
+	"ecommerce-project/events"
+	"ecommerce-project/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// notifyBackInStock phát sự kiện VariantBackInStock cho các đăng ký chưa được thông báo
+// khi tồn kho của variant chuyển từ 0 lên dương. Hàm cần được gọi trong cùng transaction
+// với thao tác thay đổi tồn kho, với previousStock đọc từ dòng variant đã khoá (FOR UPDATE) để
+// hai lần nhập hàng đồng thời không cùng thông báo. Handler của sự kiện gán NotifiedAt.
+// Variant hoặc sản phẩm đang trong thùng rác (ví dụ khi nhận hàng trả về) không được thông báo.
+func notifyBackInStock(tx *gorm.DB, variant models.ProductVariant, previousStock int) error {
+	if previousStock > 0 || variant.Stock <= 0 || variant.DeletedAt.Valid {
+		return nil
+	}
+	var productCount int64
+	if err := tx.Model(&models.Product{}).Where("id = ?", variant.ProductID).Count(&productCount).Error; err != nil {
+		return err
+	}
+	if productCount == 0 {
+		return nil
+	}
+
+	var subscriptions []models.StockSubscription
+	if err := tx.Where("variant_id = ? AND notified_at IS NULL", variant.ID).
+		Find(&subscriptions).Error; err != nil {
+		return err
+	}
+
+	for _, subscription := range subscriptions {
+		err := events.Publish(tx, events.Event{
+			Name: events.VariantBackInStock,
+			Payload: events.BackInStockPayload{
+				SubscriptionID: subscription.ID.String(),
+				UserID:         subscription.UserID.String(),
+				ProductID:      variant.ProductID.String(),
+				VariantID:      variant.ID.String(),
+				Stock:          variant.Stock,
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetWishlist lấy danh sách variant trong wishlist của người dùng (preload Variant).
+func GetWishlist(c *gin.Context) {
+	userIDInterface, exists := c.Get("userID")
+	if !exists {
+		errResp := models.NewErrorResponse(http.StatusUnauthorized, "Unauthorized", "Unauthorized")
+		c.JSON(http.StatusUnauthorized, errResp)
+		return
+	}
+	userID, ok := userIDInterface.(uuid.UUID)
+	if !ok {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Invalid user id", "Invalid user id")
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	// Bỏ qua các variant đang nằm trong thùng rác
+	var items []models.WishlistItem
+	if err := config.DB.Preload("Variant").
+		Where("user_id = ?", userID).
+		Where("variant_id IN (?)", config.DB.Model(&models.ProductVariant{}).Select("id")).
+		Order("created_at DESC").
+		Find(&items).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to fetch wishlist", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	c.JSON(http.StatusOK, items)
+}
+
+// AddWishlistItem thêm một variant vào wishlist của người dùng.
+func AddWishlistItem(c *gin.Context) {
+	userIDInterface, exists := c.Get("userID")
+	if !exists {
+		errResp := models.NewErrorResponse(http.StatusUnauthorized, "Unauthorized", "Unauthorized")
+		c.JSON(http.StatusUnauthorized, errResp)
+		return
+	}
+	userID, ok := userIDInterface.(uuid.UUID)
+	if !ok {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Invalid user id", "Invalid user id")
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	var input models.AddWishlistItemInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid request payload", err.Error())
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+	variantID := uuid.MustParse(input.VariantID)
+
+	var variant models.ProductVariant
+	if err := config.DB.Scopes(models.VisibleVariants).First(&variant, "id = ?", variantID).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusNotFound, "Variant not found", err.Error())
+		c.JSON(http.StatusNotFound, errResp)
+		return
+	}
+
+	// Nếu variant đã có trong wishlist thì trả về bản ghi hiện có
+	var item models.WishlistItem
+	err := config.DB.Where("user_id = ? AND variant_id = ?", userID, variantID).First(&item).Error
+	if err == nil {
+		c.JSON(http.StatusOK, item)
+		return
+	}
+
+	item = models.WishlistItem{
+		ID:        uuid.New(),
+		UserID:    userID,
+		VariantID: variantID,
+		Note:      input.Note,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := config.DB.Create(&item).Error; err != nil {
+		// Một request đồng thời vừa thêm cùng variant: trả về bản ghi của request đó
+		if errors.Is(err, gorm.ErrDuplicatedKey) &&
+			config.DB.Where("user_id = ? AND variant_id = ?", userID, variantID).First(&item).Error == nil {
+			c.JSON(http.StatusOK, item)
+			return
+		}
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to add item to wishlist", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	item.Variant = variant
+	c.JSON(http.StatusCreated, item)
+}
+
+// UpdateWishlistItem cập nhật ghi chú của một mục trong wishlist.
+func UpdateWishlistItem(c *gin.Context) {
+	userIDInterface, exists := c.Get("userID")
+	if !exists {
+		errResp := models.NewErrorResponse(http.StatusUnauthorized, "Unauthorized", "Unauthorized")
+		c.JSON(http.StatusUnauthorized, errResp)
+		return
+	}
+	userID, ok := userIDInterface.(uuid.UUID)
+	if !ok {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Invalid user id", "Invalid user id")
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	itemID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid wishlist item id", err.Error())
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+
+	var input models.UpdateWishlistItemInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid request payload", err.Error())
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+
+	var item models.WishlistItem
+	if err := config.DB.Where("id = ? AND user_id = ?", itemID, userID).First(&item).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusNotFound, "Wishlist item not found", err.Error())
+		c.JSON(http.StatusNotFound, errResp)
+		return
+	}
+
+	if input.Note != nil {
+		item.Note = *input.Note
+	}
+	item.UpdatedAt = time.Now()
+
+	if err := config.DB.Save(&item).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to update wishlist item", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	c.JSON(http.StatusOK, item)
+}
+
+// DeleteWishlistItem xoá một mục khỏi wishlist của người dùng.
+func DeleteWishlistItem(c *gin.Context) {
+	userIDInterface, exists := c.Get("userID")
+	if !exists {
+		errResp := models.NewErrorResponse(http.StatusUnauthorized, "Unauthorized", "Unauthorized")
+		c.JSON(http.StatusUnauthorized, errResp)
+		return
+	}
+	userID, ok := userIDInterface.(uuid.UUID)
+	if !ok {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Invalid user id", "Invalid user id")
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	itemID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid wishlist item id", err.Error())
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+
+	result := config.DB.Where("id = ? AND user_id = ?", itemID, userID).Delete(&models.WishlistItem{})
+	if result.Error != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to delete wishlist item", result.Error.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+	if result.RowsAffected == 0 {
+		errResp := models.NewErrorResponse(http.StatusNotFound, "Wishlist item not found")
+		c.JSON(http.StatusNotFound, errResp)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Wishlist item deleted successfully"})
+}
+
+// GetStockSubscriptions lấy các đăng ký nhận thông báo có hàng của người dùng.
+func GetStockSubscriptions(c *gin.Context) {
+	userIDInterface, exists := c.Get("userID")
+	if !exists {
+		errResp := models.NewErrorResponse(http.StatusUnauthorized, "Unauthorized", "Unauthorized")
+		c.JSON(http.StatusUnauthorized, errResp)
+		return
+	}
+	userID, ok := userIDInterface.(uuid.UUID)
+	if !ok {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Invalid user id", "Invalid user id")
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	var subscriptions []models.StockSubscription
+	if err := config.DB.Preload("Variant").
+		Where("user_id = ?", userID).
+		Where("variant_id IN (?)", config.DB.Model(&models.ProductVariant{}).Select("id")).
+		Order("created_at DESC").
+		Find(&subscriptions).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to fetch stock subscriptions", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	c.JSON(http.StatusOK, subscriptions)
+}
+
+// CreateStockSubscription đăng ký nhận thông báo khi một variant đang hết hàng có hàng trở lại.
+func CreateStockSubscription(c *gin.Context) {
+	userIDInterface, exists := c.Get("userID")
+	if !exists {
+		errResp := models.NewErrorResponse(http.StatusUnauthorized, "Unauthorized", "Unauthorized")
+		c.JSON(http.StatusUnauthorized, errResp)
+		return
+	}
+	userID, ok := userIDInterface.(uuid.UUID)
+	if !ok {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Invalid user id", "Invalid user id")
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	var input models.CreateStockSubscriptionInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid request payload", err.Error())
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+	variantID := uuid.MustParse(input.VariantID)
+
+	var variant models.ProductVariant
+	if err := config.DB.Scopes(models.VisibleVariants).First(&variant, "id = ?", variantID).Error; err != nil {
+		errResp := models.NewErrorResponse(http.StatusNotFound, "Variant not found", err.Error())
+		c.JSON(http.StatusNotFound, errResp)
+		return
+	}
+
+	if variant.Stock > 0 {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Variant is in stock", "Subscriptions are only available for out-of-stock variants")
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+
+	// Nếu đã có đăng ký đang chờ thông báo thì trả về bản ghi hiện có
+	var subscription models.StockSubscription
+	err := config.DB.Where("user_id = ? AND variant_id = ? AND notified_at IS NULL", userID, variantID).
+		First(&subscription).Error
+	if err == nil {
+		c.JSON(http.StatusOK, subscription)
+		return
+	}
+
+	subscription = models.StockSubscription{
+		ID:        uuid.New(),
+		UserID:    userID,
+		VariantID: variantID,
+		CreatedAt: time.Now(),
+	}
+	if err := config.DB.Create(&subscription).Error; err != nil {
+		// Một request đồng thời vừa tạo đăng ký đang chờ: trả về bản ghi của request đó
+		if errors.Is(err, gorm.ErrDuplicatedKey) &&
+			config.DB.Where("user_id = ? AND variant_id = ? AND notified_at IS NULL", userID, variantID).First(&subscription).Error == nil {
+			c.JSON(http.StatusOK, subscription)
+			return
+		}
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to create stock subscription", err.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	c.JSON(http.StatusCreated, subscription)
+}
+
+// DeleteStockSubscription huỷ một đăng ký nhận thông báo có hàng.
+func DeleteStockSubscription(c *gin.Context) {
+	userIDInterface, exists := c.Get("userID")
+	if !exists {
+		errResp := models.NewErrorResponse(http.StatusUnauthorized, "Unauthorized", "Unauthorized")
+		c.JSON(http.StatusUnauthorized, errResp)
+		return
+	}
+	userID, ok := userIDInterface.(uuid.UUID)
+	if !ok {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Invalid user id", "Invalid user id")
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+
+	subscriptionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		errResp := models.NewErrorResponse(http.StatusBadRequest, "Invalid subscription id", err.Error())
+		c.JSON(http.StatusBadRequest, errResp)
+		return
+	}
+
+	result := config.DB.Where("id = ? AND user_id = ?", subscriptionID, userID).Delete(&models.StockSubscription{})
+	if result.Error != nil {
+		errResp := models.NewErrorResponse(http.StatusInternalServerError, "Failed to delete stock subscription", result.Error.Error())
+		c.JSON(http.StatusInternalServerError, errResp)
+		return
+	}
+	if result.RowsAffected == 0 {
+		errResp := models.NewErrorResponse(http.StatusNotFound, "Stock subscription not found")
+		c.JSON(http.StatusNotFound, errResp)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Stock subscription deleted successfully"})
+}
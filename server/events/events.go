@@ -0,0 +1,60 @@
+package events
+
+import (
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// Tên các sự kiện nghiệp vụ được phát trong hệ thống
+const (
+	// VariantBackInStock được phát khi tồn kho của một variant chuyển từ 0 lên dương,
+	// mỗi người đăng ký nhận thông báo sẽ có một sự kiện riêng.
+	VariantBackInStock = "variant.back_in_stock"
+)
+
+// Event là một sự kiện nghiệp vụ kèm dữ liệu đi theo
+type Event struct {
+	Name    string
+	Payload interface{}
+}
+
+// BackInStockPayload là dữ liệu của sự kiện VariantBackInStock
+// Handler xử lý sự kiện cần gán NotifiedAt cho SubscriptionID sau khi đã ghi thông báo.
+type BackInStockPayload struct {
+	SubscriptionID string `json:"subscription_id"`
+	UserID         string `json:"user_id"`
+	ProductID      string `json:"product_id"`
+	VariantID      string `json:"variant_id"`
+	Stock          int    `json:"stock"`
+}
+
+// Handler xử lý một sự kiện. tx là transaction của nghiệp vụ phát ra sự kiện,
+// handler trả về lỗi sẽ khiến transaction đó bị rollback.
+type Handler func(tx *gorm.DB, event Event) error
+
+var (
+	mu       sync.RWMutex
+	handlers = map[string][]Handler{}
+)
+
+// Subscribe đăng ký handler cho một loại sự kiện
+func Subscribe(name string, handler Handler) {
+	mu.Lock()
+	defer mu.Unlock()
+	handlers[name] = append(handlers[name], handler)
+}
+
+// Publish gọi lần lượt các handler đã đăng ký cho sự kiện trong cùng transaction tx
+func Publish(tx *gorm.DB, event Event) error {
+	mu.RLock()
+	subscribers := handlers[event.Name]
+	mu.RUnlock()
+
+	for _, handler := range subscribers {
+		if err := handler(tx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
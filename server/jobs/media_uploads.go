@@ -0,0 +1,74 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"ecommerce-project/config"
+	"ecommerce-project/models"
+
+	"gorm.io/gorm"
+)
+
+// StartMediaUploadCleanup định kỳ (MEDIA_UPLOAD_CLEANUP_INTERVAL) dọn các lượt upload trực tiếp
+// không được hoàn tất trước hạn, chạy trong goroutine riêng cho tới khi ctx bị huỷ.
+func StartMediaUploadCleanup(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(config.GetEnvDuration("MEDIA_UPLOAD_CLEANUP_INTERVAL", time.Hour))
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				stor, err := config.GetStorage()
+				if err != nil {
+					log.Println("Media upload cleanup failed:", err)
+					continue
+				}
+				if err := CleanupMediaUploads(config.DB, stor, time.Now()); err != nil {
+					log.Println("Media upload cleanup failed:", err)
+				}
+			}
+		}
+	}()
+}
+
+// Lượt upload đã bắt đầu hoàn tất (completed_at) quá khoảng này mà vẫn chưa có media được coi là bị gián đoạn
+const staleMediaUploadCompletion = time.Hour
+
+// CleanupMediaUploads xoá file tạm và bản ghi của các lượt upload đã quá hạn mà chưa tạo được media
+// (client không gọi hoàn tất, hoặc việc hoàn tất bị gián đoạn). Lượt upload đang được hoàn tất không bị
+// dọn cho tới khi completed_at cũ hơn staleMediaUploadCompletion.
+func CleanupMediaUploads(db *gorm.DB, stor config.Storage, now time.Time) error {
+	staleBefore := now.Add(-staleMediaUploadCompletion)
+	var uploads []models.MediaUpload
+	if err := db.Where("media_id IS NULL AND expires_at < ? AND (completed_at IS NULL OR completed_at < ?)", now, staleBefore).
+		Find(&uploads).Error; err != nil {
+		return err
+	}
+
+	removed := 0
+	for _, upload := range uploads {
+		// Điều kiện được kiểm tra lại khi xoá: lượt upload vừa được hoàn tất sau khi đọc danh sách thì giữ nguyên
+		result := db.Where("media_id IS NULL AND (completed_at IS NULL OR completed_at < ?)", staleBefore).Delete(&upload)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+		removed++
+		// File có thể chưa từng được upload; lỗi xoá chỉ được ghi log
+		if err := stor.DeleteFile(upload.StorageKey); err != nil {
+			log.Println("Failed to delete file:", err)
+		}
+	}
+
+	if removed > 0 {
+		log.Printf("Media upload cleanup: removed %d expired uploads", removed)
+	}
+	return nil
+}
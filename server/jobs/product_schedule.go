@@ -0,0 +1,56 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"ecommerce-project/config"
+	"ecommerce-project/models"
+
+	"gorm.io/gorm"
+)
+
+// StartProductScheduler định kỳ (PRODUCT_SCHEDULE_INTERVAL) cập nhật trạng thái của các sản phẩm
+// đã tới publish_at hoặc unpublish_at, chạy trong goroutine riêng cho tới khi ctx bị huỷ.
+func StartProductScheduler(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(config.GetEnvDuration("PRODUCT_SCHEDULE_INTERVAL", time.Minute))
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := ApplyProductSchedule(config.DB, time.Now()); err != nil {
+					log.Println("Product schedule failed:", err)
+				}
+			}
+		}
+	}()
+}
+
+// ApplyProductSchedule chuyển sản phẩm scheduled sang published khi tới publish_at
+// và sản phẩm published sang archived khi tới unpublish_at.
+// Truy vấn công khai đã tự so các mốc thời gian, job này chỉ đồng bộ cột status.
+func ApplyProductSchedule(db *gorm.DB, now time.Time) error {
+	published := db.Model(&models.Product{}).
+		Where("status = ? AND publish_at <= ?", models.ProductStatusScheduled, now).
+		Updates(map[string]interface{}{"status": models.ProductStatusPublished, "updated_at": now})
+	if published.Error != nil {
+		return published.Error
+	}
+
+	archived := db.Model(&models.Product{}).
+		Where("status IN ? AND unpublish_at <= ?", []string{models.ProductStatusScheduled, models.ProductStatusPublished}, now).
+		Updates(map[string]interface{}{"status": models.ProductStatusArchived, "updated_at": now})
+	if archived.Error != nil {
+		return archived.Error
+	}
+
+	if published.RowsAffected > 0 || archived.RowsAffected > 0 {
+		log.Printf("Product schedule: published %d, archived %d", published.RowsAffected, archived.RowsAffected)
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"ecommerce-project/config"
+	"ecommerce-project/recommend"
+)
+
+// StartViewRecorder ghi các lượt xem sản phẩm được đưa vào hàng đợi bởi recommend.RecordView
+// trong goroutine riêng cho tới khi ctx bị huỷ.
+func StartViewRecorder(ctx context.Context) {
+	go recommend.ProcessViews(ctx, config.DB)
+}
+
+// StartRecommendationRefresh tính lại bảng gợi ý sản phẩm khi khởi động và định kỳ
+// (RECOMMENDATION_REFRESH_INTERVAL), chạy trong goroutine riêng cho tới khi ctx bị huỷ.
+func StartRecommendationRefresh(ctx context.Context) {
+	go func() {
+		refresh := func() {
+			if err := recommend.Refresh(config.DB, recommend.LoadSettings(), time.Now()); err != nil {
+				log.Println("Recommendation refresh failed:", err)
+			}
+		}
+		refresh()
+
+		ticker := time.NewTicker(config.GetEnvDuration("RECOMMENDATION_REFRESH_INTERVAL", time.Hour))
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				refresh()
+			}
+		}
+	}()
+}
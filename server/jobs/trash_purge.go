@@ -0,0 +1,109 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"ecommerce-project/config"
+	"ecommerce-project/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// StartTrashPurge định kỳ (TRASH_PURGE_INTERVAL) xoá vĩnh viễn các mục đã nằm trong thùng rác
+// lâu hơn TRASH_RETENTION, chạy trong goroutine riêng cho tới khi ctx bị huỷ.
+func StartTrashPurge(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(config.GetEnvDuration("TRASH_PURGE_INTERVAL", time.Hour))
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := PurgeTrash(config.DB, time.Now().Add(-config.GetEnvDuration("TRASH_RETENTION", 30*24*time.Hour))); err != nil {
+					log.Println("Trash purge failed:", err)
+				}
+			}
+		}
+	}()
+}
+
+// PurgeTrash xoá vĩnh viễn variant, sản phẩm và danh mục bị xoá mềm trước cutoff.
+// Mục còn được tham chiếu thì được giữ lại cho tới lần chạy sau: variant còn trong giỏ hàng, đơn hàng,
+// yêu cầu đổi trả hoặc sổ kho; sản phẩm còn variant hoặc dòng đơn hàng; danh mục còn sản phẩm
+// (kể cả trong thùng rác). Lịch sử đơn hàng, đổi trả và sổ kho vì vậy luôn trỏ tới bản ghi còn tồn tại.
+func PurgeTrash(db *gorm.DB, cutoff time.Time) error {
+	var variantIDs []uuid.UUID
+	if err := db.Unscoped().Model(&models.ProductVariant{}).
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).
+		Where("id NOT IN (?)", db.Model(&models.CartItem{}).Select("variant_id")).
+		Where("id NOT IN (?)", db.Model(&models.OrderLine{}).Select("variant_id")).
+		Where("id NOT IN (?)", db.Model(&models.ReturnLine{}).Select("variant_id")).
+		Where("id NOT IN (?)", db.Model(&models.StockMovement{}).Select("variant_id")).
+		Pluck("id", &variantIDs).Error; err != nil {
+		return err
+	}
+	if len(variantIDs) > 0 {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			// Danh sách yêu thích và đăng ký báo có hàng của variant không còn ý nghĩa
+			if err := tx.Where("variant_id IN ?", variantIDs).Delete(&models.WishlistItem{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("variant_id IN ?", variantIDs).Delete(&models.StockSubscription{}).Error; err != nil {
+				return err
+			}
+			return tx.Unscoped().Where("id IN ?", variantIDs).Delete(&models.ProductVariant{}).Error
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	var productIDs []uuid.UUID
+	if err := db.Unscoped().Model(&models.Product{}).
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).
+		Where("id NOT IN (?)", db.Unscoped().Model(&models.ProductVariant{}).Select("product_id")).
+		Where("id NOT IN (?)", db.Model(&models.OrderLine{}).Select("product_id")).
+		Pluck("id", &productIDs).Error; err != nil {
+		return err
+	}
+	if len(productIDs) > 0 {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("product_id IN ?", productIDs).Delete(&models.Review{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("accessory_id IN ? OR device_id IN ?", productIDs, productIDs).Delete(&models.ProductCompatibility{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("product_id IN ? OR related_product_id IN ?", productIDs, productIDs).Delete(&models.ProductRecommendation{}).Error; err != nil {
+				return err
+			}
+			for _, model := range []interface{}{&models.ProductRevision{}, &models.ProductInteraction{}, &models.ProductImage{}} {
+				if err := tx.Where("product_id IN ?", productIDs).Delete(model).Error; err != nil {
+					return err
+				}
+			}
+			return tx.Unscoped().Where("id IN ?", productIDs).Delete(&models.Product{}).Error
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	result := db.Unscoped().
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).
+		Where("id NOT IN (?)", db.Unscoped().Model(&models.Product{}).Select("category_id")).
+		Delete(&models.Category{})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if len(variantIDs) > 0 || len(productIDs) > 0 || result.RowsAffected > 0 {
+		log.Printf("Trash purge: removed %d variants, %d products, %d categories", len(variantIDs), len(productIDs), result.RowsAffected)
+	}
+	return nil
+}
@@ -1,13 +1,23 @@
 package main
 
 import (
+	"context"
 	"ecommerce-project/config"
 	"ecommerce-project/docs"
+	"ecommerce-project/jobs"
 	"ecommerce-project/middleware"
+	"ecommerce-project/notification"
+	"ecommerce-project/rbac"
 	"ecommerce-project/routes"
+	"ecommerce-project/utils"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -18,7 +28,39 @@ func main() {
     config.InitDatabase()
     config.InitStorageClient()
 
+    // ctx bị huỷ khi nhận SIGINT/SIGTERM, các job nền và dispatcher dừng theo ctx
+    ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+    defer stop()
+
+    // Đồng bộ danh mục vai trò nhân viên có sẵn
+    if err := rbac.SeedRoles(config.DB); err != nil {
+        log.Fatal("Failed to seed roles:", err)
+    }
+
+    // Khoá ký access token: nạp từ database và định kỳ xoay vòng
+    utils.InitSigningKeys()
+    utils.StartKeyRotation(ctx)
+
+    // Xoá vĩnh viễn các mục quá hạn trong thùng rác
+    jobs.StartTrashPurge(ctx)
+    // Xuất bản và ngừng hiển thị sản phẩm theo lịch
+    jobs.StartProductScheduler(ctx)
+    // Ghi lượt xem sản phẩm ở nền và làm mới bảng gợi ý sản phẩm
+    jobs.StartViewRecorder(ctx)
+    jobs.StartRecommendationRefresh(ctx)
+    // Dọn các lượt upload trực tiếp lên storage không được hoàn tất
+    jobs.StartMediaUploadCleanup(ctx)
+
+    // Email giao dịch: đăng ký handler sự kiện và chạy dispatcher gửi outbox
+    notification.RegisterEventHandlers()
+    dispatcherDone := notification.NewDispatcher(config.DB, notification.NewSMTPSender()).Start(ctx)
+
     r := gin.Default()
+    // Chỉ đọc IP client từ X-Forwarded-For khi request đi qua proxy trong TRUSTED_PROXIES,
+    // để client không giả IP và né giới hạn đăng nhập theo IP
+    if err := r.SetTrustedProxies(config.TrustedProxies()); err != nil {
+        log.Fatal("Invalid TRUSTED_PROXIES:", err)
+    }
 
     r.Use(middleware.CORSMiddleware())
     
@@ -26,6 +68,28 @@ func main() {
     docs.InitSwagger(r)
     routes.SetupRoutes(r)
 
-    fmt.Println("Server is running on port 8080")
-    log.Fatal(http.ListenAndServe(":8080", r))
+    server := &http.Server{Addr: ":8080", Handler: r}
+    go func() {
+        fmt.Println("Server is running on port 8080")
+        if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+            log.Fatal(err)
+        }
+    }()
+
+    // Khi có tín hiệu dừng: ngừng nhận request mới, chờ các request đang xử lý
+    // và chờ dispatcher ghi kết quả của email đang gửi
+    <-ctx.Done()
+    stop()
+    log.Println("Shutting down...")
+
+    shutdownCtx, cancel := context.WithTimeout(context.Background(), config.GetEnvDuration("SHUTDOWN_TIMEOUT", 30*time.Second))
+    defer cancel()
+    if err := server.Shutdown(shutdownCtx); err != nil {
+        log.Println("Server shutdown failed:", err)
+    }
+    select {
+    case <-dispatcherDone:
+    case <-shutdownCtx.Done():
+        log.Println("Outbox dispatcher did not stop in time")
+    }
 }
\ No newline at end of file
@@ -0,0 +1,111 @@
+// Package media kiểm tra ảnh upload và tạo các bản thu nhỏ chỉ bằng thư viện chuẩn của Go.
+//
+// Phạm vi định dạng: ảnh gốc nhận JPEG, PNG và GIF; các bản thu nhỏ luôn là JPEG. WebP (cả nhận vào
+// lẫn xuất ra) nằm ngoài phạm vi: thư viện chuẩn không có codec WebP, golang.org/x/image/webp chỉ có
+// bộ giải mã và việc mã hoá cần libwebp qua cgo, trong khi project không thêm dependency ngoài cho media.
+package media
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"image"
+	_ "image/gif"  // đăng ký bộ giải mã GIF cho image.Decode
+	_ "image/jpeg" // đăng ký bộ giải mã JPEG cho image.Decode
+	_ "image/png"  // đăng ký bộ giải mã PNG cho image.Decode
+	"io"
+	"net/http"
+)
+
+var (
+	// ErrTooLarge được trả về khi file vượt quá dung lượng hoặc số điểm ảnh cho phép
+	ErrTooLarge = errors.New("file is too large")
+	// ErrUnsupportedType được trả về khi nội dung file không phải ảnh JPEG, PNG hoặc GIF
+	ErrUnsupportedType = errors.New("file is not a supported image (jpeg, png, gif)")
+)
+
+// allowedTypes là các định dạng ảnh được chấp nhận, xác định theo nội dung chứ không theo đuôi file
+// hay Content-Type do client gửi. WebP bị từ chối (xem phạm vi định dạng ở đầu package).
+var allowedTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+}
+
+// Supported cho biết định dạng ảnh có được chấp nhận hay không
+func Supported(contentType string) bool {
+	return allowedTypes[contentType]
+}
+
+// Info là thông tin của một ảnh đã được kiểm tra
+type Info struct {
+	ContentType string
+	Size        int64
+	Width       int
+	Height      int
+	// Checksum là SHA-256 (hex) của nội dung file
+	Checksum string
+}
+
+// Limits giới hạn dung lượng file và số điểm ảnh (chống ảnh "bomb" giải nén ra rất lớn)
+type Limits struct {
+	MaxSize   int64
+	MaxPixels int
+}
+
+// Inspect đọc nội dung từ r (tối đa limits.MaxSize byte) theo dạng stream, xác định định dạng
+// theo nội dung, đọc kích thước ảnh và tính checksum mà không giữ toàn bộ file trong bộ nhớ.
+func Inspect(r io.Reader, limits Limits) (Info, error) {
+	var info Info
+
+	hash := sha256.New()
+	counter := &countingReader{r: io.LimitReader(r, limits.MaxSize+1)}
+	buffered := bufio.NewReader(io.TeeReader(counter, hash))
+
+	head, err := buffered.Peek(512)
+	if err != nil && err != io.EOF {
+		return info, err
+	}
+	info.ContentType = http.DetectContentType(head)
+	if !allowedTypes[info.ContentType] {
+		return info, ErrUnsupportedType
+	}
+
+	config, _, err := image.DecodeConfig(buffered)
+	if err != nil {
+		return info, ErrUnsupportedType
+	}
+	if config.Width <= 0 || config.Height <= 0 {
+		return info, ErrUnsupportedType
+	}
+	if config.Width*config.Height > limits.MaxPixels {
+		return info, ErrTooLarge
+	}
+
+	// Đọc hết phần còn lại để tính checksum và dung lượng
+	if _, err := io.Copy(io.Discard, buffered); err != nil {
+		return info, err
+	}
+	if counter.n > limits.MaxSize {
+		return info, ErrTooLarge
+	}
+
+	info.Size = counter.n
+	info.Width = config.Width
+	info.Height = config.Height
+	info.Checksum = hex.EncodeToString(hash.Sum(nil))
+	return info, nil
+}
+
+// countingReader đếm số byte đã đọc
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,133 @@
+package media
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"io"
+)
+
+// Chất lượng nén JPEG của các bản thu nhỏ
+const jpegQuality = 85
+
+// RenditionContentType là định dạng của các bản thu nhỏ. Bản WebP không được tạo vì thư viện chuẩn
+// không có bộ mã hoá WebP (xem phạm vi định dạng ở đầu package).
+const RenditionContentType = "image/jpeg"
+
+// Size là một kích thước bản thu nhỏ: cạnh dài nhất không vượt quá MaxSide
+type Size struct {
+	Name    string
+	MaxSide int
+}
+
+// Sizes là các bản thu nhỏ được tạo cho mỗi ảnh
+var Sizes = []Size{
+	{Name: "thumbnail", MaxSide: 200},
+	{Name: "medium", MaxSide: 800},
+	{Name: "large", MaxSide: 1600},
+}
+
+// Rendition là một bản thu nhỏ đã được mã hoá
+type Rendition struct {
+	Name        string
+	ContentType string
+	Width       int
+	Height      int
+	Data        []byte
+}
+
+// Renditions giải mã ảnh gốc đọc từ r và tạo các bản thu nhỏ theo Sizes. Ảnh nhỏ hơn kích thước
+// yêu cầu không bị phóng to. Nền trong suốt được thay bằng nền trắng vì JPEG không có kênh alpha.
+func Renditions(r io.Reader) ([]Rendition, error) {
+	source, _, err := image.Decode(r)
+	if err != nil {
+		return nil, ErrUnsupportedType
+	}
+	flattened := flatten(source)
+
+	renditions := make([]Rendition, 0, len(Sizes))
+	for _, size := range Sizes {
+		resized := Resize(flattened, size.MaxSide)
+
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, resized, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return nil, err
+		}
+		bounds := resized.Bounds()
+		renditions = append(renditions, Rendition{
+			Name:        size.Name,
+			ContentType: RenditionContentType,
+			Width:       bounds.Dx(),
+			Height:      bounds.Dy(),
+			Data:        buf.Bytes(),
+		})
+	}
+	return renditions, nil
+}
+
+// flatten vẽ ảnh lên nền trắng thành ảnh RGBA bắt đầu tại (0, 0)
+func flatten(source image.Image) *image.RGBA {
+	bounds := source.Bounds()
+	result := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(result, result.Bounds(), &image.Uniform{C: color.White}, image.Point{}, draw.Src)
+	draw.Draw(result, result.Bounds(), source, bounds.Min, draw.Over)
+	return result
+}
+
+// Resize thu nhỏ ảnh để cạnh dài nhất không vượt quá maxSide, giữ nguyên tỉ lệ.
+// Mỗi điểm ảnh đích là trung bình có trọng số của vùng ảnh gốc mà nó phủ (area averaging),
+// cho chất lượng tốt khi thu nhỏ nhiều lần mà không cần thư viện ngoài.
+func Resize(source *image.RGBA, maxSide int) *image.RGBA {
+	srcWidth, srcHeight := source.Bounds().Dx(), source.Bounds().Dy()
+	if srcWidth <= maxSide && srcHeight <= maxSide {
+		return source
+	}
+
+	dstWidth, dstHeight := maxSide, maxSide
+	if srcWidth >= srcHeight {
+		dstHeight = max(1, srcHeight*maxSide/srcWidth)
+	} else {
+		dstWidth = max(1, srcWidth*maxSide/srcHeight)
+	}
+
+	scaleX := float64(srcWidth) / float64(dstWidth)
+	scaleY := float64(srcHeight) / float64(dstHeight)
+	result := image.NewRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+
+	for dy := 0; dy < dstHeight; dy++ {
+		y0 := float64(dy) * scaleY
+		y1 := y0 + scaleY
+		for dx := 0; dx < dstWidth; dx++ {
+			x0 := float64(dx) * scaleX
+			x1 := x0 + scaleX
+
+			var r, g, b, a, total float64
+			for sy := int(y0); sy < srcHeight && float64(sy) < y1; sy++ {
+				weightY := overlap(float64(sy), y0, y1)
+				for sx := int(x0); sx < srcWidth && float64(sx) < x1; sx++ {
+					weight := weightY * overlap(float64(sx), x0, x1)
+					offset := sy*source.Stride + sx*4
+					r += weight * float64(source.Pix[offset])
+					g += weight * float64(source.Pix[offset+1])
+					b += weight * float64(source.Pix[offset+2])
+					a += weight * float64(source.Pix[offset+3])
+					total += weight
+				}
+			}
+
+			offset := dy*result.Stride + dx*4
+			result.Pix[offset] = uint8(r/total + 0.5)
+			result.Pix[offset+1] = uint8(g/total + 0.5)
+			result.Pix[offset+2] = uint8(b/total + 0.5)
+			result.Pix[offset+3] = uint8(a/total + 0.5)
+		}
+	}
+	return result
+}
+
+// overlap là độ dài phần giao giữa điểm ảnh [pixel, pixel+1) và đoạn [start, end)
+func overlap(pixel, start, end float64) float64 {
+	return max(0, min(pixel+1, end)-max(pixel, start))
+}
@@ -1,38 +1,75 @@
 package middleware
 
 import (
+	"ecommerce-project/audit"
 	"ecommerce-project/config"
+	"ecommerce-project/models"
 	"ecommerce-project/utils"
-	"fmt"
 	"net/http"
+	"slices"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
+// sessionActive kiểm tra access token còn hiệu lực phía server: phiên đăng nhập (family của
+// refresh token) chưa bị thu hồi, token version khớp với User.TokenVersion và tài khoản chưa bị vô hiệu hoá.
+func sessionActive(claims *utils.TokenClaims) bool {
+    var count int64
+    err := config.DB.Model(&models.User{}).
+        Where("id = ? AND token_version = ? AND disabled_at IS NULL", claims.UserID, claims.TokenVersion).
+        Where("EXISTS (SELECT 1 FROM refresh_tokens WHERE refresh_tokens.family_id = ? AND refresh_tokens.revoked_at IS NULL)", claims.SessionID).
+        Count(&count).Error
+    return err == nil && count > 0
+}
+
 func AuthMiddleware(allowedRoles ...string) gin.HandlerFunc {
     return func(c *gin.Context) {
-        token := strings.Split(c.GetHeader("Authorization"), " ")[1]
+        token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
         if token == "" {
             c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization required"})
             c.Abort()
             return
         }
 
-        fmt.Println(token)
-
-        userID, role, err := utils.ParseToken(token, config.GetEnv("ACCESS_TOKEN_SECRET"))
+        claims, err := utils.ParseToken(token)
         if err != nil {
             c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
             c.Abort()
             return
         }
 
+        // Token đã bị thu hồi (đăng xuất, thu hồi phiên hoặc admin buộc đăng xuất)
+        if !sessionActive(claims) {
+            c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+            c.Abort()
+            return
+        }
+        touchSession(claims.SessionID)
+
+        // Route chỉ dành cho admin: khi bật chính sách REQUIRE_ADMIN_2FA, phiên đăng nhập phải qua 2FA
+        if claims.Role == "admin" && !claims.MFA && config.RequireAdminTwoFactor() && !slices.Contains(allowedRoles, "user") {
+            c.JSON(http.StatusForbidden, gin.H{"error": "Two-factor authentication required"})
+            c.Abort()
+            return
+        }
+
         // Kiểm tra quyền truy cập
         for _, allowedRole := range allowedRoles {
-            if role == allowedRole {
-                c.Set("userID", userID)
-                c.Set("role", role)
+            if claims.Role == allowedRole {
+                c.Set("userID", claims.UserID)
+                c.Set("role", claims.Role)
+                c.Set("sessionID", claims.SessionID)
+                c.Set("mfa", claims.MFA)
+                c.Set("permissions", claims.Permissions)
+                if claims.ImpersonatorID != uuid.Nil {
+                    c.Set("impersonatorID", claims.ImpersonatorID)
+                    // Người bị giả danh không có quyền quản trị nên request không đi qua RequirePermission:
+                    // mọi thao tác thay đổi dữ liệu trong phiên giả danh được audit ngay tại đây
+                    audit.Handle(c)
+                    return
+                }
                 c.Next()
                 return
             }
@@ -42,3 +79,19 @@ func AuthMiddleware(allowedRoles ...string) gin.HandlerFunc {
         c.Abort()
     }
 }
+
+// OptionalAuth gán userID khi request có access token hợp lệ, nhưng vẫn cho request không đăng nhập
+// (hoặc token không hợp lệ) đi tiếp như khách. Dùng cho route public cần biết người xem nếu có.
+func OptionalAuth() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+        if token != "" {
+            if claims, err := utils.ParseToken(token); err == nil && sessionActive(claims) {
+                touchSession(claims.SessionID)
+                c.Set("userID", claims.UserID)
+                c.Set("role", claims.Role)
+            }
+        }
+        c.Next()
+    }
+}
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"net/http"
+
+	"ecommerce-project/audit"
+	"ecommerce-project/config"
+	"ecommerce-project/rbac"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequirePermission chỉ cho phép request khi access token có đủ mọi quyền trong permissions.
+// Phải đặt sau AuthMiddleware. Khi bật REQUIRE_ADMIN_2FA, tài khoản admin phải dùng phiên
+// đăng nhập đã qua 2FA như với AuthMiddleware; nhân viên (role user có vai trò) không bị áp dụng.
+func RequirePermission(permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, _ := c.Get("permissions")
+		granted, _ := value.([]string)
+
+		for _, permission := range permissions {
+			if !rbac.HasPermission(granted, permission) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Access denied", "missing_permission": permission})
+				c.Abort()
+				return
+			}
+		}
+
+		if c.GetString("role") == "admin" && !c.GetBool("mfa") && config.RequireAdminTwoFactor() {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Two-factor authentication required"})
+			c.Abort()
+			return
+		}
+
+		// Thao tác thay đổi dữ liệu qua route cần quyền được ghi audit log
+		audit.Handle(c)
+	}
+}
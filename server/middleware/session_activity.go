@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"ecommerce-project/config"
+	"ecommerce-project/models"
+
+	"github.com/google/uuid"
+)
+
+// sessionTouches lưu lần cuối mỗi phiên được ghi LastUsedAt trên instance này,
+// để request liên tiếp của cùng phiên không ghi database mỗi lần.
+var sessionTouches = struct {
+	sync.Mutex
+	last map[uuid.UUID]time.Time
+}{last: map[uuid.UUID]time.Time{}}
+
+// maxTrackedSessions giới hạn số phiên được nhớ trong sessionTouches
+const maxTrackedSessions = 10000
+
+// touchSession cập nhật RefreshToken.LastUsedAt của phiên đang dùng, tối đa một lần mỗi
+// SESSION_ACTIVITY_INTERVAL (mặc định 5 phút). Điều kiện last_used_at trong câu UPDATE giữ
+// giới hạn này đúng cả khi chạy nhiều instance.
+func touchSession(sessionID uuid.UUID) {
+	interval := config.GetEnvDuration("SESSION_ACTIVITY_INTERVAL", 5*time.Minute)
+	now := time.Now()
+
+	sessionTouches.Lock()
+	if last, ok := sessionTouches.last[sessionID]; ok && now.Sub(last) < interval {
+		sessionTouches.Unlock()
+		return
+	}
+	if len(sessionTouches.last) >= maxTrackedSessions {
+		for id, last := range sessionTouches.last {
+			if now.Sub(last) >= interval {
+				delete(sessionTouches.last, id)
+			}
+		}
+	}
+	sessionTouches.last[sessionID] = now
+	sessionTouches.Unlock()
+
+	if err := config.DB.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL AND last_used_at < ?", sessionID, now.Add(-interval)).
+		Update("last_used_at", now).Error; err != nil {
+		log.Println("Failed to update session activity:", err)
+	}
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Các hành động được ghi trong audit log
+const (
+	AuditActionCreate = "create"
+	AuditActionUpdate = "update"
+	AuditActionDelete = "delete"
+)
+
+// AuditChange là giá trị trước và sau khi thay đổi của một trường
+type AuditChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// AuditLog ghi lại một thao tác thay đổi dữ liệu của admin hoặc nhân viên qua route cần quyền
+// (middleware.RequirePermission).
+// Bảng chỉ cho phép thêm mới: trigger trong database từ chối UPDATE, DELETE và TRUNCATE.
+type AuditLog struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	ActorID   *uuid.UUID `gorm:"type:uuid;index" json:"actor_id"`
+	ActorRole string     `gorm:"size:10" json:"actor_role"`
+	// ImpersonatorID là admin đang giả danh ActorID khi thực hiện thao tác (nếu có)
+	ImpersonatorID *uuid.UUID `gorm:"type:uuid" json:"impersonator_id,omitempty"`
+	IPAddress      string     `gorm:"size:45" json:"ip_address"`
+	Method         string     `gorm:"size:10;not null" json:"method"`
+	// Route là mẫu route của gin (ví dụ /api/admin/products/:id), Path là đường dẫn thực tế
+	Route      string `gorm:"size:255;not null;index" json:"route"`
+	Path       string `gorm:"size:500" json:"path"`
+	StatusCode int    `json:"status_code"`
+	Action     string `gorm:"size:10;not null;index" json:"action"`
+	EntityType string `gorm:"size:50;index:idx_audit_entity" json:"entity_type"`
+	EntityID   string `gorm:"size:100;index:idx_audit_entity" json:"entity_id"`
+	// Changes chứa các trường đã thay đổi với giá trị trước và sau
+	Changes   map[string]AuditChange `gorm:"type:jsonb;serializer:json" json:"changes"`
+	CreatedAt time.Time              `gorm:"default:now();index" json:"created_at"`
+}
@@ -4,12 +4,17 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 // Category represents a product category
 type Category struct {
     ID        uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
     Name      string    `gorm:"size:100;not null" json:"name"`
+    // SpecSchema là danh sách thông số kỹ thuật mà sản phẩm thuộc danh mục có thể khai báo
+    SpecSchema []SpecDefinition `gorm:"type:jsonb;serializer:json" json:"spec_schema"`
     CreatedAt time.Time `json:"created_at"`
     UpdatedAt time.Time `json:"updated_at"`
+    // DeletedAt được gán khi danh mục bị chuyển vào thùng rác (soft delete)
+    DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at"`
 }
\ No newline at end of file
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ProductCompatibility cho biết phụ kiện AccessoryID (ốp lưng, dán màn hình, sạc...) dùng được với thiết bị DeviceID
+type ProductCompatibility struct {
+	AccessoryID uuid.UUID `gorm:"type:uuid;primaryKey" json:"accessory_id"`
+	DeviceID    uuid.UUID `gorm:"type:uuid;primaryKey;index" json:"device_id"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// CompatibilityPair là một cặp phụ kiện - thiết bị trong thao tác hàng loạt
+type CompatibilityPair struct {
+	AccessoryID string `json:"accessory_id" binding:"required,uuid"`
+	DeviceID    string `json:"device_id" binding:"required,uuid"`
+}
+
+// BulkCompatibilityInput thêm và xoá nhiều cặp tương thích trong một request
+type BulkCompatibilityInput struct {
+	Add    []CompatibilityPair `json:"add" binding:"dive"`
+	Remove []CompatibilityPair `json:"remove" binding:"dive"`
+}
+
+// SetCompatibleDevicesInput thay thế toàn bộ danh sách thiết bị tương thích của một phụ kiện
+type SetCompatibleDevicesInput struct {
+	DeviceIDs []string `json:"device_ids" binding:"dive,uuid"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// LoginAttempt lưu bộ đếm đăng nhập thất bại của một khoá (tài khoản hoặc IP) cho bruteforce.DBStore,
+// để mọi instance của server dùng chung bộ đếm. Bản ghi hết hiệu lực sau ExpiresAt.
+type LoginAttempt struct {
+	Key         string    `gorm:"size:320;primaryKey"`
+	Failures    int       `gorm:"not null;default:0"`
+	LastFailure time.Time `gorm:"not null"`
+	LockedUntil *time.Time
+	ExpiresAt   time.Time `gorm:"not null;index"`
+}
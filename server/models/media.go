@@ -0,0 +1,94 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MediaRendition là một bản thu nhỏ của ảnh (thumbnail, medium, large)
+type MediaRendition struct {
+	URL         string `json:"url"`
+	StorageKey  string `json:"-"`
+	ContentType string `json:"content_type"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	Size        int64  `json:"size"`
+}
+
+// Media là một ảnh trong thư viện media. Định dạng, kích thước và checksum được xác định
+// từ nội dung file khi upload; StorageKey là đường dẫn của file gốc trong storage.
+type Media struct {
+	ID          uuid.UUID                 `gorm:"type:uuid;primaryKey" json:"id"`
+	OwnerID     uuid.UUID                 `gorm:"type:uuid;not null;index" json:"owner_id"`
+	Filename    string                    `gorm:"size:255;not null" json:"filename"`
+	ContentType string                    `gorm:"size:100;not null" json:"content_type"`
+	Size        int64                     `gorm:"not null" json:"size"`
+	Width       int                       `json:"width"`
+	Height      int                       `json:"height"`
+	Checksum    string                    `gorm:"size:64;not null;uniqueIndex" json:"checksum"`
+	AltText     string                    `gorm:"size:500" json:"alt_text"`
+	StorageKey  string                    `gorm:"size:500;not null" json:"-"`
+	URL         string                    `gorm:"size:1000;not null" json:"url"`
+	Renditions  map[string]MediaRendition `gorm:"type:jsonb;serializer:json" json:"renditions"`
+	CreatedAt   time.Time                 `json:"created_at"`
+	UpdatedAt   time.Time                 `json:"updated_at"`
+}
+
+// UpdateMediaInput cập nhật thông tin mô tả của media
+type UpdateMediaInput struct {
+	AltText *string `json:"alt_text" binding:"omitempty,max=500"`
+}
+
+// ProductImage gắn một media vào sản phẩm theo thứ tự hiển thị; mỗi sản phẩm có tối đa một ảnh chính
+type ProductImage struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
+	ProductID uuid.UUID `gorm:"type:uuid;not null;index" json:"product_id"`
+	MediaID   uuid.UUID `gorm:"type:uuid;not null;index" json:"media_id"`
+	Media     Media     `gorm:"foreignKey:MediaID;references:ID" json:"media"`
+	Position  int       `gorm:"not null" json:"position"`
+	Primary   bool      `gorm:"not null;default:false" json:"primary"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ProductImageInput là một ảnh trong danh sách ảnh của sản phẩm, thứ tự trong mảng là thứ tự hiển thị
+type ProductImageInput struct {
+	MediaID string `json:"media_id" binding:"required,uuid"`
+	Primary bool   `json:"primary"`
+}
+
+// SetProductImagesInput thay thế toàn bộ ảnh của sản phẩm
+type SetProductImagesInput struct {
+	Images []ProductImageInput `json:"images" binding:"dive"`
+}
+
+// MediaUpload là một lượt upload trực tiếp lên storage qua URL được ký trước.
+// Client upload vào StorageKey (khu vực tạm uploads/<id>); khi client gọi hoàn tất và file đã được kiểm tra,
+// file được sao chép sang vị trí của media và Media được tạo với cùng ID.
+// ExpiresAt là hạn chót để hoàn tất, sau đó lượt upload và file tạm (nếu có) bị dọn.
+type MediaUpload struct {
+	ID          uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
+	OwnerID     uuid.UUID `gorm:"type:uuid;not null;index" json:"owner_id"`
+	Filename    string    `gorm:"size:255;not null" json:"filename"`
+	ContentType string    `gorm:"size:100;not null" json:"content_type"`
+	// Size là dung lượng (byte) client đã khai báo, file upload phải có đúng dung lượng này
+	Size       int64     `gorm:"not null;default:0" json:"size"`
+	StorageKey string    `gorm:"size:500;not null" json:"-"`
+	ExpiresAt  time.Time `gorm:"not null;index" json:"expires_at"`
+	// CompletedAt được gán khi bắt đầu hoàn tất (tránh hai lần hoàn tất đồng thời) và được xoá nếu thất bại
+	CompletedAt *time.Time `json:"completed_at"`
+	MediaID     *uuid.UUID `gorm:"type:uuid" json:"media_id"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// CreateMediaUploadInput là thông tin file mà client sắp upload trực tiếp
+type CreateMediaUploadInput struct {
+	Filename    string `json:"filename" binding:"required,max=255"`
+	ContentType string `json:"content_type" binding:"required"`
+	Size        int64  `json:"size" binding:"required,min=1"`
+}
+
+// CompleteMediaUploadInput là thông tin bổ sung khi hoàn tất upload
+type CompleteMediaUploadInput struct {
+	AltText string `json:"alt_text" binding:"max=500"`
+}
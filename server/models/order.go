@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Trạng thái của đơn hàng
+const (
+	OrderStatusPlaced    = "placed"
+	OrderStatusShipped   = "shipped"
+	OrderStatusDelivered = "delivered"
+)
+
+// Order lưu đơn hàng được tạo khi người dùng thanh toán giỏ hàng.
+// PaymentProvider và PaymentReference xác định giao dịch thanh toán, dùng khi hoàn tiền.
+type Order struct {
+	ID               uuid.UUID   `gorm:"type:uuid;primary_key" json:"id"`
+	UserID           uuid.UUID   `gorm:"type:uuid;not null;index" json:"user_id"`
+	Status           string      `gorm:"size:20;not null;default:'placed';index" json:"status"`
+	Total            float64     `gorm:"type:numeric(12,2);not null" json:"total"`
+	PaymentProvider  string      `gorm:"size:50;not null" json:"payment_provider"`
+	PaymentReference string      `gorm:"size:255" json:"payment_reference"`
+	PlacedAt         time.Time   `gorm:"not null" json:"placed_at"`
+	ShippedAt        *time.Time  `json:"shipped_at"`
+	DeliveredAt      *time.Time  `json:"delivered_at"`
+	UpdatedAt        time.Time   `json:"updated_at"`
+	Lines            []OrderLine `gorm:"foreignKey:OrderID" json:"lines,omitempty"`
+}
+
+// OrderLine là một dòng của đơn hàng. Tên sản phẩm, thuộc tính và đơn giá được chụp lại
+// tại thời điểm mua để không thay đổi khi sản phẩm được sửa sau đó.
+type OrderLine struct {
+	ID          uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
+	OrderID     uuid.UUID `gorm:"type:uuid;not null;index" json:"order_id"`
+	ProductID   uuid.UUID `gorm:"type:uuid;not null;index" json:"product_id"`
+	VariantID   uuid.UUID `gorm:"type:uuid;not null;index" json:"variant_id"`
+	ProductName string    `gorm:"size:255;not null" json:"product_name"`
+	Color       string    `gorm:"size:50" json:"color"`
+	Capacity    string    `gorm:"size:50" json:"capacity"`
+	UnitPrice   float64   `gorm:"type:numeric(10,2);not null" json:"unit_price"`
+	Quantity    int       `gorm:"not null" json:"quantity"`
+}
+
+// UpdateOrderStatusInput dùng để admin chuyển trạng thái giao hàng của đơn
+type UpdateOrderStatusInput struct {
+	Status string `json:"status" binding:"required,oneof=shipped delivered"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Trạng thái gửi của một email trong outbox
+const (
+	OutboxStatusPending = "pending"
+	// OutboxStatusSending là email đã được một dispatcher nhận gửi; NextAttemptAt là hạn nhận,
+	// quá hạn (dispatcher bị dừng giữa chừng) thì email được nhận gửi lại.
+	OutboxStatusSending = "sending"
+	OutboxStatusSent    = "sent"
+	OutboxStatusFailed  = "failed"
+)
+
+// OutboxMessage là một email giao dịch chờ gửi. Bản ghi được ghi trong cùng transaction
+// với nghiệp vụ phát sinh email, sau đó dispatcher chạy nền sẽ gửi và thử lại khi lỗi.
+type OutboxMessage struct {
+	ID            uuid.UUID  `gorm:"type:uuid;primary_key" json:"id"`
+	Template      string     `gorm:"size:50;not null" json:"template"`
+	Locale        string     `gorm:"size:5;not null" json:"locale"`
+	Recipient     string     `gorm:"size:100;not null" json:"recipient"`
+	Subject       string     `gorm:"size:255;not null" json:"subject"`
+	HTMLBody      string     `gorm:"type:text" json:"html_body"`
+	TextBody      string     `gorm:"type:text" json:"text_body"`
+	Status        string     `gorm:"size:20;not null;default:'pending';index:idx_outbox_status_next_attempt" json:"status"`
+	Attempts      int        `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt time.Time  `gorm:"not null;index:idx_outbox_status_next_attempt" json:"next_attempt_at"`
+	LastError     string     `gorm:"type:text" json:"last_error"`
+	SentAt        *time.Time `json:"sent_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
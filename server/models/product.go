@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 // Product lưu thông tin chung của sản phẩm
@@ -12,29 +13,106 @@ type Product struct {
     Name        string            `gorm:"size:200;not null" json:"name"`
     Description string            `json:"description"`
     // ImageURLs lưu danh sách URL hình ảnh (được lưu dưới dạng JSON trong database)
+    // Luôn được suy ra từ Images (ảnh chính đứng đầu); URL gửi lên phải là URL ảnh gốc trong thư viện media
     ImageURLs   []string         `gorm:"type:json;serializer:json" json:"image_urls"`
-    Public      bool              `gorm:"default:true" json:"public"`
+    // Images là các ảnh trong thư viện media của sản phẩm, sắp theo Position
+    Images      []ProductImage    `gorm:"foreignKey:ProductID;references:ID" json:"images,omitempty"`
+    // Status là trạng thái vòng đời: draft, scheduled, published, archived
+    Status      string            `gorm:"size:20;not null;default:'draft';index" json:"status"`
+    // PublishAt là thời điểm sản phẩm scheduled được hiển thị, UnpublishAt là thời điểm tự động ngừng hiển thị
+    PublishAt   *time.Time        `json:"publish_at"`
+    UnpublishAt *time.Time        `json:"unpublish_at"`
+    // Public cho biết sản phẩm đang hiển thị công khai (suy ra từ Status, giữ cho client cũ)
+    Public      bool              `gorm:"-" json:"public"`
     Variants    []ProductVariant  `gorm:"foreignKey:ProductID;references:ID" json:"variants"`
     CategoryID  uuid.UUID         `gorm:"type:uuid;not null" json:"category_id"`
+    // Specs lưu giá trị thông số kỹ thuật theo schema của danh mục (key -> số, chuỗi hoặc boolean)
+    Specs       map[string]interface{} `gorm:"type:jsonb;serializer:json" json:"specs"`
     Category    Category          `gorm:"foreignKey:CategoryID;references:ID" json:"category"`
+    // Điểm đánh giá trung bình và số lượng đánh giá đã được duyệt
+    RatingAverage float64         `gorm:"type:numeric(3,2);default:0" json:"rating_average"`
+    RatingCount   int             `gorm:"default:0" json:"rating_count"`
+    // RatingHistogram đếm số đánh giá theo từng mức sao (1-5), chỉ trả về ở GetProduct
+    RatingHistogram map[int]int64 `gorm:"-" json:"rating_histogram,omitempty"`
     CreatedAt   time.Time         `json:"created_at"`
     UpdatedAt   time.Time         `json:"updated_at"`
+    // DeletedAt được gán khi sản phẩm bị chuyển vào thùng rác (soft delete)
+    DeletedAt   gorm.DeletedAt    `gorm:"index" json:"deleted_at"`
 }
 
 // CreateProductInput chỉ chứa các trường thông tin chung của sản phẩm
 type CreateProductInput struct {
     Name        string   `json:"name" binding:"required"`
     Description string   `json:"description"`
-    // Bắt buộc phải có mảng URL, mỗi URL hợp lệ
+    // Bắt buộc phải có mảng URL, mỗi URL là URL ảnh gốc của một media trong thư viện (ảnh đầu tiên là ảnh chính)
     ImageURLs   []string `json:"image_urls" binding:"required,dive,url"`
     CategoryID  string   `json:"category_id" binding:"required,uuid"`
+    Specs       map[string]interface{} `json:"specs"`
+    // Status mặc định là draft; Public (client cũ) tương đương published/draft
+    Status      string     `json:"status" binding:"omitempty,oneof=draft scheduled published"`
+    PublishAt   *time.Time `json:"publish_at"`
+    UnpublishAt *time.Time `json:"unpublish_at"`
+    Public      *bool      `json:"public"`
 }
 
 // UpdateProductInput chỉ cho phép cập nhật thông tin chung của sản phẩm
 type UpdateProductInput struct {
     Name        *string   `json:"name"`
     Description *string   `json:"description"`
+    // ImageURLs thay thế toàn bộ ảnh của sản phẩm, cùng quy tắc với CreateProductInput
     ImageURLs   *[]string `json:"image_urls"`
-    Public      *bool     `json:"public"`
     CategoryID  *string   `json:"category_id" binding:"omitempty,uuid"`
+    // Specs thay thế toàn bộ thông số kỹ thuật của sản phẩm
+    Specs       *map[string]interface{} `json:"specs"`
+    // Public (client cũ) chuyển sản phẩm sang published hoặc draft
+    Public      *bool     `json:"public"`
+}
+
+// ProductStatusInput chuyển trạng thái vòng đời của sản phẩm.
+// scheduled cần publish_at; unpublish_at (nếu có) phải sau publish_at.
+type ProductStatusInput struct {
+    Status      string     `json:"status" binding:"required,oneof=draft scheduled published archived"`
+    PublishAt   *time.Time `json:"publish_at"`
+    UnpublishAt *time.Time `json:"unpublish_at"`
+}
+
+// Các trạng thái vòng đời của sản phẩm
+const (
+    ProductStatusDraft     = "draft"
+    ProductStatusScheduled = "scheduled"
+    ProductStatusPublished = "published"
+    ProductStatusArchived  = "archived"
+)
+
+// IsVisible cho biết sản phẩm có được hiển thị công khai tại thời điểm now hay không
+func (p Product) IsVisible(now time.Time) bool {
+    switch {
+    case p.UnpublishAt != nil && !now.Before(*p.UnpublishAt):
+        return false
+    case p.Status == ProductStatusPublished:
+        return true
+    case p.Status == ProductStatusScheduled:
+        return p.PublishAt != nil && !now.Before(*p.PublishAt)
+    default:
+        return false
+    }
+}
+
+// AfterFind suy ra Public từ trạng thái vòng đời
+func (p *Product) AfterFind(tx *gorm.DB) error {
+    p.Public = p.IsVisible(time.Now())
+    return nil
+}
+
+// VisibleProducts giới hạn truy vấn sản phẩm ở các sản phẩm đang hiển thị công khai.
+// Thời điểm publish_at/unpublish_at được so trực tiếp nên không phụ thuộc vào độ trễ của job lập lịch.
+func VisibleProducts(db *gorm.DB) *gorm.DB {
+    now := time.Now()
+    return db.Where("(products.status = ? OR (products.status = ? AND products.publish_at <= ?)) AND (products.unpublish_at IS NULL OR products.unpublish_at > ?)",
+        ProductStatusPublished, ProductStatusScheduled, now, now)
+}
+
+// VisibleVariants giới hạn truy vấn variant ở các variant thuộc sản phẩm đang hiển thị công khai
+func VisibleVariants(db *gorm.DB) *gorm.DB {
+    return db.Where("product_variants.product_id IN (?)", db.Session(&gorm.Session{NewDB: true}).Model(&Product{}).Scopes(VisibleProducts).Select("id"))
 }
\ No newline at end of file
@@ -0,0 +1,35 @@
+package models
+
+import "github.com/google/uuid"
+
+// PriceRange là khoảng giá của các variant đang bán của một sản phẩm
+type PriceRange struct {
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+}
+
+// ComparisonProduct là cột sản phẩm trong bảng so sánh
+type ComparisonProduct struct {
+	ID         uuid.UUID `json:"id"`
+	Name       string    `json:"name"`
+	ImageURL   string    `json:"image_url"`
+	CategoryID uuid.UUID `json:"category_id"`
+	InStock    bool      `json:"in_stock"`
+}
+
+// ComparisonRow là một hàng của bảng so sánh, Values có cùng thứ tự với danh sách sản phẩm
+// (nil nếu sản phẩm không có giá trị). Differs cho biết các sản phẩm có giá trị khác nhau.
+type ComparisonRow struct {
+	Key     string        `json:"key"`
+	Label   string        `json:"label"`
+	Type    string        `json:"type"`
+	Unit    string        `json:"unit,omitempty"`
+	Values  []interface{} `json:"values"`
+	Differs bool          `json:"differs"`
+}
+
+// ProductComparison là bảng so sánh sản phẩm: các hàng giá, màu, dung lượng rồi tới thông số kỹ thuật
+type ProductComparison struct {
+	Products []ComparisonProduct `json:"products"`
+	Rows     []ComparisonRow     `json:"rows"`
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ProductSnapshot là nội dung của sản phẩm tại một phiên bản. Variant (giá, tồn kho, trạng thái)
+// có vòng đời riêng, được theo dõi qua audit log và lịch sử tồn kho nên không thuộc phiên bản.
+type ProductSnapshot struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	ImageURLs   []string               `json:"image_urls"`
+	CategoryID  uuid.UUID              `json:"category_id"`
+	Specs       map[string]interface{} `json:"specs"`
+}
+
+// ProductRevision lưu một phiên bản nội dung của sản phẩm, được tạo sau mỗi lần tạo,
+// cập nhật hoặc khôi phục sản phẩm. Number tăng dần theo từng sản phẩm, bắt đầu từ 1.
+type ProductRevision struct {
+	ID        uuid.UUID       `gorm:"type:uuid;primaryKey" json:"id"`
+	ProductID uuid.UUID       `gorm:"type:uuid;not null;uniqueIndex:idx_product_revision_number" json:"product_id"`
+	Number    int             `gorm:"not null;uniqueIndex:idx_product_revision_number" json:"number"`
+	Snapshot  ProductSnapshot `gorm:"type:jsonb;serializer:json" json:"snapshot"`
+	ActorID   *uuid.UUID      `gorm:"type:uuid" json:"actor_id"`
+	// RolledBackFrom là số phiên bản được khôi phục nếu phiên bản này được tạo bởi rollback
+	RolledBackFrom *int      `json:"rolled_back_from,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// ProductRevisionResponse là một phiên bản kèm các trường thay đổi so với phiên bản trước đó
+type ProductRevisionResponse struct {
+	ProductRevision
+	Changes map[string]AuditChange `json:"changes"`
+}
+
+// NewProductSnapshot tạo snapshot nội dung từ sản phẩm
+func NewProductSnapshot(product Product) ProductSnapshot {
+	return ProductSnapshot{
+		Name:        product.Name,
+		Description: product.Description,
+		ImageURLs:   product.ImageURLs,
+		CategoryID:  product.CategoryID,
+		Specs:       product.Specs,
+	}
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Các loại tương tác được dùng để tính gợi ý sản phẩm
+const (
+	InteractionView     = "view"
+	InteractionPurchase = "purchase"
+)
+
+// Lý do một sản phẩm được gợi ý
+const (
+	RecommendationCoPurchase = "co_purchase"
+	RecommendationCoView     = "co_view"
+	RecommendationSimilar    = "similar"
+	// RecommendationPopular dùng cho người dùng chưa có tương tác nào
+	RecommendationPopular = "popular"
+)
+
+// ProductInteraction ghi nhận một lần xem hoặc mua sản phẩm. SubjectKey xác định người tương tác
+// ("user:<id>" hoặc "visitor:<id>" từ cookie visitor_id do server ký) để tìm các sản phẩm được
+// xem/mua cùng nhau.
+type ProductInteraction struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	SubjectKey string     `gorm:"size:100;not null;index;index:idx_product_interaction_subject,priority:1" json:"subject_key"`
+	UserID     *uuid.UUID `gorm:"type:uuid;index" json:"user_id"`
+	ProductID  uuid.UUID  `gorm:"type:uuid;not null;index" json:"product_id"`
+	Kind       string     `gorm:"size:20;not null;index:idx_product_interaction_subject,priority:2" json:"kind"`
+	CreatedAt  time.Time  `gorm:"index;index:idx_product_interaction_subject,priority:3" json:"created_at"`
+}
+
+// ProductRecommendation là một sản phẩm gợi ý đã được tính sẵn bởi job làm mới gợi ý
+type ProductRecommendation struct {
+	ProductID        uuid.UUID `gorm:"type:uuid;primaryKey" json:"product_id"`
+	RelatedProductID uuid.UUID `gorm:"type:uuid;primaryKey" json:"related_product_id"`
+	Score            float64   `json:"score"`
+	Reason           string    `gorm:"size:20" json:"reason"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
@@ -6,10 +6,43 @@ import (
 	"github.com/google/uuid"
 )
 
+// RefreshToken lưu hash SHA-256 của refresh token (không lưu token gốc).
+// Mỗi lần refresh, token cũ bị thu hồi và thay bằng token mới cùng FamilyID.
+// Nếu một token đã bị thu hồi được dùng lại, toàn bộ family sẽ bị thu hồi.
 type RefreshToken struct {
-    ID        uuid.UUID `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
-    UserID    uuid.UUID `gorm:"type:uuid;not null"`
-    Token     string    `gorm:"type:text;unique;not null"`
-    ExpiresAt time.Time `gorm:"not null"`
-    CreatedAt time.Time `gorm:"default:now()"`
-}
\ No newline at end of file
+    ID           uuid.UUID  `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
+    UserID       uuid.UUID  `gorm:"type:uuid;not null"`
+    TokenHash    string     `gorm:"size:64;unique;not null"`
+    FamilyID     uuid.UUID  `gorm:"type:uuid;not null;index"`
+    ExpiresAt    time.Time  `gorm:"not null"`
+    // RevokedAt được gán khi token đã được xoay vòng, đăng xuất hoặc bị thu hồi
+    RevokedAt    *time.Time
+    // ReplacedByID trỏ tới token mới được cấp khi xoay vòng token này
+    ReplacedByID *uuid.UUID `gorm:"type:uuid"`
+    // Thông tin thiết bị của phiên đăng nhập, được giữ nguyên khi xoay vòng token
+    DeviceName   string     `gorm:"size:100"`
+    UserAgent    string     `gorm:"type:text"`
+    IPAddress    string     `gorm:"size:45"`
+    // LastUsedAt là lần cuối phiên được dùng (đăng nhập, refresh hoặc request đã xác thực,
+    // được cập nhật tối đa một lần mỗi SESSION_ACTIVITY_INTERVAL)
+    LastUsedAt   time.Time
+    // MFA cho biết phiên đăng nhập đã qua bước xác thực hai lớp
+    MFA          bool       `gorm:"not null;default:false"`
+    // ImpersonatorID là admin đang đăng nhập dưới danh nghĩa người dùng này (phiên giả danh)
+    ImpersonatorID *uuid.UUID `gorm:"type:uuid"`
+    CreatedAt    time.Time  `gorm:"default:now()"`
+}
+
+// SessionResponse là thông tin một phiên đăng nhập trả về cho người dùng.
+// ID của phiên chính là FamilyID của refresh token.
+type SessionResponse struct {
+    ID         uuid.UUID `json:"id"`
+    DeviceName string    `json:"device_name"`
+    UserAgent  string    `json:"user_agent"`
+    IPAddress  string    `json:"ip_address"`
+    LastUsedAt time.Time `json:"last_used_at"`
+    ExpiresAt  time.Time `json:"expires_at"`
+    Current    bool      `json:"current"`
+    // Impersonated cho biết phiên do admin tạo để hỗ trợ người dùng
+    Impersonated bool    `json:"impersonated"`
+}
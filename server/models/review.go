@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Trạng thái kiểm duyệt của đánh giá
+const (
+	ReviewStatusPending  = "pending"
+	ReviewStatusApproved = "approved"
+	ReviewStatusHidden   = "hidden"
+)
+
+// Review lưu đánh giá của người dùng cho một sản phẩm.
+// Mỗi người dùng chỉ được đánh giá một sản phẩm một lần.
+type Review struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
+	ProductID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_review_product_user" json:"product_id"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_review_product_user" json:"user_id"`
+	Rating    int       `gorm:"not null" json:"rating"`
+	Title     string    `gorm:"size:200" json:"title"`
+	Body      string    `gorm:"type:text" json:"body"`
+	// ImageURLs lưu danh sách URL hình ảnh đính kèm (được upload qua config.Storage)
+	ImageURLs []string `gorm:"type:json;serializer:json" json:"image_urls"`
+	// VerifiedPurchase cho biết người đánh giá đã nhận sản phẩm này trong một đơn hàng đã giao
+	VerifiedPurchase bool      `gorm:"default:false" json:"verified_purchase"`
+	Status           string    `gorm:"size:20;not null;default:'pending';index" json:"status"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// CreateReviewInput chứa dữ liệu gửi lên dưới dạng multipart/form-data,
+// các file ảnh (nếu có) được gửi qua trường "images".
+type CreateReviewInput struct {
+	Rating int    `form:"rating" binding:"required,min=1,max=5"`
+	Title  string `form:"title" binding:"max=200"`
+	Body   string `form:"body"`
+}
+
+// ModerateReviewInput dùng cho admin duyệt hoặc ẩn đánh giá
+type ModerateReviewInput struct {
+	Status string `json:"status" binding:"required,oneof=approved hidden"`
+}
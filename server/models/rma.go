@@ -0,0 +1,99 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Trạng thái của yêu cầu trả hàng (RMA)
+const (
+	ReturnStatusRequested         = "requested"
+	ReturnStatusApproved          = "approved"
+	ReturnStatusRejected          = "rejected"
+	ReturnStatusReceived          = "received"
+	ReturnStatusPartiallyRefunded = "partially_refunded"
+	ReturnStatusRefunded          = "refunded"
+)
+
+// Trạng thái của một lần hoàn tiền
+const (
+	RefundStatusPending   = "pending"
+	RefundStatusSucceeded = "succeeded"
+	RefundStatusFailed    = "failed"
+)
+
+// ReturnRequest là yêu cầu trả hàng của khách cho các dòng của một đơn hàng đã giao.
+// RefundableAmount là tổng tiền của các dòng được trả, giới hạn số tiền có thể hoàn.
+type ReturnRequest struct {
+	ID               uuid.UUID            `gorm:"type:uuid;primary_key" json:"id"`
+	OrderID          uuid.UUID            `gorm:"type:uuid;not null;index" json:"order_id"`
+	UserID           uuid.UUID            `gorm:"type:uuid;not null;index" json:"user_id"`
+	Status           string               `gorm:"size:20;not null;index" json:"status"`
+	Reason           string               `gorm:"type:text" json:"reason"`
+	RefundableAmount float64              `gorm:"type:numeric(12,2);not null" json:"refundable_amount"`
+	RefundedAmount   float64              `gorm:"type:numeric(12,2);not null;default:0" json:"refunded_amount"`
+	CreatedAt        time.Time            `json:"created_at"`
+	UpdatedAt        time.Time            `json:"updated_at"`
+	Lines            []ReturnLine         `gorm:"foreignKey:ReturnID" json:"lines,omitempty"`
+	History          []ReturnStatusChange `gorm:"foreignKey:ReturnID" json:"history,omitempty"`
+	Refunds          []Refund             `gorm:"foreignKey:ReturnID" json:"refunds,omitempty"`
+}
+
+// ReturnLine là số lượng được trả của một dòng đơn hàng
+type ReturnLine struct {
+	ID          uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
+	ReturnID    uuid.UUID `gorm:"type:uuid;not null;index" json:"return_id"`
+	OrderLineID uuid.UUID `gorm:"type:uuid;not null;index" json:"order_line_id"`
+	VariantID   uuid.UUID `gorm:"type:uuid;not null" json:"variant_id"`
+	Quantity    int       `gorm:"not null" json:"quantity"`
+	UnitPrice   float64   `gorm:"type:numeric(10,2);not null" json:"unit_price"`
+}
+
+// ReturnStatusChange lưu lịch sử chuyển trạng thái của yêu cầu trả hàng
+type ReturnStatusChange struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primary_key" json:"id"`
+	ReturnID   uuid.UUID  `gorm:"type:uuid;not null;index" json:"return_id"`
+	FromStatus string     `gorm:"size:20" json:"from_status"`
+	ToStatus   string     `gorm:"size:20;not null" json:"to_status"`
+	ActorID    *uuid.UUID `gorm:"type:uuid" json:"actor_id"`
+	Note       string     `gorm:"type:text" json:"note"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Refund là một lần hoàn tiền qua nhà cung cấp thanh toán.
+// Refund ở trạng thái pending được tính vào số tiền đã hoàn để hai yêu cầu đồng thời không hoàn quá.
+type Refund struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primary_key" json:"id"`
+	ReturnID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"return_id"`
+	Amount      float64    `gorm:"type:numeric(12,2);not null" json:"amount"`
+	Status      string     `gorm:"size:20;not null" json:"status"`
+	Provider    string     `gorm:"size:50;not null" json:"provider"`
+	ProviderRef string     `gorm:"size:255" json:"provider_ref"`
+	Error       string     `gorm:"type:text" json:"error,omitempty"`
+	ActorID     *uuid.UUID `gorm:"type:uuid" json:"actor_id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+type ReturnLineInput struct {
+	OrderLineID string `json:"order_line_id" binding:"required,uuid"`
+	Quantity    int    `json:"quantity" binding:"required,min=1"`
+}
+
+// CreateReturnInput là yêu cầu trả hàng của khách cho một đơn hàng
+type CreateReturnInput struct {
+	Reason string            `json:"reason" binding:"required,max=2000"`
+	Lines  []ReturnLineInput `json:"lines" binding:"required,min=1,dive"`
+}
+
+// ReviewReturnInput dùng khi admin duyệt, từ chối hoặc xác nhận đã nhận hàng
+type ReviewReturnInput struct {
+	Note string `json:"note" binding:"max=2000"`
+}
+
+// RefundReturnInput dùng để hoàn tiền; không truyền amount nghĩa là hoàn toàn bộ số tiền còn lại
+type RefundReturnInput struct {
+	Amount *float64 `json:"amount" binding:"omitempty,gt=0"`
+	Note   string   `json:"note" binding:"max=2000"`
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Role là vai trò nhân viên gồm một tập quyền có tên (ví dụ "product:write").
+// System = true với các vai trò có sẵn trong danh mục, không thể xoá.
+type Role struct {
+	Name        string    `gorm:"size:50;primaryKey" json:"name"`
+	Description string    `gorm:"size:255" json:"description"`
+	Permissions []string  `gorm:"type:jsonb;serializer:json" json:"permissions"`
+	System      bool      `gorm:"not null;default:false" json:"system"`
+	CreatedAt   time.Time `gorm:"default:now()" json:"created_at"`
+	UpdatedAt   time.Time `gorm:"default:now()" json:"updated_at"`
+}
+
+// UserRole gán vai trò nhân viên cho người dùng
+type UserRole struct {
+	UserID    uuid.UUID `gorm:"type:uuid;primaryKey" json:"user_id"`
+	RoleName  string    `gorm:"size:50;primaryKey" json:"role_name"`
+	CreatedAt time.Time `gorm:"default:now()" json:"created_at"`
+}
+
+// RoleInput là dữ liệu tạo hoặc cập nhật vai trò
+type RoleInput struct {
+	Name        string   `json:"name" validate:"omitempty,min=2,max=50"`
+	Description string   `json:"description" validate:"max=255"`
+	Permissions []string `json:"permissions" validate:"required"`
+}
+
+// AssignRolesInput thay thế toàn bộ vai trò nhân viên của người dùng
+type AssignRolesInput struct {
+	Roles []string `json:"roles" validate:"required"`
+}
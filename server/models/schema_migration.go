@@ -0,0 +1,9 @@
+package models
+
+import "time"
+
+// SchemaMigration ghi lại các migration dữ liệu chỉ được chạy một lần (xem config.runMigrationOnce)
+type SchemaMigration struct {
+	Name      string    `gorm:"size:100;primaryKey" json:"name"`
+	AppliedAt time.Time `gorm:"not null" json:"applied_at"`
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Các loại sự kiện bảo mật được ghi lại
+const (
+	SecurityEventAccountLocked   = "account_locked"
+	SecurityEventIPLocked        = "ip_locked"
+	SecurityEventAccountUnlocked = "account_unlocked"
+	SecurityEventAccountDisabled = "account_disabled"
+	SecurityEventAccountEnabled  = "account_enabled"
+	SecurityEventRoleChanged     = "role_changed"
+	// Admin bắt đầu phiên giả danh người dùng
+	SecurityEventImpersonation = "impersonation_started"
+)
+
+// SecurityEvent ghi lại các sự kiện bảo mật liên quan tới đăng nhập (khoá tài khoản, mở khoá...)
+type SecurityEvent struct {
+	ID     uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	Type   string     `gorm:"size:30;not null;index" json:"type"`
+	UserID *uuid.UUID `gorm:"type:uuid;index" json:"user_id,omitempty"`
+	// ActorID là admin thực hiện thao tác (nếu có)
+	ActorID   *uuid.UUID `gorm:"type:uuid" json:"actor_id,omitempty"`
+	Email     string     `gorm:"size:255" json:"email,omitempty"`
+	IPAddress string     `gorm:"size:45" json:"ip_address,omitempty"`
+	Details   string     `gorm:"type:text" json:"details,omitempty"`
+	CreatedAt time.Time  `gorm:"default:now();index" json:"created_at"`
+}
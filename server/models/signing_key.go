@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// SigningKey là khoá bất đối xứng dùng để ký access token, định danh bằng kid.
+// Khoá đang dùng để ký là khoá mới nhất chưa có RetiredAt. Khoá đã ngừng ký vẫn được
+// công bố trong JWKS tới ExpiresAt để các token đã cấp còn xác thực được.
+// Khoá bí mật chỉ được lưu dưới dạng mã hoá (xem utils.encryptPrivateKey).
+type SigningKey struct {
+	ID                  string     `gorm:"size:64;primaryKey" json:"kid"`
+	Algorithm           string     `gorm:"size:10;not null" json:"alg"`
+	EncryptedPrivateKey string     `gorm:"type:text" json:"-"`
+	CreatedAt           time.Time  `gorm:"not null" json:"created_at"`
+	RetiredAt           *time.Time `json:"retired_at"`
+	ExpiresAt           *time.Time `json:"expires_at"`
+}
@@ -0,0 +1,23 @@
+package models
+
+// Các kiểu thông số kỹ thuật của sản phẩm
+const (
+	SpecTypeNumber  = "number"
+	SpecTypeEnum    = "enum"
+	SpecTypeBoolean = "boolean"
+	SpecTypeText    = "text"
+)
+
+// SpecDefinition mô tả một thông số kỹ thuật trong schema của danh mục
+// (ví dụ key "screen_size", kiểu number, đơn vị "inch").
+type SpecDefinition struct {
+	// Key là tên thông số trong Product.Specs và trong bộ lọc spec.<key> của GetProducts
+	Key   string `json:"key"`
+	Label string `json:"label"`
+	Type  string `json:"type"`
+	// Unit chỉ dùng cho kiểu number (ví dụ "GB", "mAh", "inch")
+	Unit string `json:"unit,omitempty"`
+	// Options là các giá trị hợp lệ của kiểu enum
+	Options  []string `json:"options,omitempty"`
+	Required bool     `json:"required"`
+}
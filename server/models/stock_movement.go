@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Lý do thay đổi tồn kho
+const (
+	StockMovementSale       = "sale"
+	StockMovementReturn     = "return"
+	StockMovementAdjustment = "adjustment"
+)
+
+// StockMovement ghi lại mỗi lần tồn kho của variant thay đổi (bán, nhận hàng trả, điều chỉnh).
+// ReferenceID trỏ tới đơn hàng hoặc yêu cầu trả hàng gây ra thay đổi, nếu có.
+type StockMovement struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primary_key" json:"id"`
+	VariantID   uuid.UUID  `gorm:"type:uuid;not null;index" json:"variant_id"`
+	Delta       int        `gorm:"not null" json:"delta"`
+	StockAfter  int        `gorm:"not null" json:"stock_after"`
+	Reason      string     `gorm:"size:30;not null;index" json:"reason"`
+	ReferenceID *uuid.UUID `gorm:"type:uuid;index" json:"reference_id"`
+	ActorID     *uuid.UUID `gorm:"type:uuid" json:"actor_id"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
@@ -16,6 +16,26 @@ type User struct {
     Address      string    `gorm:"type:text"`
     PhoneNumber  string    `gorm:"size:15"`
     Role         string    `gorm:"size:10;default:'user'"`
+    // Locale là ngôn ngữ dùng cho email gửi tới người dùng (vi hoặc en)
+    Locale       string    `gorm:"size:5;default:'vi'"`
+    // EmailVerifiedAt được gán khi người dùng xác minh email qua link gửi lúc đăng ký
+    EmailVerifiedAt *time.Time
+    // TokenVersion được nhúng vào access token, tăng giá trị này sẽ vô hiệu hoá mọi access token đã cấp
+    TokenVersion int       `gorm:"not null;default:0"`
+    // Xác thực hai lớp (TOTP). TOTPSecret được tạo khi đăng ký và chỉ có hiệu lực sau khi
+    // người dùng xác nhận bằng một mã hợp lệ (TOTPEnabled = true).
+    TOTPSecret   string    `gorm:"size:64"`
+    TOTPEnabled  bool      `gorm:"not null;default:false"`
+    // TOTPLastStep là bước thời gian của mã TOTP dùng gần nhất, dùng để chống dùng lại mã
+    TOTPLastStep int64     `gorm:"not null;default:0"`
+    // AvatarURL là URL ảnh đại diện đã upload qua config.Storage
+    AvatarURL    string    `gorm:"size:500"`
+    // PendingEmail là email mới đang chờ người dùng xác minh trước khi thay thế Email
+    PendingEmail string    `gorm:"size:100"`
+    // DeletedAt được gán khi người dùng xoá tài khoản; dữ liệu cá nhân đã được ẩn danh hoá
+    DeletedAt    *time.Time
+    // DisabledAt được gán khi admin vô hiệu hoá tài khoản; tài khoản bị vô hiệu hoá không thể đăng nhập
+    DisabledAt   *time.Time
     CreatedAt    time.Time `gorm:"default:now()"`
     UpdatedAt    time.Time `gorm:"default:now()"`
 }
@@ -23,4 +43,94 @@ type User struct {
 func (u *User) BeforeCreate(tx *gorm.DB) (err error) {
     u.ID = uuid.New()
     return
-}
\ No newline at end of file
+}
+
+// UserResponse là thông tin người dùng trả về cho client, không chứa mật khẩu hay secret 2FA
+type UserResponse struct {
+    ID               uuid.UUID `json:"id"`
+    Username         string    `json:"username"`
+    Email            string    `json:"email"`
+    PendingEmail     string    `json:"pending_email,omitempty"`
+    EmailVerified    bool      `json:"email_verified"`
+    FullName         string    `json:"full_name"`
+    Address          string    `json:"address"`
+    PhoneNumber      string    `json:"phone_number"`
+    AvatarURL        string    `json:"avatar_url"`
+    Role             string    `json:"role"`
+    Locale           string    `json:"locale"`
+    TwoFactorEnabled bool      `json:"two_factor_enabled"`
+    // HasPassword là false với tài khoản chỉ đăng nhập qua Google, Facebook...
+    HasPassword      bool      `json:"has_password"`
+    Disabled         bool      `json:"disabled"`
+    Deleted          bool      `json:"deleted"`
+    CreatedAt        time.Time `json:"created_at"`
+}
+
+// NewUserResponse tạo UserResponse từ User
+func NewUserResponse(user User) UserResponse {
+    return UserResponse{
+        ID:               user.ID,
+        Username:         user.Username,
+        Email:            user.Email,
+        PendingEmail:     user.PendingEmail,
+        EmailVerified:    user.EmailVerifiedAt != nil,
+        FullName:         user.FullName,
+        Address:          user.Address,
+        PhoneNumber:      user.PhoneNumber,
+        AvatarURL:        user.AvatarURL,
+        Role:             user.Role,
+        Locale:           user.Locale,
+        TwoFactorEnabled: user.TOTPEnabled,
+        HasPassword:      user.PasswordHash != "",
+        Disabled:         user.DisabledAt != nil,
+        Deleted:          user.DeletedAt != nil,
+        CreatedAt:        user.CreatedAt,
+    }
+}
+
+// UpdateProfileInput là dữ liệu cập nhật hồ sơ, gửi dạng JSON hoặc multipart/form-data
+// (ảnh đại diện qua trường "avatar")
+type UpdateProfileInput struct {
+    FullName    *string `json:"full_name" form:"full_name" validate:"omitempty,max=100"`
+    PhoneNumber *string `json:"phone_number" form:"phone_number" validate:"omitempty,max=15,numeric"`
+    Address     *string `json:"address" form:"address" validate:"omitempty,max=500"`
+    Locale      *string `json:"locale" form:"locale" validate:"omitempty,oneof=vi en"`
+}
+
+// ChangePasswordInput là dữ liệu đổi mật khẩu. Tài khoản chưa có mật khẩu (chỉ đăng nhập qua
+// mạng xã hội) gửi ConfirmationToken nhận qua email thay cho CurrentPassword.
+type ChangePasswordInput struct {
+    CurrentPassword   string `json:"current_password"`
+    ConfirmationToken string `json:"confirmation_token"`
+    NewPassword       string `json:"new_password" validate:"required,min=6"`
+}
+
+// ChangeEmailInput là dữ liệu yêu cầu đổi email, email mới chỉ có hiệu lực sau khi được xác minh
+type ChangeEmailInput struct {
+    NewEmail          string `json:"new_email" validate:"required,email,max=100"`
+    Password          string `json:"password"`
+    ConfirmationToken string `json:"confirmation_token"`
+}
+
+// DeleteAccountInput xác nhận xoá tài khoản bằng mật khẩu, hoặc bằng mã xác nhận gửi qua email
+// với tài khoản chưa có mật khẩu
+type DeleteAccountInput struct {
+    Password          string `json:"password"`
+    ConfirmationToken string `json:"confirmation_token"`
+}
+
+// ChangeRoleInput là dữ liệu admin đổi vai trò cơ bản của người dùng
+type ChangeRoleInput struct {
+    Role string `json:"role" validate:"required,oneof=user admin"`
+}
+
+// AdminUserDetail là thông tin chi tiết của người dùng trong trang quản trị
+type AdminUserDetail struct {
+    UserResponse
+    StaffRoles     []string `json:"staff_roles"`
+    LinkedAccounts []string `json:"linked_accounts"`
+    ActiveSessions int64    `json:"active_sessions"`
+    // OrderCount và LifetimeValue tính từ các đơn hàng đã lưu của người dùng
+    OrderCount    int64   `json:"order_count"`
+    LifetimeValue float64 `json:"lifetime_value"`
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserIdentity liên kết tài khoản với danh tính ở một provider đăng nhập bên ngoài (Google, Facebook...)
+type UserIdentity struct {
+	ID       uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
+	UserID   uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id"`
+	Provider string    `gorm:"size:30;not null;uniqueIndex:idx_user_identity_provider_subject" json:"provider"`
+	// Subject là id người dùng do provider cấp (claim sub)
+	Subject   string    `gorm:"size:255;not null;uniqueIndex:idx_user_identity_provider_subject" json:"subject"`
+	Email     string    `gorm:"size:100" json:"email"`
+	CreatedAt time.Time `gorm:"default:now()" json:"created_at"`
+}
+
+// OAuthState lưu state, nonce và PKCE code verifier của một lần đăng nhập qua provider
+// cho tới khi provider chuyển hướng về. Chỉ lưu hash của state.
+type OAuthState struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey"`
+	StateHash string    `gorm:"size:64;unique;not null"`
+	Provider  string    `gorm:"size:30;not null"`
+	// UserID khác nil khi người dùng đã đăng nhập yêu cầu liên kết provider vào tài khoản của mình
+	UserID       *uuid.UUID `gorm:"type:uuid"`
+	Nonce        string     `gorm:"size:100;not null"`
+	CodeVerifier string     `gorm:"size:100;not null"`
+	ExpiresAt    time.Time  `gorm:"not null"`
+	CreatedAt    time.Time  `gorm:"default:now()"`
+}
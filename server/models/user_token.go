@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Mục đích sử dụng của UserToken
+const (
+	TokenPurposeEmailVerification = "email_verification"
+	TokenPurposePasswordReset     = "password_reset"
+	// Token xác minh email mới khi người dùng đổi email (gửi tới User.PendingEmail)
+	TokenPurposeEmailChange = "email_change"
+	// Token tạm thời trả về ở bước 1 của Login khi người dùng đã bật 2FA
+	TokenPurposeTwoFactorChallenge = "two_factor_challenge"
+	// Mã gửi qua email để tài khoản chưa có mật khẩu xác nhận thao tác nhạy cảm
+	// (đặt mật khẩu, đổi email, xoá tài khoản)
+	TokenPurposeAccountConfirmation = "account_confirmation"
+)
+
+// UserToken là token dùng một lần gửi qua email (xác minh email, đặt lại mật khẩu).
+// Chỉ lưu hash SHA-256 của token, token gốc chỉ xuất hiện trong email.
+type UserToken struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;index"`
+	Purpose   string    `gorm:"size:30;not null"`
+	TokenHash string    `gorm:"size:64;unique;not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	// Attempts đếm số lần nhập sai mã 2FA với challenge token; đủ số lần tối đa thì token bị huỷ
+	Attempts  int `gorm:"not null;default:0"`
+	UsedAt    *time.Time
+	CreatedAt time.Time `gorm:"default:now()"`
+}
@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 // ProductVariant đại diện cho một phiên bản của sản phẩm
@@ -18,6 +19,8 @@ type ProductVariant struct {
 	Default    bool      `gorm:"default:false" json:"default"`
 	CreatedAt  time.Time `json:"created_at"`
 	UpdatedAt  time.Time `json:"updated_at"`
+	// DeletedAt được gán khi variant bị chuyển vào thùng rác (soft delete)
+	DeletedAt  gorm.DeletedAt `gorm:"index" json:"deleted_at"`
 }
 
 // CreateVariantInput chỉ chứa các trường thông tin cần thiết để tạo variant
@@ -41,3 +44,9 @@ type UpdateVariantInput struct {
 	Default  *bool    `json:"default"`
 	Active   *bool    `json:"active"`
 }
+
+// AdjustStockInput điều chỉnh tồn kho: đặt giá trị mới (Stock) hoặc cộng/trừ (Delta), chỉ một trong hai
+type AdjustStockInput struct {
+	Stock *int `json:"stock"`
+	Delta *int `json:"delta"`
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WishlistItem lưu một variant mà người dùng muốn mua sau
+type WishlistItem struct {
+	ID        uuid.UUID      `gorm:"type:uuid;primary_key" json:"id"`
+	UserID    uuid.UUID      `gorm:"type:uuid;not null;uniqueIndex:idx_wishlist_user_variant" json:"user_id"`
+	VariantID uuid.UUID      `gorm:"type:uuid;not null;uniqueIndex:idx_wishlist_user_variant" json:"variant_id"`
+	Note      string         `gorm:"size:255" json:"note"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	Variant   ProductVariant `gorm:"foreignKey:VariantID;references:ID" json:"variant,omitempty"`
+}
+
+// StockSubscription lưu đăng ký nhận thông báo khi variant có hàng trở lại.
+// NotifiedAt được gán khi đã phát thông báo, đăng ký đó sẽ không được thông báo lại.
+// Mỗi người dùng có tối đa một đăng ký đang chờ thông báo cho mỗi variant.
+type StockSubscription struct {
+	ID         uuid.UUID      `gorm:"type:uuid;primary_key" json:"id"`
+	UserID     uuid.UUID      `gorm:"type:uuid;not null;index;uniqueIndex:idx_stock_subscription_pending,where:notified_at IS NULL" json:"user_id"`
+	VariantID  uuid.UUID      `gorm:"type:uuid;not null;index;uniqueIndex:idx_stock_subscription_pending" json:"variant_id"`
+	NotifiedAt *time.Time     `json:"notified_at"`
+	CreatedAt  time.Time      `json:"created_at"`
+	Variant    ProductVariant `gorm:"foreignKey:VariantID;references:ID" json:"variant,omitempty"`
+}
+
+type AddWishlistItemInput struct {
+	VariantID string `json:"variant_id" binding:"required,uuid"`
+	Note      string `json:"note" binding:"max=255"`
+}
+
+type UpdateWishlistItemInput struct {
+	Note *string `json:"note" binding:"omitempty,max=255"`
+}
+
+type CreateStockSubscriptionInput struct {
+	VariantID string `json:"variant_id" binding:"required,uuid"`
+}
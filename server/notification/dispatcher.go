@@ -0,0 +1,178 @@
+package notification
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"ecommerce-project/config"
+	"ecommerce-project/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Dispatcher định kỳ lấy các email pending trong outbox để gửi.
+// Email gửi lỗi được thử lại với thời gian chờ tăng dần (exponential backoff),
+// sau MaxAttempts lần lỗi thì chuyển sang trạng thái failed.
+type Dispatcher struct {
+	db           *gorm.DB
+	sender       Sender
+	interval     time.Duration
+	batchSize    int
+	maxAttempts  int
+	baseBackoff  time.Duration
+	maxBackoff   time.Duration
+	claimTimeout time.Duration
+}
+
+// NewDispatcher khởi tạo Dispatcher, các tham số được đọc từ biến môi trường OUTBOX_*
+func NewDispatcher(db *gorm.DB, sender Sender) *Dispatcher {
+	return &Dispatcher{
+		db:           db,
+		sender:       sender,
+		interval:     config.GetEnvDuration("OUTBOX_POLL_INTERVAL", 10*time.Second),
+		batchSize:    config.GetEnvInt("OUTBOX_BATCH_SIZE", 20),
+		maxAttempts:  config.GetEnvInt("OUTBOX_MAX_ATTEMPTS", 8),
+		baseBackoff:  config.GetEnvDuration("OUTBOX_BASE_BACKOFF", 30*time.Second),
+		maxBackoff:   config.GetEnvDuration("OUTBOX_MAX_BACKOFF", 6*time.Hour),
+		claimTimeout: config.GetEnvDuration("OUTBOX_CLAIM_TIMEOUT", 5*time.Minute),
+	}
+}
+
+// Start chạy dispatcher trong goroutine riêng cho tới khi ctx bị huỷ.
+// Channel trả về được đóng khi dispatcher đã dừng hẳn (email đang gửi dở đã được ghi kết quả).
+func (d *Dispatcher) Start(ctx context.Context) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(d.interval)
+		defer ticker.Stop()
+
+		for {
+			if err := d.DispatchPending(ctx); err != nil {
+				log.Println("Outbox dispatch failed:", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return done
+}
+
+// DispatchPending gửi một lô email đã tới hạn. Các bản ghi được nhận gửi (chuyển sang sending) trong một
+// transaction ngắn dùng FOR UPDATE SKIP LOCKED nên nhiều instance có thể chạy dispatcher cùng lúc; việc gửi
+// diễn ra ngoài transaction và kết quả của từng email được ghi bằng một câu lệnh riêng.
+// Khi ctx bị huỷ, các email chưa gửi trong lô được trả lại trạng thái pending.
+func (d *Dispatcher) DispatchPending(ctx context.Context) error {
+	messages, err := d.claim()
+	if err != nil {
+		return err
+	}
+
+	for i := range messages {
+		if ctx.Err() != nil {
+			return d.release(messages[i:])
+		}
+
+		message := &messages[i]
+		err := d.sender.Send(message.Recipient, RenderedEmail{
+			Subject:  message.Subject,
+			HTMLBody: message.HTMLBody,
+			TextBody: message.TextBody,
+		})
+
+		now := time.Now()
+		message.Attempts++
+		message.UpdatedAt = now
+		if err == nil {
+			message.Status = models.OutboxStatusSent
+			message.SentAt = &now
+			message.LastError = ""
+		} else {
+			message.LastError = err.Error()
+			message.Status = models.OutboxStatusPending
+			if message.Attempts >= d.maxAttempts {
+				message.Status = models.OutboxStatusFailed
+			} else {
+				message.NextAttemptAt = now.Add(d.backoff(message.Attempts))
+			}
+		}
+
+		if err := d.db.Model(message).Where("status = ?", models.OutboxStatusSending).Updates(map[string]interface{}{
+			"status":          message.Status,
+			"attempts":        message.Attempts,
+			"next_attempt_at": message.NextAttemptAt,
+			"last_error":      message.LastError,
+			"sent_at":         message.SentAt,
+			"updated_at":      message.UpdatedAt,
+		}).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// claim nhận gửi một lô email đã tới hạn, kể cả email đang sending đã quá hạn nhận
+func (d *Dispatcher) claim() ([]models.OutboxMessage, error) {
+	var messages []models.OutboxMessage
+	err := d.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status IN ? AND next_attempt_at <= ?", []string{models.OutboxStatusPending, models.OutboxStatusSending}, now).
+			Order("next_attempt_at ASC").
+			Limit(d.batchSize).
+			Find(&messages).Error; err != nil {
+			return err
+		}
+		if len(messages) == 0 {
+			return nil
+		}
+
+		ids := make([]uuid.UUID, 0, len(messages))
+		for i := range messages {
+			ids = append(ids, messages[i].ID)
+			messages[i].Status = models.OutboxStatusSending
+		}
+		return tx.Model(&models.OutboxMessage{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+			"status":          models.OutboxStatusSending,
+			"next_attempt_at": now.Add(d.claimTimeout),
+			"updated_at":      now,
+		}).Error
+	})
+	return messages, err
+}
+
+// release trả các email đã nhận nhưng chưa gửi về trạng thái pending để được gửi ngay lần sau
+func (d *Dispatcher) release(messages []models.OutboxMessage) error {
+	ids := make([]uuid.UUID, 0, len(messages))
+	for _, message := range messages {
+		ids = append(ids, message.ID)
+	}
+	now := time.Now()
+	return d.db.Model(&models.OutboxMessage{}).
+		Where("id IN ? AND status = ?", ids, models.OutboxStatusSending).
+		Updates(map[string]interface{}{
+			"status":          models.OutboxStatusPending,
+			"next_attempt_at": now,
+			"updated_at":      now,
+		}).Error
+}
+
+// backoff tính thời gian chờ trước lần thử tiếp theo: base * 2^(attempts-1), tối đa maxBackoff
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	wait := d.baseBackoff
+	for i := 1; i < attempts; i++ {
+		wait *= 2
+		if wait >= d.maxBackoff {
+			return d.maxBackoff
+		}
+	}
+	return wait
+}
@@ -0,0 +1,61 @@
+package notification
+
+import (
+	"errors"
+	"time"
+
+	"ecommerce-project/events"
+	"ecommerce-project/models"
+
+	"gorm.io/gorm"
+)
+
+// RegisterEventHandlers đăng ký các handler tạo email cho sự kiện nghiệp vụ
+func RegisterEventHandlers() {
+	events.Subscribe(events.VariantBackInStock, handleBackInStock)
+}
+
+// handleBackInStock ghi email thông báo có hàng trở lại vào outbox và đánh dấu đăng ký đã được thông báo.
+// Handler chạy trong transaction của thao tác thay đổi tồn kho nên không được làm hỏng thao tác đó:
+// nếu người dùng đã xoá tài khoản hoặc variant/sản phẩm không còn (kể cả đang trong thùng rác),
+// đăng ký bị bỏ thay vì trả về lỗi.
+func handleBackInStock(tx *gorm.DB, event events.Event) error {
+	payload, ok := event.Payload.(events.BackInStockPayload)
+	if !ok {
+		return nil
+	}
+
+	var user models.User
+	err := tx.First(&user, "id = ? AND deleted_at IS NULL", payload.UserID).Error
+	var variant models.ProductVariant
+	if err == nil {
+		err = tx.First(&variant, "id = ?", payload.VariantID).Error
+	}
+	var product models.Product
+	if err == nil {
+		err = tx.First(&product, "id = ?", payload.ProductID).Error
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return tx.Where("id = ?", payload.SubscriptionID).Delete(&models.StockSubscription{}).Error
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := Enqueue(tx, TemplateBackInStock, user.Locale, user.Email, map[string]interface{}{
+		"Username":    user.Username,
+		"ProductID":   product.ID.String(),
+		"ProductName": product.Name,
+		"Color":       variant.Color,
+		"Capacity":    variant.Capacity,
+		"Price":       variant.Price,
+		"Stock":       variant.Stock,
+	}); err != nil {
+		return err
+	}
+
+	// Chỉ đánh dấu đã thông báo sau khi email đã nằm trong outbox
+	return tx.Model(&models.StockSubscription{}).
+		Where("id = ? AND notified_at IS NULL", payload.SubscriptionID).
+		Update("notified_at", time.Now()).Error
+}
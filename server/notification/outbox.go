@@ -0,0 +1,37 @@
+package notification
+
+import (
+	"time"
+
+	"ecommerce-project/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Enqueue render email và ghi vào outbox bằng transaction tx của nghiệp vụ.
+// Email chỉ được gửi khi transaction commit thành công.
+func Enqueue(tx *gorm.DB, template, locale, recipient string, data map[string]interface{}) error {
+	locale = NormalizeLocale(locale)
+
+	email, err := Render(template, locale, data)
+	if err != nil {
+		return err
+	}
+
+	message := models.OutboxMessage{
+		ID:            uuid.New(),
+		Template:      template,
+		Locale:        locale,
+		Recipient:     recipient,
+		Subject:       email.Subject,
+		HTMLBody:      email.HTMLBody,
+		TextBody:      email.TextBody,
+		Status:        models.OutboxStatusPending,
+		NextAttemptAt: time.Now(),
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
+	}
+
+	return tx.Create(&message).Error
+}
@@ -0,0 +1,112 @@
+package notification
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"time"
+
+	"ecommerce-project/config"
+)
+
+// Sender gửi một email đã render tới người nhận
+type Sender interface {
+	Send(recipient string, email RenderedEmail) error
+}
+
+// SMTPSender gửi email qua máy chủ SMTP. Khi không cấu hình username,
+// sender sẽ gửi không xác thực (phù hợp với máy chủ SMTP test chạy local như MailHog).
+type SMTPSender struct {
+	host     string
+	port     string
+	username string
+	password string
+	from     string
+}
+
+// NewSMTPSender khởi tạo SMTPSender từ các biến môi trường SMTP_*
+func NewSMTPSender() *SMTPSender {
+	return &SMTPSender{
+		host:     config.GetEnvDefault("SMTP_HOST", "localhost"),
+		port:     config.GetEnvDefault("SMTP_PORT", "1025"),
+		username: config.GetEnv("SMTP_USERNAME"),
+		password: config.GetEnv("SMTP_PASSWORD"),
+		from:     config.GetEnvDefault("SMTP_FROM", "PhoneStore <no-reply@phonestore.local>"),
+	}
+}
+
+func (s *SMTPSender) Send(recipient string, email RenderedEmail) error {
+	message, err := buildMessage(s.from, recipient, email)
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if s.username != "" {
+		auth = smtp.PlainAuth("", s.username, s.password, s.host)
+	}
+
+	// Envelope sender chỉ nhận địa chỉ email, không kèm tên hiển thị
+	from := s.from
+	if address, err := mail.ParseAddress(s.from); err == nil {
+		from = address.Address
+	}
+
+	addr := net.JoinHostPort(s.host, s.port)
+	if err := smtp.SendMail(addr, auth, from, []string{recipient}, message); err != nil {
+		return fmt.Errorf("failed to send email via %s: %w", addr, err)
+	}
+	return nil
+}
+
+// buildMessage tạo email MIME multipart/alternative gồm phần text và HTML
+func buildMessage(from, recipient string, email RenderedEmail) ([]byte, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	parts := []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain; charset=UTF-8", email.TextBody},
+		{"text/html; charset=UTF-8", email.HTMLBody},
+	}
+	for _, part := range parts {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Type", part.contentType)
+		header.Set("Content-Transfer-Encoding", "quoted-printable")
+
+		partWriter, err := writer.CreatePart(header)
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(partWriter)
+		if _, err := qp.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: %s\r\n", from)
+	fmt.Fprintf(&message, "To: %s\r\n", recipient)
+	fmt.Fprintf(&message, "Subject: %s\r\n", mime.QEncoding.Encode("UTF-8", email.Subject))
+	fmt.Fprintf(&message, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&message, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&message, "Content-Type: multipart/alternative; boundary=%s\r\n", writer.Boundary())
+	fmt.Fprintf(&message, "\r\n")
+	message.Write(body.Bytes())
+
+	return message.Bytes(), nil
+}
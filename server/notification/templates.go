@@ -0,0 +1,95 @@
+package notification
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+
+	"ecommerce-project/config"
+)
+
+// Mỗi template email gồm 2 file cho mỗi ngôn ngữ trong thư mục templates:
+//   - <tên>.<locale>.txt: định nghĩa block "subject" và "body" (bản text thuần)
+//   - <tên>.<locale>.html: nội dung HTML
+//
+//go:embed templates/*
+var templateFS embed.FS
+
+// Các template email hiện có
+const (
+	TemplateWelcome           = "welcome"
+	TemplateOrderConfirmation = "order_confirmation"
+	TemplateBackInStock       = "back_in_stock"
+	TemplateVerifyEmail       = "verify_email"
+	TemplatePasswordReset     = "password_reset"
+	TemplateEmailChange       = "email_change"
+	// Mã xác nhận thao tác nhạy cảm cho tài khoản chưa có mật khẩu
+	TemplateAccountConfirmation = "account_confirmation"
+)
+
+// Các ngôn ngữ được hỗ trợ, DefaultLocale được dùng khi ngôn ngữ yêu cầu không có template
+const (
+	LocaleVietnamese = "vi"
+	LocaleEnglish    = "en"
+	DefaultLocale    = LocaleVietnamese
+)
+
+// RenderedEmail là kết quả render một template
+type RenderedEmail struct {
+	Subject  string
+	HTMLBody string
+	TextBody string
+}
+
+// NormalizeLocale trả về locale được hỗ trợ gần nhất với giá trị truyền vào
+func NormalizeLocale(locale string) string {
+	switch strings.ToLower(strings.TrimSpace(locale)) {
+	case LocaleEnglish:
+		return LocaleEnglish
+	default:
+		return DefaultLocale
+	}
+}
+
+// Render render template name theo locale với dữ liệu data.
+// Các khoá AppName và BaseURL luôn có sẵn trong dữ liệu template.
+func Render(name, locale string, data map[string]interface{}) (RenderedEmail, error) {
+	locale = NormalizeLocale(locale)
+
+	values := map[string]interface{}{
+		"AppName": config.GetEnvDefault("APP_NAME", "PhoneStore"),
+		"BaseURL": config.GetEnvDefault("APP_BASE_URL", "http://localhost:3000"),
+	}
+	for key, value := range data {
+		values[key] = value
+	}
+
+	textTmpl, err := texttemplate.ParseFS(templateFS, fmt.Sprintf("templates/%s.%s.txt", name, locale))
+	if err != nil {
+		return RenderedEmail{}, fmt.Errorf("failed to parse text template %s: %w", name, err)
+	}
+	htmlTmpl, err := htmltemplate.ParseFS(templateFS, fmt.Sprintf("templates/%s.%s.html", name, locale))
+	if err != nil {
+		return RenderedEmail{}, fmt.Errorf("failed to parse html template %s: %w", name, err)
+	}
+
+	var subject, text, html bytes.Buffer
+	if err := textTmpl.ExecuteTemplate(&subject, "subject", values); err != nil {
+		return RenderedEmail{}, fmt.Errorf("failed to render subject of %s: %w", name, err)
+	}
+	if err := textTmpl.ExecuteTemplate(&text, "body", values); err != nil {
+		return RenderedEmail{}, fmt.Errorf("failed to render text body of %s: %w", name, err)
+	}
+	if err := htmlTmpl.Execute(&html, values); err != nil {
+		return RenderedEmail{}, fmt.Errorf("failed to render html body of %s: %w", name, err)
+	}
+
+	return RenderedEmail{
+		Subject:  strings.TrimSpace(subject.String()),
+		HTMLBody: html.String(),
+		TextBody: strings.TrimSpace(text.String()) + "\n",
+	}, nil
+}
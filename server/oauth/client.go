@@ -0,0 +1,203 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Identity là danh tính người dùng đọc được từ provider
+type Identity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Picture       string
+}
+
+// TokenResponse là phản hồi của token endpoint
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IDToken     string `json:"id_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// endpoints trả về authorization endpoint và token endpoint, ưu tiên giá trị cấu hình
+// rồi tới discovery document của provider OIDC.
+func (p *Provider) endpoints(ctx context.Context) (string, string, error) {
+	authURL, tokenURL := p.AuthURL, p.TokenURL
+	if (authURL == "" || tokenURL == "") && p.IsOIDC() {
+		meta, err := p.metadata(ctx, false)
+		if err != nil {
+			return "", "", err
+		}
+		if authURL == "" {
+			authURL = meta.discovery.AuthorizationEndpoint
+		}
+		if tokenURL == "" {
+			tokenURL = meta.discovery.TokenEndpoint
+		}
+	}
+	if authURL == "" || tokenURL == "" {
+		return "", "", fmt.Errorf("provider %s has no authorization or token endpoint", p.Name)
+	}
+	return authURL, tokenURL, nil
+}
+
+// AuthorizationURL tạo URL chuyển hướng người dùng tới trang đăng nhập của provider
+// (authorization code flow với PKCE S256).
+func (p *Provider) AuthorizationURL(ctx context.Context, state, nonce, codeVerifier string) (string, error) {
+	authURL, _, err := p.endpoints(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", p.ClientID)
+	params.Set("redirect_uri", p.RedirectURL)
+	params.Set("scope", strings.Join(p.Scopes, " "))
+	params.Set("state", state)
+	params.Set("code_challenge", CodeChallenge(codeVerifier))
+	params.Set("code_challenge_method", "S256")
+	if p.IsOIDC() {
+		params.Set("nonce", nonce)
+	}
+
+	separator := "?"
+	if strings.Contains(authURL, "?") {
+		separator = "&"
+	}
+	return authURL + separator + params.Encode(), nil
+}
+
+// Exchange đổi authorization code lấy token, gửi kèm PKCE code verifier
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier string) (*TokenResponse, error) {
+	_, tokenURL, err := p.endpoints(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.RedirectURL)
+	form.Set("client_id", p.ClientID)
+	form.Set("client_secret", p.ClientSecret)
+	form.Set("code_verifier", codeVerifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token endpoint returned status %d", resp.StatusCode)
+	}
+
+	var token TokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return nil, err
+	}
+	if token.AccessToken == "" && token.IDToken == "" {
+		return nil, errors.New("token endpoint returned no token")
+	}
+	return &token, nil
+}
+
+// Identity đọc danh tính người dùng: từ ID token (đã xác thực chữ ký và nonce) với provider OIDC,
+// hoặc từ UserInfoURL với provider OAuth2 thuần.
+func (p *Provider) Identity(ctx context.Context, token *TokenResponse, nonce string) (*Identity, error) {
+	if p.IsOIDC() {
+		if token.IDToken == "" {
+			return nil, errors.New("token endpoint returned no id token")
+		}
+		claims, err := p.verifyIDToken(ctx, token.IDToken, nonce)
+		if err != nil {
+			return nil, err
+		}
+
+		identity := &Identity{Provider: p.Name}
+		identity.Subject, _ = claims["sub"].(string)
+		identity.Email, _ = claims["email"].(string)
+		identity.Name, _ = claims["name"].(string)
+		identity.Picture, _ = claims["picture"].(string)
+		// Một số provider trả email_verified dạng chuỗi "true"
+		switch verified := claims["email_verified"].(type) {
+		case bool:
+			identity.EmailVerified = verified
+		case string:
+			identity.EmailVerified = verified == "true"
+		}
+		if identity.Subject == "" {
+			return nil, errors.New("id token has no subject")
+		}
+		return identity, nil
+	}
+
+	if p.UserInfoURL == "" {
+		return nil, fmt.Errorf("provider %s has no userinfo endpoint", p.Name)
+	}
+
+	var info struct {
+		ID            string          `json:"id"`
+		Sub           string          `json:"sub"`
+		Email         string          `json:"email"`
+		EmailVerified *bool           `json:"email_verified"`
+		Name          string          `json:"name"`
+		Picture       json.RawMessage `json:"picture"`
+	}
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+token.AccessToken)
+	if err := getJSON(ctx, p.UserInfoURL, header, &info); err != nil {
+		return nil, err
+	}
+
+	identity := &Identity{
+		Provider:      p.Name,
+		Subject:       info.ID,
+		Email:         info.Email,
+		EmailVerified: p.TrustEmail && info.Email != "",
+		Name:          info.Name,
+	}
+	if identity.Subject == "" {
+		identity.Subject = info.Sub
+	}
+	if info.EmailVerified != nil {
+		identity.EmailVerified = *info.EmailVerified
+	}
+
+	// picture là chuỗi URL, hoặc {"data": {"url": ...}} với Facebook Graph API
+	var picture string
+	if err := json.Unmarshal(info.Picture, &picture); err == nil {
+		identity.Picture = picture
+	} else {
+		var graphPicture struct {
+			Data struct {
+				URL string `json:"url"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(info.Picture, &graphPicture); err == nil {
+			identity.Picture = graphPicture.Data.URL
+		}
+	}
+
+	if identity.Subject == "" {
+		return nil, errors.New("userinfo response has no subject")
+	}
+	return identity, nil
+}
@@ -0,0 +1,265 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// fakeOIDC là OIDC provider giả lập: discovery, JWKS, token endpoint kiểm tra PKCE và userinfo
+type fakeOIDC struct {
+	server   *httptest.Server
+	key      *rsa.PrivateKey
+	clientID string
+
+	// code được cấp cho lần đăng nhập, kèm code challenge và các claim của ID token
+	code          string
+	codeChallenge string
+	claims        jwt.MapClaims
+	userInfo      map[string]interface{}
+}
+
+func newFakeOIDC(t *testing.T) *fakeOIDC {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeOIDC{key: key, clientID: "test-client"}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 f.server.URL,
+			"authorization_endpoint": f.server.URL + "/authorize",
+			"token_endpoint":         f.server.URL + "/token",
+			"userinfo_endpoint":      f.server.URL + "/userinfo",
+			"jwks_uri":               f.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "test-key",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if r.PostForm.Get("code") != f.code || r.PostForm.Get("client_id") != f.clientID ||
+			CodeChallenge(r.PostForm.Get("code_verifier")) != f.codeChallenge {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, f.claims)
+		token.Header["kid"] = "test-key"
+		idToken, err := token.SignedString(f.key)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "access-token",
+			"token_type":   "Bearer",
+			"id_token":     idToken,
+			"expires_in":   3600,
+		})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer access-token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(f.userInfo)
+	})
+
+	f.server = httptest.NewServer(mux)
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+func (f *fakeOIDC) provider() *Provider {
+	return &Provider{
+		Name:        "fake",
+		ClientID:    f.clientID,
+		RedirectURL: "https://shop.example/oauth/callback",
+		Scopes:      []string{"openid", "email"},
+		Issuer:      f.server.URL,
+	}
+}
+
+// authorize mô phỏng bước người dùng đăng nhập ở provider: đọc URL đăng nhập và cấp code
+func (f *fakeOIDC) authorize(t *testing.T, authorizationURL, subject string) {
+	t.Helper()
+	parsed, err := url.Parse(authorizationURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := parsed.Query()
+	if query.Get("code_challenge_method") != "S256" {
+		t.Fatalf("got code_challenge_method %q, want S256", query.Get("code_challenge_method"))
+	}
+	f.code = "code-" + subject
+	f.codeChallenge = query.Get("code_challenge")
+	f.claims = jwt.MapClaims{
+		"iss":            f.server.URL,
+		"aud":            f.clientID,
+		"sub":            subject,
+		"email":          subject + "@example.com",
+		"email_verified": true,
+		"nonce":          query.Get("nonce"),
+		"iat":            time.Now().Unix(),
+		"exp":            time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func TestOIDCLogin(t *testing.T) {
+	f := newFakeOIDC(t)
+	p := f.provider()
+	ctx := context.Background()
+
+	authorizationURL, err := p.AuthorizationURL(ctx, "state-1", "nonce-1", "verifier-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(authorizationURL, f.server.URL+"/authorize?") {
+		t.Fatalf("got authorization URL %s", authorizationURL)
+	}
+	f.authorize(t, authorizationURL, "alice")
+
+	token, err := p.Exchange(ctx, f.code, "verifier-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	identity, err := p.Identity(ctx, token, "nonce-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.Subject != "alice" || identity.Email != "alice@example.com" || !identity.EmailVerified {
+		t.Fatalf("got identity %+v", identity)
+	}
+}
+
+func TestOIDCRejectsWrongCodeVerifier(t *testing.T) {
+	f := newFakeOIDC(t)
+	p := f.provider()
+	ctx := context.Background()
+
+	authorizationURL, err := p.AuthorizationURL(ctx, "state-1", "nonce-1", "verifier-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.authorize(t, authorizationURL, "alice")
+
+	if _, err := p.Exchange(ctx, f.code, "other-verifier"); err == nil {
+		t.Fatal("exchange with wrong code verifier succeeded")
+	}
+}
+
+func TestOIDCRejectsInvalidIDToken(t *testing.T) {
+	tests := []struct {
+		name   string
+		nonce  string
+		mutate func(claims jwt.MapClaims)
+	}{
+		{name: "nonce mismatch", nonce: "other-nonce"},
+		{name: "wrong audience", nonce: "nonce-1", mutate: func(claims jwt.MapClaims) { claims["aud"] = "other-client" }},
+		{name: "wrong issuer", nonce: "nonce-1", mutate: func(claims jwt.MapClaims) { claims["iss"] = "https://evil.example" }},
+		{name: "expired", nonce: "nonce-1", mutate: func(claims jwt.MapClaims) { claims["exp"] = time.Now().Add(-time.Hour).Unix() }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeOIDC(t)
+			p := f.provider()
+			ctx := context.Background()
+
+			authorizationURL, err := p.AuthorizationURL(ctx, "state-1", "nonce-1", "verifier-1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.authorize(t, authorizationURL, "alice")
+			if tt.mutate != nil {
+				tt.mutate(f.claims)
+			}
+
+			token, err := p.Exchange(ctx, f.code, "verifier-1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if identity, err := p.Identity(ctx, token, tt.nonce); err == nil {
+				t.Fatalf("got identity %+v, want error", identity)
+			}
+		})
+	}
+}
+
+func TestOIDCRejectsForeignSigningKey(t *testing.T) {
+	f := newFakeOIDC(t)
+	p := f.provider()
+	ctx := context.Background()
+
+	authorizationURL, err := p.AuthorizationURL(ctx, "state-1", "nonce-1", "verifier-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.authorize(t, authorizationURL, "alice")
+	// Token được ký bằng khoá không có trong JWKS của provider
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.key = other
+
+	token, err := p.Exchange(ctx, f.code, "verifier-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Identity(ctx, token, "nonce-1"); err == nil {
+		t.Fatal("id token signed with a foreign key was accepted")
+	}
+}
+
+func TestUserInfoEmailNotTrusted(t *testing.T) {
+	f := newFakeOIDC(t)
+	f.userInfo = map[string]interface{}{
+		"id":      "12345",
+		"email":   "bob@example.com",
+		"name":    "Bob",
+		"picture": map[string]interface{}{"data": map[string]string{"url": "https://cdn.example/bob.jpg"}},
+	}
+	// Provider OAuth2 thuần với cấu hình mặc định của Facebook
+	p := &Provider{
+		Name:        "facebook",
+		ClientID:    f.clientID,
+		UserInfoURL: f.server.URL + "/userinfo",
+		TrustEmail:  defaultProviders["facebook"].TrustEmail,
+	}
+
+	identity, err := p.Identity(context.Background(), &TokenResponse{AccessToken: "access-token"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.Subject != "12345" || identity.Picture != "https://cdn.example/bob.jpg" {
+		t.Fatalf("got identity %+v", identity)
+	}
+	if identity.EmailVerified {
+		t.Fatal("facebook email was treated as verified")
+	}
+}
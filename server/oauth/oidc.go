@@ -0,0 +1,204 @@
+package oauth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// discoveryDocument là các trường cần dùng trong /.well-known/openid-configuration
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserInfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// jsonWebKey là khoá công khai trong JWKS của provider (RSA hoặc EC)
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// Discovery document và JWKS được cache để không phải tải lại ở mỗi lần đăng nhập
+const metadataCacheTTL = time.Hour
+
+type providerMetadata struct {
+	discovery discoveryDocument
+	keys      map[string]interface{}
+	fetchedAt time.Time
+}
+
+var metadataCache = struct {
+	sync.Mutex
+	entries map[string]*providerMetadata
+}{entries: map[string]*providerMetadata{}}
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// getJSON tải url và giải mã JSON vào out
+func getJSON(ctx context.Context, url string, header http.Header, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: unexpected status %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// metadata trả về discovery document và JWKS của provider OIDC. force bỏ qua cache
+// (dùng khi ID token có kid chưa biết do provider vừa xoay vòng khoá).
+func (p *Provider) metadata(ctx context.Context, force bool) (*providerMetadata, error) {
+	metadataCache.Lock()
+	defer metadataCache.Unlock()
+
+	cached, ok := metadataCache.entries[p.Issuer]
+	if ok && !force && time.Since(cached.fetchedAt) < metadataCacheTTL {
+		return cached, nil
+	}
+	// Giới hạn tần suất tải lại khi gặp kid lạ
+	if ok && force && time.Since(cached.fetchedAt) < 10*time.Second {
+		return cached, nil
+	}
+
+	var discovery discoveryDocument
+	wellKnown := strings.TrimSuffix(p.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := getJSON(ctx, wellKnown, nil, &discovery); err != nil {
+		return nil, err
+	}
+	if discovery.Issuer != p.Issuer {
+		return nil, fmt.Errorf("issuer mismatch: expected %s, got %s", p.Issuer, discovery.Issuer)
+	}
+
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := getJSON(ctx, discovery.JWKSURI, nil, &jwks); err != nil {
+		return nil, err
+	}
+
+	keys := map[string]interface{}{}
+	for _, key := range jwks.Keys {
+		publicKey, err := key.publicKey()
+		if err != nil {
+			// Bỏ qua các loại khoá không hỗ trợ
+			continue
+		}
+		keys[key.Kid] = publicKey
+	}
+
+	entry := &providerMetadata{discovery: discovery, keys: keys, fetchedAt: time.Now()}
+	metadataCache.entries[p.Issuer] = entry
+	return entry, nil
+}
+
+// publicKey giải mã JWK thành *rsa.PublicKey hoặc *ecdsa.PublicKey
+func (k jsonWebKey) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %s", k.Kty)
+}
+
+// verifyIDToken kiểm tra chữ ký, issuer, audience, thời hạn và nonce của ID token
+func (p *Provider) verifyIDToken(ctx context.Context, rawIDToken, nonce string) (jwt.MapClaims, error) {
+	keyFunc := func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+
+		meta, err := p.metadata(ctx, false)
+		if err != nil {
+			return nil, err
+		}
+		if key, ok := meta.keys[kid]; ok {
+			return key, nil
+		}
+
+		meta, err = p.metadata(ctx, true)
+		if err != nil {
+			return nil, err
+		}
+		if key, ok := meta.keys[kid]; ok {
+			return key, nil
+		}
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+
+	token, err := jwt.Parse(rawIDToken, keyFunc,
+		jwt.WithValidMethods([]string{"RS256", "ES256", "ES384"}),
+		jwt.WithIssuer(p.Issuer),
+		jwt.WithAudience(p.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, errors.New("invalid id token")
+	}
+	if tokenNonce, _ := claims["nonce"].(string); tokenNonce != nonce {
+		return nil, errors.New("id token nonce mismatch")
+	}
+	return claims, nil
+}
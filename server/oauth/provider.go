@@ -0,0 +1,121 @@
+package oauth
+
+import (
+	"errors"
+	"strings"
+	"sync"
+
+	"ecommerce-project/config"
+)
+
+// ErrUnknownProvider được trả về khi provider không được cấu hình
+var ErrUnknownProvider = errors.New("unknown oauth provider")
+
+// Provider là cấu hình của một nhà cung cấp đăng nhập OAuth2/OIDC.
+// Với provider OIDC (Issuer khác rỗng), các endpoint được lấy từ discovery document
+// và danh tính được đọc từ ID token; với provider OAuth2 thuần (Facebook),
+// danh tính được lấy từ UserInfoURL bằng access token.
+type Provider struct {
+	Name         string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+
+	// Issuer của provider OIDC, dùng để tải /.well-known/openid-configuration
+	Issuer string
+
+	AuthURL     string
+	TokenURL    string
+	UserInfoURL string
+
+	// TrustEmail coi email trả về từ UserInfoURL là đã xác minh khi provider không trả về
+	// email_verified. Facebook không đảm bảo email đã được xác nhận nên không bật.
+	TrustEmail bool
+}
+
+// IsOIDC cho biết provider xác thực bằng ID token
+func (p *Provider) IsOIDC() bool {
+	return p.Issuer != ""
+}
+
+// Các giá trị mặc định của provider được hỗ trợ sẵn, có thể ghi đè bằng biến môi trường
+var defaultProviders = map[string]Provider{
+	"google": {
+		Issuer: "https://accounts.google.com",
+		Scopes: []string{"openid", "email", "profile"},
+	},
+	"facebook": {
+		AuthURL:     "https://www.facebook.com/v19.0/dialog/oauth",
+		TokenURL:    "https://graph.facebook.com/v19.0/oauth/access_token",
+		UserInfoURL: "https://graph.facebook.com/me?fields=id,name,email,picture",
+		Scopes:      []string{"email", "public_profile"},
+		TrustEmail:  false,
+	},
+}
+
+var (
+	providersOnce sync.Once
+	providers     map[string]*Provider
+)
+
+// loadProviders đọc danh sách provider từ OAUTH_PROVIDERS (ví dụ "google,facebook").
+// Mỗi provider NAME được cấu hình bằng OAUTH_<NAME>_CLIENT_ID, _CLIENT_SECRET, _REDIRECT_URL
+// và có thể ghi đè _ISSUER, _AUTH_URL, _TOKEN_URL, _USERINFO_URL, _SCOPES (ví dụ để trỏ tới
+// một OIDC provider giả lập chạy local khi phát triển).
+func loadProviders() map[string]*Provider {
+	result := map[string]*Provider{}
+	for _, name := range strings.Split(config.GetEnv("OAUTH_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		provider := defaultProviders[name]
+		provider.Name = name
+
+		prefix := "OAUTH_" + strings.ToUpper(name) + "_"
+		provider.ClientID = config.GetEnv(prefix + "CLIENT_ID")
+		provider.ClientSecret = config.GetEnv(prefix + "CLIENT_SECRET")
+		provider.RedirectURL = config.GetEnv(prefix + "REDIRECT_URL")
+		provider.Issuer = config.GetEnvDefault(prefix+"ISSUER", provider.Issuer)
+		provider.AuthURL = config.GetEnvDefault(prefix+"AUTH_URL", provider.AuthURL)
+		provider.TokenURL = config.GetEnvDefault(prefix+"TOKEN_URL", provider.TokenURL)
+		provider.UserInfoURL = config.GetEnvDefault(prefix+"USERINFO_URL", provider.UserInfoURL)
+		if scopes := config.GetEnv(prefix + "SCOPES"); scopes != "" {
+			provider.Scopes = strings.Fields(strings.ReplaceAll(scopes, ",", " "))
+		}
+
+		if provider.ClientID == "" || provider.RedirectURL == "" {
+			continue
+		}
+		result[name] = &provider
+	}
+	return result
+}
+
+// GetProvider trả về cấu hình provider theo tên
+func GetProvider(name string) (*Provider, error) {
+	providersOnce.Do(func() {
+		providers = loadProviders()
+	})
+
+	provider, ok := providers[strings.ToLower(name)]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	return provider, nil
+}
+
+// ProviderNames trả về tên các provider đã được cấu hình
+func ProviderNames() []string {
+	providersOnce.Do(func() {
+		providers = loadProviders()
+	})
+
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	return names
+}
@@ -0,0 +1,56 @@
+package payment
+
+import (
+	"errors"
+	"fmt"
+
+	"ecommerce-project/config"
+
+	"github.com/google/uuid"
+)
+
+// Tên các nhà cung cấp thanh toán được hỗ trợ
+const (
+	ProviderManual = "manual"
+)
+
+var ErrUnknownProvider = errors.New("unknown payment provider")
+
+// Provider là lớp trừu tượng của cổng thanh toán: thu tiền khi đặt hàng và hoàn tiền khi trả hàng.
+// idempotencyKey giúp cổng thanh toán bỏ qua yêu cầu bị gửi lại.
+type Provider interface {
+	Name() string
+	Charge(orderID uuid.UUID, amount float64) (reference string, err error)
+	Refund(paymentReference string, idempotencyKey uuid.UUID, amount float64) (reference string, err error)
+}
+
+// ManualProvider dùng khi chưa tích hợp cổng thanh toán: tiền được thu khi giao hàng và hoàn lại
+// thủ công bởi nhân viên, provider chỉ cấp mã tham chiếu để đối soát.
+type ManualProvider struct{}
+
+func (ManualProvider) Name() string {
+	return ProviderManual
+}
+
+func (ManualProvider) Charge(orderID uuid.UUID, amount float64) (string, error) {
+	return "manual-" + orderID.String(), nil
+}
+
+func (ManualProvider) Refund(paymentReference string, idempotencyKey uuid.UUID, amount float64) (string, error) {
+	return "manual-refund-" + idempotencyKey.String(), nil
+}
+
+// NewProvider tạo provider theo tên đã lưu trong đơn hàng
+func NewProvider(name string) (Provider, error) {
+	switch name {
+	case ProviderManual:
+		return ManualProvider{}, nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownProvider, name)
+	}
+}
+
+// DefaultProvider là provider dùng cho đơn hàng mới, chọn bằng PAYMENT_PROVIDER (mặc định manual)
+func DefaultProvider() (Provider, error) {
+	return NewProvider(config.GetEnvDefault("PAYMENT_PROVIDER", ProviderManual))
+}
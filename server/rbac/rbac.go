@@ -0,0 +1,139 @@
+package rbac
+
+import (
+	"slices"
+	"sort"
+	"time"
+
+	"ecommerce-project/models"
+
+	"gorm.io/gorm"
+)
+
+// Các quyền có tên được kiểm tra bởi middleware.RequirePermission
+const (
+	ProductWrite    = "product:write"
+	CategoryWrite   = "category:write"
+	InventoryAdjust = "inventory:adjust"
+	MediaWrite      = "media:write"
+	ReviewModerate  = "review:moderate"
+	OrderManage     = "order:manage"
+	OrderRefund     = "order:refund"
+	UserRead        = "user:read"
+	UserManage      = "user:manage"
+	UserImpersonate = "user:impersonate"
+	RoleManage      = "role:manage"
+	AuditRead       = "audit:read"
+
+	// All là quyền đại diện cho mọi quyền, chỉ được cấp cho tài khoản có User.Role = "admin"
+	All = "*"
+)
+
+// Permissions là danh mục quyền kèm mô tả
+var Permissions = map[string]string{
+	ProductWrite:    "Create, update and delete products and variants",
+	CategoryWrite:   "Create, update and delete categories",
+	InventoryAdjust: "Adjust variant stock levels",
+	MediaWrite:      "Upload and delete media",
+	ReviewModerate:  "Moderate product reviews",
+	OrderManage:     "View orders, update shipping status and handle returns",
+	OrderRefund:     "Refund orders",
+	UserRead:        "View customer accounts",
+	UserManage:      "Unlock, disable and enable accounts and force logout",
+	UserImpersonate: "Sign in as a customer for support",
+	RoleManage:      "Manage staff roles and assignments",
+	AuditRead:       "View security and audit logs",
+}
+
+// Các vai trò nhân viên có sẵn
+const (
+	RoleCatalogManager = "catalog_manager"
+	RoleWarehouse      = "warehouse"
+	RoleSupport        = "support"
+)
+
+// Catalogue là danh mục vai trò có sẵn, được đồng bộ vào database khi khởi động
+var Catalogue = []models.Role{
+	{
+		Name:        RoleCatalogManager,
+		Description: "Manages products, variants, categories and media",
+		Permissions: []string{ProductWrite, CategoryWrite, MediaWrite, InventoryAdjust},
+	},
+	{
+		Name:        RoleWarehouse,
+		Description: "Adjusts stock levels, ships orders and receives returns",
+		Permissions: []string{InventoryAdjust, OrderManage},
+	},
+	{
+		Name:        RoleSupport,
+		Description: "Helps customers with accounts, reviews and refunds",
+		Permissions: []string{ReviewModerate, OrderManage, OrderRefund, UserRead, UserManage},
+	},
+}
+
+// IsValidPermission cho biết permission có trong danh mục
+func IsValidPermission(permission string) bool {
+	_, ok := Permissions[permission]
+	return ok
+}
+
+// SeedRoles tạo hoặc cập nhật các vai trò có sẵn theo Catalogue
+func SeedRoles(db *gorm.DB) error {
+	for _, role := range Catalogue {
+		role.System = true
+		role.UpdatedAt = time.Now()
+
+		var existing models.Role
+		err := db.First(&existing, "name = ?", role.Name).Error
+		if err == gorm.ErrRecordNotFound {
+			role.CreatedAt = time.Now()
+			if err := db.Create(&role).Error; err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := db.Model(&existing).Updates(map[string]interface{}{
+			"description": role.Description,
+			"permissions": role.Permissions,
+			"system":      true,
+			"updated_at":  role.UpdatedAt,
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UserPermissions trả về danh sách quyền của người dùng: admin có mọi quyền,
+// người dùng khác có hợp các quyền của vai trò nhân viên được gán.
+func UserPermissions(db *gorm.DB, user models.User) ([]string, error) {
+	if user.Role == "admin" {
+		return []string{All}, nil
+	}
+
+	var roles []models.Role
+	if err := db.Where("name IN (?)",
+		db.Model(&models.UserRole{}).Select("role_name").Where("user_id = ?", user.ID),
+	).Find(&roles).Error; err != nil {
+		return nil, err
+	}
+
+	var permissions []string
+	for _, role := range roles {
+		for _, permission := range role.Permissions {
+			if !slices.Contains(permissions, permission) {
+				permissions = append(permissions, permission)
+			}
+		}
+	}
+	sort.Strings(permissions)
+	return permissions, nil
+}
+
+// HasPermission cho biết tập quyền granted có chứa permission
+func HasPermission(granted []string, permission string) bool {
+	return slices.Contains(granted, All) || slices.Contains(granted, permission)
+}
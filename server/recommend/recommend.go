@@ -0,0 +1,233 @@
+package recommend
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"ecommerce-project/config"
+	"ecommerce-project/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Trọng số của từng loại tín hiệu: hai sản phẩm được mua cùng nhau đáng tin hơn được xem cùng nhau.
+// Điểm của gợi ý tương tự luôn nhỏ hơn 1 nên chỉ đứng sau các gợi ý có tín hiệu.
+const (
+	purchaseWeight = 3.0
+	viewWeight     = 1.0
+)
+
+// Settings là cấu hình tính gợi ý
+type Settings struct {
+	// Window là khoảng thời gian tương tác được dùng, tương tác cũ hơn bị xoá
+	Window time.Duration
+	// Limit là số gợi ý tối đa được lưu cho mỗi sản phẩm
+	Limit int
+	// PriceBand là độ lệch giá tương đối tối đa của sản phẩm tương tự (0.3 = ±30%)
+	PriceBand float64
+	// ViewWindow là khoảng thời gian lượt xem được dùng (ngắn hơn Window vì lượt xem nhiều hơn lượt mua)
+	ViewWindow time.Duration
+	// PairWindow là khoảng cách thời gian tối đa giữa hai lượt xem để được tính là "xem cùng"
+	PairWindow time.Duration
+}
+
+// LoadSettings đọc cấu hình từ RECOMMENDATION_WINDOW, RECOMMENDATION_LIMIT, RECOMMENDATION_PRICE_BAND (phần trăm),
+// RECOMMENDATION_VIEW_WINDOW và RECOMMENDATION_PAIR_WINDOW
+func LoadSettings() Settings {
+	return Settings{
+		Window:     config.GetEnvDuration("RECOMMENDATION_WINDOW", 90*24*time.Hour),
+		Limit:      config.GetEnvInt("RECOMMENDATION_LIMIT", 20),
+		PriceBand:  float64(config.GetEnvInt("RECOMMENDATION_PRICE_BAND", 30)) / 100,
+		ViewWindow: config.GetEnvDuration("RECOMMENDATION_VIEW_WINDOW", 30*24*time.Hour),
+		PairWindow: config.GetEnvDuration("RECOMMENDATION_PAIR_WINDOW", 24*time.Hour),
+	}
+}
+
+// Weight trả về trọng số của một loại tương tác
+func Weight(kind string) float64 {
+	if kind == models.InteractionPurchase {
+		return purchaseWeight
+	}
+	return viewWeight
+}
+
+// candidate là sản phẩm đang hiển thị cùng giá thấp nhất của các variant đang bán
+type candidate struct {
+	ID            uuid.UUID
+	CategoryID    uuid.UUID
+	Price         *float64
+	RatingAverage float64
+	RatingCount   int
+}
+
+// pair là số người đã cùng tương tác với hai sản phẩm
+type pair struct {
+	ProductID        uuid.UUID
+	RelatedProductID uuid.UUID
+	Subjects         int64
+}
+
+type scored struct {
+	id       uuid.UUID
+	score    float64
+	purchase float64
+	view     float64
+}
+
+// Refresh tính lại toàn bộ bảng gợi ý: các sản phẩm được mua cùng trong Window và xem cùng
+// (cách nhau không quá PairWindow) trong ViewWindow, bổ sung bằng sản phẩm cùng danh mục và
+// cùng tầm giá khi chưa đủ Limit gợi ý. Phép tự nối chỉ quét tương tác trong cửa sổ thời gian
+// qua index (subject_key, kind, created_at).
+func Refresh(db *gorm.DB, settings Settings, now time.Time) error {
+	since := now.Add(-settings.Window)
+
+	// Tương tác quá cũ không còn được dùng để tính gợi ý
+	if err := db.Where("created_at < ?", since).Delete(&models.ProductInteraction{}).Error; err != nil {
+		return err
+	}
+
+	var candidates []candidate
+	if err := db.Model(&models.Product{}).Scopes(models.VisibleProducts).
+		Select("products.id, products.category_id, products.rating_average, products.rating_count, " +
+			"(SELECT MIN(v.price) FROM product_variants v WHERE v.product_id = products.id AND v.active AND v.deleted_at IS NULL) AS price").
+		Scan(&candidates).Error; err != nil {
+		return err
+	}
+	visible := map[uuid.UUID]candidate{}
+	for _, item := range candidates {
+		visible[item.ID] = item
+	}
+
+	// Lượt mua ít nên mọi cặp trong Window đều được tính; lượt xem chỉ ghép trong PairWindow
+	windows := []struct {
+		kind  string
+		since time.Time
+		pair  time.Duration
+	}{
+		{models.InteractionPurchase, since, settings.Window},
+		{models.InteractionView, now.Add(-settings.ViewWindow), settings.PairWindow},
+	}
+
+	scores := map[uuid.UUID]map[uuid.UUID]*scored{}
+	for _, window := range windows {
+		kind := window.kind
+		var pairs []pair
+		if err := db.Raw(`SELECT a.product_id, b.product_id AS related_product_id, COUNT(DISTINCT a.subject_key) AS subjects
+			FROM product_interactions a
+			JOIN product_interactions b ON b.subject_key = a.subject_key AND b.kind = a.kind AND b.product_id <> a.product_id
+				AND b.created_at BETWEEN a.created_at - @pair * INTERVAL '1 second' AND a.created_at + @pair * INTERVAL '1 second'
+			WHERE a.kind = @kind AND a.created_at >= @since AND b.created_at >= @since
+			GROUP BY a.product_id, b.product_id`, map[string]interface{}{
+			"kind":  kind,
+			"since": window.since,
+			"pair":  window.pair.Seconds(),
+		}).Scan(&pairs).Error; err != nil {
+			return err
+		}
+
+		for _, p := range pairs {
+			if _, ok := visible[p.ProductID]; !ok {
+				continue
+			}
+			if _, ok := visible[p.RelatedProductID]; !ok {
+				continue
+			}
+			if scores[p.ProductID] == nil {
+				scores[p.ProductID] = map[uuid.UUID]*scored{}
+			}
+			entry := scores[p.ProductID][p.RelatedProductID]
+			if entry == nil {
+				entry = &scored{id: p.RelatedProductID}
+				scores[p.ProductID][p.RelatedProductID] = entry
+			}
+			value := Weight(kind) * float64(p.Subjects)
+			entry.score += value
+			if kind == models.InteractionPurchase {
+				entry.purchase += value
+			} else {
+				entry.view += value
+			}
+		}
+	}
+
+	var rows []models.ProductRecommendation
+	for _, product := range candidates {
+		var ranked []*scored
+		for _, entry := range scores[product.ID] {
+			ranked = append(ranked, entry)
+		}
+		sort.Slice(ranked, func(i, j int) bool { return ranked[i].score > ranked[j].score })
+		if len(ranked) > settings.Limit {
+			ranked = ranked[:settings.Limit]
+		}
+
+		for _, entry := range ranked {
+			reason := models.RecommendationCoView
+			if entry.purchase >= entry.view {
+				reason = models.RecommendationCoPurchase
+			}
+			rows = append(rows, models.ProductRecommendation{
+				ProductID:        product.ID,
+				RelatedProductID: entry.id,
+				Score:            entry.score,
+				Reason:           reason,
+				UpdatedAt:        now,
+			})
+		}
+
+		if missing := settings.Limit - len(ranked); missing > 0 {
+			for _, similar := range similarTo(product, candidates, scores[product.ID], settings.PriceBand, missing) {
+				rows = append(rows, models.ProductRecommendation{
+					ProductID:        product.ID,
+					RelatedProductID: similar.id,
+					Score:            similar.score,
+					Reason:           models.RecommendationSimilar,
+					UpdatedAt:        now,
+				})
+			}
+		}
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("1 = 1").Delete(&models.ProductRecommendation{}).Error; err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(rows, 500).Error
+	})
+}
+
+// similarTo chọn các sản phẩm cùng danh mục có giá nằm trong PriceBand, giá càng gần điểm càng cao
+// (trong khoảng 0..1), bỏ qua các sản phẩm đã có trong exclude
+func similarTo(product candidate, candidates []candidate, exclude map[uuid.UUID]*scored, band float64, limit int) []scored {
+	var result []scored
+	for _, other := range candidates {
+		if other.ID == product.ID || other.CategoryID != product.CategoryID {
+			continue
+		}
+		if _, ok := exclude[other.ID]; ok {
+			continue
+		}
+
+		// Sản phẩm chưa có giá chỉ được xếp theo đánh giá
+		score := 0.1 * other.RatingAverage / 5
+		if product.Price != nil && other.Price != nil && *product.Price > 0 {
+			distance := math.Abs(*other.Price-*product.Price) / *product.Price
+			if distance > band {
+				continue
+			}
+			score = 0.5*(1-distance/math.Max(band, 1e-9)) + 0.4*other.RatingAverage/5
+		}
+		result = append(result, scored{id: other.ID, score: math.Min(score, 0.99)})
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].score > result[j].score })
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
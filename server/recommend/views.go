@@ -0,0 +1,78 @@
+package recommend
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"ecommerce-project/config"
+	"ecommerce-project/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Một người xem lại cùng sản phẩm trong khoảng này chỉ được ghi nhận một lần
+const viewDedupWindow = 30 * time.Minute
+
+// view là một lượt xem chờ được ghi vào product_interactions
+type view struct {
+	subject   string
+	userID    *uuid.UUID
+	productID uuid.UUID
+	at        time.Time
+}
+
+// views là hàng đợi lượt xem; khi đầy, lượt xem mới bị bỏ qua thay vì làm chậm request
+var views = make(chan view, 1024)
+
+// RecordView đưa lượt xem vào hàng đợi để ghi ở goroutine nền (ProcessViews), không chờ database
+func RecordView(subject string, userID *uuid.UUID, productID uuid.UUID) {
+	select {
+	case views <- view{subject: subject, userID: userID, productID: productID, at: time.Now()}:
+	default:
+	}
+}
+
+// ProcessViews ghi các lượt xem trong hàng đợi cho tới khi ctx bị huỷ. Mỗi người chỉ được ghi
+// tối đa VIEW_RATE_LIMIT lượt xem mỗi giờ (mặc định 120), lượt xem lặp lại trong viewDedupWindow
+// bị bỏ qua; lỗi chỉ được ghi log.
+func ProcessViews(ctx context.Context, db *gorm.DB) {
+	limit := int64(config.GetEnvInt("VIEW_RATE_LIMIT", 120))
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case v := <-views:
+			if err := saveView(db, v, limit); err != nil {
+				log.Println("Failed to record product view:", err)
+			}
+		}
+	}
+}
+
+func saveView(db *gorm.DB, v view, limit int64) error {
+	var counts struct {
+		Hour   int64
+		Recent int64
+	}
+	if err := db.Model(&models.ProductInteraction{}).
+		Select("COUNT(*) AS hour, COUNT(*) FILTER (WHERE product_id = ? AND created_at > ?) AS recent",
+			v.productID, v.at.Add(-viewDedupWindow)).
+		Where("subject_key = ? AND kind = ? AND created_at > ?", v.subject, models.InteractionView, v.at.Add(-time.Hour)).
+		Scan(&counts).Error; err != nil {
+		return err
+	}
+	if counts.Recent > 0 || counts.Hour >= limit {
+		return nil
+	}
+
+	return db.Create(&models.ProductInteraction{
+		ID:         uuid.New(),
+		SubjectKey: v.subject,
+		UserID:     v.userID,
+		ProductID:  v.productID,
+		Kind:       models.InteractionView,
+		CreatedAt:  v.at,
+	}).Error
+}
@@ -12,9 +12,22 @@ func AuthRoutes(r *gin.RouterGroup) {
 	{
 		auth.POST("/register", controllers.Register)
 		auth.POST("/login", controllers.Login)
+		// Bước 2 của đăng nhập khi tài khoản đã bật 2FA
+		auth.POST("/login/2fa", controllers.LoginTwoFactor)
 		
 		auth.POST("/logout", controllers.Logout)
 		auth.POST("/refresh", controllers.Refresh)
+
+		// Xác minh email và đặt lại mật khẩu bằng token gửi qua email
+		auth.POST("/verify-email", controllers.VerifyEmail)
+		auth.POST("/forgot-password", controllers.ForgotPassword)
+		auth.POST("/reset-password", controllers.ResetPassword)
+		auth.POST("/confirm-email-change", controllers.ConfirmEmailChange)
+
+		// Đăng nhập bằng Google, Facebook... (authorization code + PKCE)
+		auth.GET("/oauth/providers", controllers.GetOAuthProviders)
+		auth.GET("/oauth/:provider", controllers.StartOAuthLogin)
+		auth.POST("/oauth/:provider/callback", controllers.OAuthCallback)
 		auth.Use(middleware.AuthMiddleware("user", "admin"))
 		{
 			auth.GET("/me", controllers.CheckMe)
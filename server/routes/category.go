@@ -3,6 +3,7 @@ package routes
 import (
 	"ecommerce-project/controllers"
 	"ecommerce-project/middleware"
+	"ecommerce-project/rbac"
 
 	"github.com/gin-gonic/gin"
 )
@@ -13,10 +14,11 @@ func CategoryProductRoutes(r *gin.RouterGroup) {
     r.GET("/categproes/:id", controllers.GetCategory)
 
     admin := r.Group("/admin")
-    admin.Use(middleware.AuthMiddleware("admin"))
+    admin.Use(middleware.AuthMiddleware("user", "admin"), middleware.RequirePermission(rbac.CategoryWrite))
     {
         admin.POST("/categories", controllers.CreateCategory)
         admin.PUT("/categories/:id", controllers.UpdateCategory)
         admin.DELETE("/categories/:id", controllers.DeleteCategory)
+        admin.POST("/categories/:id/restore", controllers.RestoreCategory)
      }
 }
\ No newline at end of file
@@ -3,6 +3,7 @@ package routes
 import (
 	"ecommerce-project/controllers"
 	"ecommerce-project/middleware"
+	"ecommerce-project/rbac"
 
 	"github.com/gin-gonic/gin"
 )
@@ -10,9 +11,22 @@ import (
 // RegisterMediaRoutes đăng ký các route liên quan đến media (hình ảnh)
 func MediaRoutes(router *gin.RouterGroup) {
 	// Định nghĩa route cho upload image, sử dụng phương thức POST
-	router.Use(middleware.AuthMiddleware("admin"))
+	// Dùng group riêng để middleware admin không áp dụng cho các route đăng ký sau
+	media := router.Group("/media")
+	media.Use(middleware.AuthMiddleware("user", "admin"), middleware.RequirePermission(rbac.MediaWrite))
 	{
-		router.POST("/media/upload", controllers.UploadImage)
-		router.DELETE("/media/images", controllers.DeleteImage)
+		media.POST("/upload", controllers.UploadImage)
+		media.DELETE("/images", controllers.DeleteImage)
+
+		// Thư viện media
+		media.POST("", controllers.UploadMedia)
+		media.GET("", controllers.GetMediaList)
+		media.GET("/:id", controllers.GetMedia)
+		media.PATCH("/:id", controllers.UpdateMedia)
+		media.DELETE("/:id", controllers.DeleteMedia)
+
+		// Upload trực tiếp lên storage bằng URL được ký trước
+		media.POST("/uploads", controllers.CreateMediaUpload)
+		media.POST("/uploads/:id/complete", controllers.CompleteMediaUpload)
 	}
 }
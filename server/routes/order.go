@@ -0,0 +1,44 @@
+package routes
+
+import (
+	"ecommerce-project/controllers"
+	"ecommerce-project/middleware"
+	"ecommerce-project/rbac"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OrderRoutes định nghĩa các routes cho đơn hàng và yêu cầu trả hàng (RMA) của người dùng và admin
+func OrderRoutes(r *gin.RouterGroup) {
+	userGroup := r.Group("/user")
+	userGroup.Use(middleware.AuthMiddleware("user", "admin"))
+	{
+		userGroup.GET("/orders", controllers.GetMyOrders)
+		userGroup.GET("/orders/:id", controllers.GetMyOrder)
+		// Yêu cầu trả hàng cho các dòng của đơn đã giao, trong thời hạn RMA_RETURN_WINDOW
+		userGroup.POST("/orders/:id/returns", controllers.CreateReturn)
+		userGroup.GET("/returns", controllers.GetMyReturns)
+		userGroup.GET("/returns/:id", controllers.GetMyReturn)
+	}
+
+	// --- Các route admin xử lý đơn hàng và trả hàng ---
+	admin := r.Group("/admin")
+	admin.Use(middleware.AuthMiddleware("user", "admin"), middleware.RequirePermission(rbac.OrderManage))
+	{
+		admin.GET("/orders", controllers.AdminGetOrders)
+		admin.PUT("/orders/:id/status", controllers.AdminUpdateOrderStatus)
+		admin.GET("/returns", controllers.AdminGetReturns)
+		admin.GET("/returns/:id", controllers.AdminGetReturn)
+		admin.POST("/returns/:id/approve", controllers.ApproveReturn)
+		admin.POST("/returns/:id/reject", controllers.RejectReturn)
+		// Xác nhận đã nhận hàng trả về, hàng được nhập lại kho
+		admin.POST("/returns/:id/receive", controllers.ReceiveReturn)
+	}
+
+	refunds := r.Group("/admin")
+	refunds.Use(middleware.AuthMiddleware("user", "admin"), middleware.RequirePermission(rbac.OrderRefund))
+	{
+		// Hoàn tiền một phần hoặc toàn bộ qua nhà cung cấp thanh toán của đơn hàng
+		refunds.POST("/returns/:id/refund", controllers.RefundReturn)
+	}
+}
@@ -3,6 +3,7 @@ package routes
 import (
 	"ecommerce-project/controllers"
 	"ecommerce-project/middleware"
+	"ecommerce-project/rbac"
 
 	"github.com/gin-gonic/gin"
 )
@@ -11,14 +12,37 @@ import (
 func ProductRoutes(r *gin.RouterGroup) {
 	// --- Các route public ---
 	r.GET("/products", controllers.GetProducts)
-	r.GET("/products/:id", controllers.GetProduct)
+	r.GET("/products/compare", controllers.CompareProducts)
+	// Người xem đã đăng nhập (nếu có) được ghi nhận cho gợi ý sản phẩm
+	r.GET("/products/:id", middleware.OptionalAuth(), controllers.GetProduct)
+	r.GET("/products/:id/related", controllers.GetRelatedProducts)
+	// Phụ kiện dùng được với một thiết bị và thiết bị mà một phụ kiện dùng được
+	r.GET("/products/:id/accessories", controllers.GetProductAccessories)
+	r.GET("/products/:id/compatible-devices", controllers.GetCompatibleDevices)
 
-	// --- Các route admin cho sản phẩm ---
+	// --- Các route quản trị sản phẩm (admin hoặc nhân viên có quyền product:write) ---
 	admin := r.Group("/admin")
-	admin.Use(middleware.AuthMiddleware("admin"))
+	admin.Use(middleware.AuthMiddleware("user", "admin"), middleware.RequirePermission(rbac.ProductWrite))
 	{
+		admin.GET("/products", controllers.AdminGetProducts)
+		admin.GET("/products/:id", controllers.AdminGetProduct)
 		admin.POST("/products", controllers.CreateProduct)
 		admin.PUT("/products/:id", controllers.UpdateProduct)
+		admin.PUT("/products/:id/status", controllers.UpdateProductStatus)
 		admin.DELETE("/products/:id", controllers.DeleteProduct)
+		admin.POST("/products/:id/restore", controllers.RestoreProduct)
+		admin.PUT("/products/:id/compatible-devices", controllers.SetCompatibleDevices)
+		admin.PUT("/products/:id/images", controllers.SetProductImages)
+		admin.POST("/compatibility", controllers.BulkUpdateCompatibility)
+		admin.GET("/products/:id/revisions", controllers.GetProductRevisions)
+		admin.GET("/products/:id/revisions/:number", controllers.GetProductRevision)
+		admin.POST("/products/:id/revisions/:number/rollback", controllers.RollbackProductRevision)
+	}
+
+	// Thùng rác: quyền xem từng loại mục được kiểm tra trong handler
+	trash := r.Group("/admin")
+	trash.Use(middleware.AuthMiddleware("user", "admin"), middleware.RequirePermission())
+	{
+		trash.GET("/trash", controllers.GetTrash)
 	}
 }
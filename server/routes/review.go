@@ -0,0 +1,30 @@
+package routes
+
+import (
+	"ecommerce-project/controllers"
+	"ecommerce-project/middleware"
+	"ecommerce-project/rbac"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReviewRoutes định nghĩa các routes cho đánh giá sản phẩm (public, người dùng và admin)
+func ReviewRoutes(r *gin.RouterGroup) {
+	// Lấy danh sách đánh giá đã duyệt của sản phẩm
+	r.GET("/products/:id/reviews", controllers.GetProductReviews)
+
+	// Người dùng đã đăng nhập gửi đánh giá (multipart/form-data, ảnh qua trường "images")
+	protected := r.Group("/products")
+	protected.Use(middleware.AuthMiddleware("user", "admin"))
+	{
+		protected.POST("/:id/reviews", controllers.CreateReview)
+	}
+
+	// --- Các route admin kiểm duyệt đánh giá ---
+	admin := r.Group("/admin")
+	admin.Use(middleware.AuthMiddleware("user", "admin"), middleware.RequirePermission(rbac.ReviewModerate))
+	{
+		admin.GET("/reviews", controllers.GetReviewsForModeration)
+		admin.PUT("/reviews/:id/moderate", controllers.ModerateReview)
+	}
+}
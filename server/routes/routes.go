@@ -1,10 +1,25 @@
 package routes
 
 import (
+	"ecommerce-project/config"
+	"ecommerce-project/controllers"
+
 	"github.com/gin-gonic/gin"
 )
 
 func SetupRoutes(router *gin.Engine) {
+	// Khoá công khai để các service khác xác thực access token
+	router.GET("/.well-known/jwks.json", controllers.JWKS)
+
+	// Phục vụ và nhận upload trực tiếp file khi dùng backend lưu trữ cục bộ (STORAGE_BACKEND=local)
+	if files, ok := config.LocalFileServer(); ok {
+		handler := gin.WrapH(files.Handler())
+		router.GET(files.URLPath()+"/*filepath", handler)
+		router.HEAD(files.URLPath()+"/*filepath", handler)
+		router.PUT(files.URLPath()+"/*filepath", handler)
+	}
+
+	// Audit log được ghi bởi middleware.RequirePermission cho mọi route cần quyền quản trị
 	api := router.Group("/api")
 	{
 		AuthRoutes(api)
@@ -13,7 +28,9 @@ func SetupRoutes(router *gin.Engine) {
 		MediaRoutes(api)
 		CategoryProductRoutes(api)
 		CartRoutes(api)
+		OrderRoutes(api)
 		VariantRoutes(api)
+		ReviewRoutes(api)
 	}
 
 }
@@ -3,14 +3,105 @@ package routes
 import (
 	"ecommerce-project/controllers"
 	"ecommerce-project/middleware"
+	"ecommerce-project/rbac"
 
 	"github.com/gin-gonic/gin"
 )
 
 func UserRoutes(r *gin.RouterGroup) {
 	protected := r.Group("/user")
-	protected.Use(middleware.AuthMiddleware())
+	protected.Use(middleware.AuthMiddleware("user", "admin"))
 	{
 		protected.GET("/me", controllers.Me)
+
+		// Hồ sơ cá nhân, đổi mật khẩu, đổi email và xoá tài khoản
+		protected.PATCH("/me", controllers.UpdateMe)
+		protected.DELETE("/me", controllers.DeleteMe)
+		protected.POST("/me/password", controllers.ChangePassword)
+		protected.POST("/me/email", controllers.RequestEmailChange)
+		// Mã xác nhận qua email thay cho mật khẩu với tài khoản chỉ đăng nhập qua mạng xã hội
+		protected.POST("/me/confirmation", controllers.RequestAccountConfirmation)
+
+		// Gợi ý sản phẩm cá nhân hoá
+		protected.GET("/recommendations", controllers.GetUserRecommendations)
+
+		// Wishlist các variant người dùng muốn mua sau
+		protected.GET("/wishlist", controllers.GetWishlist)
+		protected.POST("/wishlist", controllers.AddWishlistItem)
+		protected.PUT("/wishlist/:id", controllers.UpdateWishlistItem)
+		protected.DELETE("/wishlist/:id", controllers.DeleteWishlistItem)
+
+		// Đăng ký nhận thông báo khi variant có hàng trở lại
+		protected.GET("/stock-subscriptions", controllers.GetStockSubscriptions)
+		protected.POST("/stock-subscriptions", controllers.CreateStockSubscription)
+		protected.DELETE("/stock-subscriptions/:id", controllers.DeleteStockSubscription)
+
+		// Quản lý các phiên đăng nhập (thiết bị)
+		protected.GET("/sessions", controllers.GetSessions)
+		protected.DELETE("/sessions/:id", controllers.RevokeSession)
+		protected.DELETE("/sessions", controllers.RevokeOtherSessions)
+
+		// Xác thực hai lớp (TOTP) và mã khôi phục
+		protected.POST("/2fa/enroll", controllers.EnrollTwoFactor)
+		protected.POST("/2fa/confirm", controllers.ConfirmTwoFactor)
+		protected.POST("/2fa/disable", controllers.DisableTwoFactor)
+		protected.POST("/2fa/recovery-codes", controllers.RegenerateRecoveryCodes)
+
+		// Tài khoản mạng xã hội đã liên kết
+		protected.GET("/identities", controllers.GetLinkedIdentities)
+		// Bắt đầu liên kết provider; provider chuyển về OAuthCallback như khi đăng nhập
+		protected.POST("/identities/:provider", controllers.StartOAuthLink)
+		protected.DELETE("/identities/:id", controllers.UnlinkIdentity)
+	}
+
+	// --- Tra cứu người dùng (quyền user:read) ---
+	console := r.Group("/admin")
+	console.Use(middleware.AuthMiddleware("user", "admin"), middleware.RequirePermission(rbac.UserRead))
+	{
+		console.GET("/users", controllers.AdminGetUsers)
+		console.GET("/users/:id", controllers.AdminGetUser)
+	}
+
+	// Đăng nhập dưới danh nghĩa người dùng để hỗ trợ (quyền user:impersonate)
+	impersonation := r.Group("/admin")
+	impersonation.Use(middleware.AuthMiddleware("user", "admin"), middleware.RequirePermission(rbac.UserImpersonate))
+	{
+		impersonation.POST("/users/:id/impersonate", controllers.ImpersonateUser)
+	}
+
+	// --- Các route quản lý người dùng (admin hoặc nhân viên có quyền user:manage) ---
+	admin := r.Group("/admin")
+	admin.Use(middleware.AuthMiddleware("user", "admin"), middleware.RequirePermission(rbac.UserManage))
+	{
+		// Buộc người dùng đăng xuất khỏi mọi thiết bị
+		admin.POST("/users/:id/force-logout", controllers.ForceLogoutUser)
+
+		// Mở khoá tài khoản bị khoá tạm thời do đăng nhập sai nhiều lần
+		admin.POST("/users/:id/unlock", controllers.UnlockUser)
+
+		// Vô hiệu hoá / kích hoạt lại tài khoản
+		admin.POST("/users/:id/disable", controllers.AdminDisableUser)
+		admin.POST("/users/:id/enable", controllers.AdminEnableUser)
+	}
+
+	audit := r.Group("/admin")
+	audit.Use(middleware.AuthMiddleware("user", "admin"), middleware.RequirePermission(rbac.AuditRead))
+	{
+		audit.GET("/security-events", controllers.GetSecurityEvents)
+		audit.GET("/audit", controllers.GetAuditLogs)
+	}
+
+	// --- Vai trò nhân viên và phân quyền ---
+	roles := r.Group("/admin")
+	roles.Use(middleware.AuthMiddleware("user", "admin"), middleware.RequirePermission(rbac.RoleManage))
+	{
+		roles.GET("/permissions", controllers.GetPermissions)
+		roles.GET("/roles", controllers.GetRoles)
+		roles.POST("/roles", controllers.CreateRole)
+		roles.PUT("/roles/:name", controllers.UpdateRole)
+		roles.DELETE("/roles/:name", controllers.DeleteRole)
+		roles.GET("/users/:id/roles", controllers.GetUserRoles)
+		roles.PUT("/users/:id/roles", controllers.AssignUserRoles)
+		roles.PUT("/users/:id/role", controllers.AdminChangeUserRole)
 	}
 }
@@ -3,6 +3,7 @@ package routes
 import (
 	"ecommerce-project/controllers"
 	"ecommerce-project/middleware"
+	"ecommerce-project/rbac"
 
 	"github.com/gin-gonic/gin"
 )
@@ -15,7 +16,7 @@ func VariantRoutes(r *gin.RouterGroup) {
 
 	// --- Các route admin cho Category ---
 	admin := r.Group("/admin")
-	admin.Use(middleware.AuthMiddleware("admin"))
+	admin.Use(middleware.AuthMiddleware("user", "admin"), middleware.RequirePermission(rbac.ProductWrite))
 	{
 		// Tạo mới một Category cho sản phẩm (nested route)
 		admin.POST("/products/:id/variants", controllers.CreateVariantForProduct)
@@ -23,5 +24,13 @@ func VariantRoutes(r *gin.RouterGroup) {
 		admin.PUT("/variants/:id", controllers.UpdateVariant)
 		// Nếu cần, bạn có thể thêm route DELETE cho Category
 		admin.DELETE("/variants/:id", controllers.DeleteVariant)
+		admin.POST("/variants/:id/restore", controllers.RestoreVariant)
+	}
+
+	// Nhân viên kho chỉ được điều chỉnh tồn kho
+	inventory := r.Group("/admin")
+	inventory.Use(middleware.AuthMiddleware("user", "admin"), middleware.RequirePermission(rbac.InventoryAdjust))
+	{
+		inventory.PATCH("/variants/:id/stock", controllers.AdjustVariantStock)
 	}
 }
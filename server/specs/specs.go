@@ -0,0 +1,198 @@
+package specs
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"ecommerce-project/models"
+
+	"gorm.io/gorm"
+)
+
+// Độ dài tối đa của giá trị thông số kiểu text
+const maxTextLength = 500
+
+// QueryPrefix là tiền tố của tham số lọc theo thông số trong GetProducts (spec.<key>)
+const QueryPrefix = "spec."
+
+var keyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,49}$`)
+
+// ValidateSchema kiểm tra schema thông số của danh mục: key hợp lệ và không trùng,
+// kiểu được hỗ trợ, enum có danh sách giá trị, đơn vị chỉ dùng cho kiểu number.
+func ValidateSchema(schema []models.SpecDefinition) error {
+	seen := map[string]bool{}
+	for _, definition := range schema {
+		if !keyPattern.MatchString(definition.Key) {
+			return fmt.Errorf("invalid spec key %q: use lowercase letters, digits and underscores", definition.Key)
+		}
+		// Hậu tố _min/_max được dành cho bộ lọc khoảng
+		if strings.HasSuffix(definition.Key, "_min") || strings.HasSuffix(definition.Key, "_max") {
+			return fmt.Errorf("spec key %q must not end with _min or _max", definition.Key)
+		}
+		if seen[definition.Key] {
+			return fmt.Errorf("duplicate spec key %q", definition.Key)
+		}
+		seen[definition.Key] = true
+
+		switch definition.Type {
+		case models.SpecTypeEnum:
+			if len(definition.Options) == 0 {
+				return fmt.Errorf("spec %q: enum requires options", definition.Key)
+			}
+		case models.SpecTypeNumber, models.SpecTypeBoolean, models.SpecTypeText:
+			if len(definition.Options) > 0 {
+				return fmt.Errorf("spec %q: options are only allowed for enum", definition.Key)
+			}
+		default:
+			return fmt.Errorf("spec %q: unknown type %q", definition.Key, definition.Type)
+		}
+		if definition.Unit != "" && definition.Type != models.SpecTypeNumber {
+			return fmt.Errorf("spec %q: unit is only allowed for number", definition.Key)
+		}
+	}
+	return nil
+}
+
+// ValidateValues kiểm tra giá trị thông số của sản phẩm theo schema của danh mục và trả về
+// bản đã chuẩn hoá. Thông số không có trong schema, sai kiểu hoặc thiếu thông số bắt buộc bị từ chối.
+func ValidateValues(schema []models.SpecDefinition, values map[string]interface{}) (map[string]interface{}, error) {
+	definitions := map[string]models.SpecDefinition{}
+	for _, definition := range schema {
+		definitions[definition.Key] = definition
+	}
+
+	normalized := map[string]interface{}{}
+	for key, value := range values {
+		definition, ok := definitions[key]
+		if !ok {
+			return nil, fmt.Errorf("unknown spec %q for this category", key)
+		}
+		// null được coi như không khai báo
+		if value == nil {
+			continue
+		}
+
+		switch definition.Type {
+		case models.SpecTypeNumber:
+			number, ok := value.(float64)
+			if !ok || math.IsNaN(number) || math.IsInf(number, 0) {
+				return nil, fmt.Errorf("spec %q must be a number", key)
+			}
+			normalized[key] = number
+		case models.SpecTypeBoolean:
+			boolean, ok := value.(bool)
+			if !ok {
+				return nil, fmt.Errorf("spec %q must be a boolean", key)
+			}
+			normalized[key] = boolean
+		case models.SpecTypeEnum:
+			text, ok := value.(string)
+			if !ok || !slices.Contains(definition.Options, text) {
+				return nil, fmt.Errorf("spec %q must be one of %s", key, strings.Join(definition.Options, ", "))
+			}
+			normalized[key] = text
+		case models.SpecTypeText:
+			text, ok := value.(string)
+			if !ok {
+				return nil, fmt.Errorf("spec %q must be a string", key)
+			}
+			text = strings.TrimSpace(text)
+			if utf8.RuneCountInString(text) > maxTextLength {
+				return nil, fmt.Errorf("spec %q must be at most %d characters", key, maxTextLength)
+			}
+			if text == "" {
+				continue
+			}
+			normalized[key] = text
+		}
+	}
+
+	for _, definition := range schema {
+		if _, ok := normalized[definition.Key]; definition.Required && !ok {
+			return nil, fmt.Errorf("spec %q is required", definition.Key)
+		}
+	}
+	return normalized, nil
+}
+
+// Filter là điều kiện lọc sản phẩm theo một thông số
+type Filter struct {
+	Key string
+	// Values lọc bằng (enum, text, boolean, number), nhiều giá trị cách nhau bởi dấu phẩy
+	Values []string
+	// Min, Max lọc khoảng cho thông số kiểu number
+	Min *float64
+	Max *float64
+}
+
+// ParseFilters đọc các tham số spec.<key>=a,b, spec.<key>_min=x và spec.<key>_max=y từ query string
+func ParseFilters(query map[string][]string) ([]Filter, error) {
+	filters := map[string]*Filter{}
+	get := func(key string) *Filter {
+		if filters[key] == nil {
+			filters[key] = &Filter{Key: key}
+		}
+		return filters[key]
+	}
+
+	for param, values := range query {
+		if !strings.HasPrefix(param, QueryPrefix) || len(values) == 0 {
+			continue
+		}
+		name := strings.TrimPrefix(param, QueryPrefix)
+		value := values[0]
+
+		switch {
+		case strings.HasSuffix(name, "_min"), strings.HasSuffix(name, "_max"):
+			key := name[:len(name)-4]
+			if !keyPattern.MatchString(key) {
+				return nil, fmt.Errorf("invalid spec filter %q", param)
+			}
+			number, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, fmt.Errorf("spec filter %q must be a number", param)
+			}
+			if strings.HasSuffix(name, "_min") {
+				get(key).Min = &number
+			} else {
+				get(key).Max = &number
+			}
+		default:
+			if !keyPattern.MatchString(name) {
+				return nil, fmt.Errorf("invalid spec filter %q", param)
+			}
+			get(name).Values = strings.Split(value, ",")
+		}
+	}
+
+	result := make([]Filter, 0, len(filters))
+	for _, filter := range filters {
+		result = append(result, *filter)
+	}
+	return result, nil
+}
+
+// Apply thêm điều kiện lọc theo thông số vào truy vấn sản phẩm.
+// Giá trị được so dưới dạng chuỗi nên dùng được cho mọi kiểu; lọc khoảng chỉ áp dụng cho giá trị số.
+func Apply(db *gorm.DB, filters []Filter) *gorm.DB {
+	for _, filter := range filters {
+		if len(filter.Values) > 0 {
+			db = db.Where("products.specs ->> ? IN ?", filter.Key, filter.Values)
+		}
+		// CASE bảo đảm chỉ ép kiểu numeric khi giá trị JSON là số
+		if filter.Min != nil {
+			db = db.Where("CASE WHEN jsonb_typeof(products.specs -> ?) = 'number' THEN (products.specs ->> ?)::numeric END >= ?",
+				filter.Key, filter.Key, *filter.Min)
+		}
+		if filter.Max != nil {
+			db = db.Where("CASE WHEN jsonb_typeof(products.specs -> ?) = 'number' THEN (products.specs ->> ?)::numeric END <= ?",
+				filter.Key, filter.Key, *filter.Max)
+		}
+	}
+	return db
+}
@@ -0,0 +1,402 @@
+// Package storagetest là bộ kiểm thử hợp đồng (contract) mà mọi backend config.Storage phải vượt qua.
+// Run chạy với storage thật (Supabase, S3/MinIO, ổ đĩa cục bộ), ghi các object tạm dưới tiền tố
+// "storagetest/" và xoá chúng khi kết thúc.
+//
+// go test ./storagetest chạy bộ kiểm thử với ổ đĩa cục bộ; S3 (cấu hình S3_*) và Supabase (SUPABASE_*)
+// chỉ được chạy khi đặt STORAGETEST_S3 hoặc STORAGETEST_SUPABASE.
+package storagetest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"path"
+	"strings"
+	"time"
+
+	"ecommerce-project/config"
+
+	"github.com/google/uuid"
+)
+
+// Options điều chỉnh cách bộ kiểm thử tải object về qua URL public
+type Options struct {
+	// Client tải object qua URL public, mặc định là http.Client với timeout 30 giây
+	Client *http.Client
+}
+
+// check là một kiểm thử trong bộ hợp đồng
+type check struct {
+	name string
+	run  func(s *suite) error
+}
+
+// checks là danh sách kiểm thử theo thứ tự chạy
+var checks = []check{
+	{"upload bytes and fetch public url", checkUploadBytes},
+	{"nested key with special characters", checkNestedKey},
+	{"overwrite existing key", checkOverwrite},
+	{"upload multipart file", checkUploadFile},
+	{"upload stream", checkUploadStream},
+	{"open file and file url", checkOpenFile},
+	{"open missing file", checkOpenMissing},
+	{"presigned upload", checkPresignedUpload},
+	{"copy file", checkCopyFile},
+	{"delete removes object", checkDelete},
+	{"reject unsafe keys", checkUnsafeKeys},
+}
+
+// Result là kết quả của một kiểm thử; Err bằng nil nếu kiểm thử đạt
+type Result struct {
+	Name string
+	Err  error
+}
+
+// Run chạy toàn bộ kiểm thử với backend stor và trả về kết quả từng kiểm thử.
+// Lỗi trả về khác nil nếu có ít nhất một kiểm thử không đạt hoặc không dọn được object tạm.
+func Run(stor config.Storage, opts Options) ([]Result, error) {
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: 30 * time.Second}
+	}
+	s := &suite{
+		stor:   stor,
+		client: opts.Client,
+		prefix: "storagetest/" + uuid.New().String(),
+	}
+
+	results := make([]Result, 0, len(checks))
+	var failed []string
+	for _, c := range checks {
+		err := c.run(s)
+		results = append(results, Result{Name: c.name, Err: err})
+		if err != nil {
+			failed = append(failed, c.name)
+		}
+	}
+
+	cleanupErr := s.cleanup()
+	if len(failed) > 0 {
+		return results, fmt.Errorf("storage contract failed: %s", strings.Join(failed, ", "))
+	}
+	if cleanupErr != nil {
+		return results, fmt.Errorf("storage contract cleanup failed: %w", cleanupErr)
+	}
+	return results, nil
+}
+
+type suite struct {
+	stor    config.Storage
+	client  *http.Client
+	prefix  string
+	created []string
+}
+
+func (s *suite) key(name string) string {
+	return s.prefix + "/" + name
+}
+
+// upload gọi UploadBytes và ghi nhớ key để dọn dẹp
+func (s *suite) upload(key string, content []byte, contentType string) (string, error) {
+	url, err := s.stor.UploadBytes(key, content, contentType)
+	if err != nil {
+		return "", fmt.Errorf("UploadBytes(%q): %w", key, err)
+	}
+	s.created = append(s.created, key)
+	if url == "" {
+		return "", fmt.Errorf("UploadBytes(%q) returned an empty url", key)
+	}
+	return url, nil
+}
+
+// fetch tải object qua URL public, trả về status, nội dung và Content-Type
+func (s *suite) fetch(url string) (int, []byte, string, error) {
+	resp, err := s.client.Get(url)
+	if err != nil {
+		return 0, nil, "", fmt.Errorf("GET %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, "", fmt.Errorf("GET %s: %w", url, err)
+	}
+	return resp.StatusCode, body, resp.Header.Get("Content-Type"), nil
+}
+
+// expectContent kiểm tra URL public trả về đúng nội dung
+func (s *suite) expectContent(url string, want []byte) error {
+	status, body, _, err := s.fetch(url)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("GET %s: status %d, want 200", url, status)
+	}
+	if !bytes.Equal(body, want) {
+		return fmt.Errorf("GET %s: got %d bytes that differ from the %d bytes uploaded", url, len(body), len(want))
+	}
+	return nil
+}
+
+func (s *suite) cleanup() error {
+	var errs []error
+	for _, key := range s.created {
+		if err := s.stor.DeleteFile(key); err != nil {
+			errs = append(errs, fmt.Errorf("DeleteFile(%q): %w", key, err))
+		}
+	}
+	s.created = nil
+	return errors.Join(errs...)
+}
+
+func checkUploadBytes(s *suite) error {
+	content := []byte("storage contract " + s.prefix)
+	url, err := s.upload(s.key("plain.txt"), content, "text/plain")
+	if err != nil {
+		return err
+	}
+	if err := s.expectContent(url, content); err != nil {
+		return err
+	}
+
+	_, _, contentType, err := s.fetch(url)
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(contentType, "text/plain") {
+		return fmt.Errorf("GET %s: content type %q, want text/plain", url, contentType)
+	}
+	return nil
+}
+
+func checkNestedKey(s *suite) error {
+	content := []byte{0x00, 0xff, 0x10, 0x80, 'o', 'k'}
+	url, err := s.upload(s.key("a/b c/ảnh+1 (copy).bin"), content, "application/octet-stream")
+	if err != nil {
+		return err
+	}
+	return s.expectContent(url, content)
+}
+
+func checkOverwrite(s *suite) error {
+	key := s.key("overwrite.txt")
+	if _, err := s.upload(key, []byte("first version"), "text/plain"); err != nil {
+		return err
+	}
+	content := []byte("second version")
+	url, err := s.upload(key, content, "text/plain")
+	if err != nil {
+		return err
+	}
+	return s.expectContent(url, content)
+}
+
+func checkUploadFile(s *suite) error {
+	content := bytes.Repeat([]byte("multipart stream "), 64*1024)
+	form, err := multipartForm("photo.txt", "text/plain", content)
+	if err != nil {
+		return err
+	}
+	defer form.RemoveAll()
+	file := form.File["file"][0]
+
+	url, err := s.stor.UploadFile(file)
+	if err != nil {
+		return fmt.Errorf("UploadFile: %w", err)
+	}
+	if url == "" {
+		return errors.New("UploadFile returned an empty url")
+	}
+	if path.Ext(url) != ".txt" {
+		return fmt.Errorf("UploadFile url %q does not keep the file extension", url)
+	}
+	// Các controller xoá file upload bằng tên cuối của URL (xem deleteStoredFile)
+	key := path.Base(url)
+	s.created = append(s.created, key)
+	if err := s.expectContent(url, content); err != nil {
+		return err
+	}
+
+	other, err := s.stor.UploadFile(file)
+	if err != nil {
+		return fmt.Errorf("UploadFile: %w", err)
+	}
+	s.created = append(s.created, path.Base(other))
+	if other == url {
+		return fmt.Errorf("UploadFile returned the same url twice: %s", url)
+	}
+	return nil
+}
+
+func checkUploadStream(s *suite) error {
+	content := bytes.Repeat([]byte("stream "), 128*1024)
+	key := s.key("stream.bin")
+	// Reader không có Len/Seek để backend phải đọc theo dạng stream
+	url, err := s.stor.UploadStream(key, io.MultiReader(bytes.NewReader(content)), int64(len(content)), "application/octet-stream")
+	if err != nil {
+		return fmt.Errorf("UploadStream(%q): %w", key, err)
+	}
+	s.created = append(s.created, key)
+	return s.expectContent(url, content)
+}
+
+func checkOpenFile(s *suite) error {
+	content := []byte("open file " + s.prefix)
+	key := s.key("open/file.txt")
+	url, err := s.upload(key, content, "text/plain")
+	if err != nil {
+		return err
+	}
+	if fileURL := s.stor.FileURL(key); fileURL != url {
+		return fmt.Errorf("FileURL(%q) = %q, want the upload url %q", key, fileURL, url)
+	}
+	return s.expectOpen(key, content)
+}
+
+func checkOpenMissing(s *suite) error {
+	key := s.key("missing.txt")
+	file, err := s.stor.OpenFile(key)
+	if err == nil {
+		file.Close()
+		return fmt.Errorf("OpenFile(%q) succeeded for a missing key", key)
+	}
+	if !errors.Is(err, config.ErrObjectNotFound) {
+		return fmt.Errorf("OpenFile(%q): %v, want config.ErrObjectNotFound", key, err)
+	}
+	return nil
+}
+
+func checkPresignedUpload(s *suite) error {
+	content := []byte("presigned upload " + s.prefix)
+	key := s.key("presigned/file.txt")
+	upload, err := s.stor.PresignUpload(key, "text/plain", int64(len(content)), 5*time.Minute)
+	if err != nil {
+		return fmt.Errorf("PresignUpload(%q): %w", key, err)
+	}
+	if upload.URL == "" || upload.Method == "" {
+		return fmt.Errorf("PresignUpload(%q) returned an incomplete request", key)
+	}
+	if !upload.ExpiresAt.After(time.Now()) {
+		return fmt.Errorf("PresignUpload(%q) is already expired at %s", key, upload.ExpiresAt)
+	}
+
+	// Client upload bằng request trần, không có thông tin xác thực nào ngoài URL và header được cấp
+	req, err := http.NewRequest(upload.Method, upload.URL, bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+	for name, value := range upload.Headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", upload.Method, upload.URL, err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	s.created = append(s.created, key)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s %s: status %d: %s", upload.Method, upload.URL, resp.StatusCode, body)
+	}
+
+	if err := s.expectOpen(key, content); err != nil {
+		return err
+	}
+	return s.expectContent(s.stor.FileURL(key), content)
+}
+
+func checkCopyFile(s *suite) error {
+	content := []byte("copy file " + s.prefix)
+	from := s.key("copy/source.txt")
+	if _, err := s.upload(from, content, "text/plain"); err != nil {
+		return err
+	}
+	to := s.key("copy/target.txt")
+	if err := s.stor.CopyFile(from, to); err != nil {
+		return fmt.Errorf("CopyFile(%q, %q): %w", from, to, err)
+	}
+	s.created = append(s.created, to)
+	if err := s.expectOpen(to, content); err != nil {
+		return err
+	}
+	if err := s.expectOpen(from, content); err != nil {
+		return err
+	}
+
+	missing := s.key("copy/missing.txt")
+	if err := s.stor.CopyFile(missing, s.key("copy/other.txt")); !errors.Is(err, config.ErrObjectNotFound) {
+		return fmt.Errorf("CopyFile(%q, ...): %v, want config.ErrObjectNotFound", missing, err)
+	}
+	return nil
+}
+
+// expectOpen kiểm tra OpenFile trả về đúng nội dung
+func (s *suite) expectOpen(key string, want []byte) error {
+	file, err := s.stor.OpenFile(key)
+	if err != nil {
+		return fmt.Errorf("OpenFile(%q): %w", key, err)
+	}
+	defer file.Close()
+	got, err := io.ReadAll(file)
+	if err != nil {
+		return fmt.Errorf("OpenFile(%q): %w", key, err)
+	}
+	if !bytes.Equal(got, want) {
+		return fmt.Errorf("OpenFile(%q): got %d bytes that differ from the %d bytes uploaded", key, len(got), len(want))
+	}
+	return nil
+}
+
+func checkDelete(s *suite) error {
+	key := s.key("deleted.txt")
+	url, err := s.upload(key, []byte("to be deleted"), "text/plain")
+	if err != nil {
+		return err
+	}
+	if err := s.stor.DeleteFile(key); err != nil {
+		return fmt.Errorf("DeleteFile(%q): %w", key, err)
+	}
+
+	status, _, _, err := s.fetch(url)
+	if err != nil {
+		return err
+	}
+	if status >= 200 && status < 300 {
+		return fmt.Errorf("GET %s after delete: status %d, want an error status", url, status)
+	}
+	return nil
+}
+
+func checkUnsafeKeys(s *suite) error {
+	for _, key := range []string{"", "/absolute.txt", "../escape.txt", s.prefix + "/../escape.txt", s.prefix + "//double.txt"} {
+		if _, err := s.stor.UploadBytes(key, []byte("unsafe"), "text/plain"); err == nil {
+			return fmt.Errorf("UploadBytes(%q) succeeded, want an error", key)
+		}
+	}
+	return nil
+}
+
+// multipartForm tạo form có một file ở trường "file", giống như khi gin đọc form upload
+func multipartForm(filename, contentType string, content []byte) (*multipart.Form, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename="%s"`, filename))
+	header.Set("Content-Type", contentType)
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := part.Write(content); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	// maxMemory = 0 để file được ghi ra đĩa tạm, giống upload lớn trong thực tế
+	return multipart.NewReader(&body, writer.Boundary()).ReadForm(0)
+}
@@ -0,0 +1,63 @@
+package storagetest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"ecommerce-project/config"
+)
+
+// runContract chạy bộ kiểm thử hợp đồng và báo lỗi riêng cho từng kiểm thử không đạt
+func runContract(t *testing.T, stor config.Storage) {
+	t.Helper()
+	results, err := Run(stor, Options{})
+	for _, result := range results {
+		if result.Err != nil {
+			t.Errorf("%s: %v", result.Name, result.Err)
+		}
+	}
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+// TestLocalStorage chạy bộ hợp đồng với ổ đĩa cục bộ (thư mục tạm) phục vụ qua server HTTP của httptest
+func TestLocalStorage(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	stor, err := config.NewLocalStorage(t.TempDir(), server.URL, "/files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux.Handle(stor.URLPath()+"/", stor.Handler())
+
+	runContract(t, stor)
+}
+
+// TestS3Storage chạy với S3/MinIO thật theo cấu hình S3_*, chỉ khi STORAGETEST_S3 được đặt
+func TestS3Storage(t *testing.T) {
+	if os.Getenv("STORAGETEST_S3") == "" {
+		t.Skip("STORAGETEST_S3 is not set")
+	}
+	stor, err := config.NewStorage(config.StorageBackendS3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runContract(t, stor)
+}
+
+// TestSupabaseStorage chạy với Supabase thật theo cấu hình SUPABASE_*, chỉ khi STORAGETEST_SUPABASE được đặt
+func TestSupabaseStorage(t *testing.T) {
+	if os.Getenv("STORAGETEST_SUPABASE") == "" {
+		t.Skip("STORAGETEST_SUPABASE is not set")
+	}
+	stor, err := config.NewStorage(config.StorageBackendSupabase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runContract(t, stor)
+}
@@ -3,72 +3,163 @@ package utils
 import (
 	"ecommerce-project/config"
 	"ecommerce-project/models"
+	"ecommerce-project/rbac"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
-func GenerateTokens(user models.User) (string, string, error) {
-    // Access token (60 phút)
-    accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-        "sub": user.ID.String(),
-        "role": user.Role,
-        "exp": time.Now().Add(60 * time.Minute).Unix(),
-    })
-
-    // Refresh token (7 ngày)
-    refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-        "sub": user.ID.String(),
-        "role": user.Role,
-        "exp": time.Now().Add(7 * 24 * time.Hour).Unix(),
-    })
-
-    accessTokenString, err := accessToken.SignedString([]byte(config.GetEnv("ACCESS_TOKEN_SECRET")))
-    if err != nil {
-        return "", "", err
-    }
-
-    refreshTokenString, err := refreshToken.SignedString([]byte(config.GetEnv("REFRESH_TOKEN_SECRET")))
-    if err != nil {
-        return "", "", err
-    }
-
-    return accessTokenString, refreshTokenString, nil
+// AccessTokenTTL là thời gian sống của access token
+const AccessTokenTTL = 60 * time.Minute
+
+// TokenClaims là các thông tin được đọc ra từ access token
+type TokenClaims struct {
+	UserID uuid.UUID
+	Role   string
+	// SessionID là FamilyID của refresh token đã cấp access token này
+	SessionID uuid.UUID
+	// TokenVersion phải khớp với User.TokenVersion, admin tăng giá trị này để buộc đăng xuất
+	TokenVersion int
+	// MFA cho biết phiên đăng nhập đã qua xác thực hai lớp
+	MFA bool
+	// Permissions là các quyền của người dùng tại thời điểm cấp token (xem package rbac)
+	Permissions []string
+	// ImpersonatorID là admin đang giả danh người dùng (claim imp), uuid.Nil nếu không giả danh
+	ImpersonatorID uuid.UUID
+}
+
+// tokenIssuer và tokenAudience được kiểm tra khi xác thực access token
+func tokenIssuer() string {
+	return config.GetEnvDefault("JWT_ISSUER", "phonestore-api")
+}
+
+func tokenAudience() string {
+	return config.GetEnvDefault("JWT_AUDIENCE", "phonestore")
 }
 
-// GenerateAccessToken generates a new access token for the given user.
-func GenerateAccessToken(user models.User) (string, error) {
-	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"sub":  user.ID.String(),
-		"role": user.Role,
-		"exp":  time.Now().Add(60 * time.Minute).Unix(),
+// GenerateTokens cấp access token và refresh token cho phiên đăng nhập session
+// (FamilyID là id phiên, MFA cho biết phiên đã qua xác thực hai lớp).
+// Access token được ký bằng khoá bất đối xứng đang hoạt động (header có kid),
+// refresh token là chuỗi ngẫu nhiên chỉ được đối chiếu qua hash lưu trong database.
+// Quyền của người dùng được nhúng vào access token (claim perms).
+func GenerateTokens(user models.User, session models.RefreshToken) (string, string, error) {
+	key, err := activeSigningKey()
+	if err != nil {
+		return "", "", err
+	}
+
+	permissions, err := rbac.UserPermissions(config.DB, user)
+	if err != nil {
+		return "", "", err
+	}
+
+	now := time.Now()
+	accessToken := jwt.NewWithClaims(key.method, jwt.MapClaims{
+		"iss":   tokenIssuer(),
+		"aud":   tokenAudience(),
+		"sub":   user.ID.String(),
+		"role":  user.Role,
+		"sid":   session.FamilyID.String(),
+		"ver":   user.TokenVersion,
+		"mfa":   session.MFA,
+		"perms": permissions,
+		"jti":   uuid.New().String(),
+		"iat":   now.Unix(),
+		"exp":   now.Add(AccessTokenTTL).Unix(),
 	})
-	return accessToken.SignedString([]byte(config.GetEnv("ACCESS_TOKEN_SECRET")))
+	accessToken.Header["kid"] = key.kid
+	if session.ImpersonatorID != nil {
+		accessToken.Claims.(jwt.MapClaims)["imp"] = session.ImpersonatorID.String()
+	}
+
+	accessTokenString, err := accessToken.SignedString(key.privateKey)
+	if err != nil {
+		return "", "", err
+	}
+
+	refreshTokenString, _, err := GenerateOpaqueToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	return accessTokenString, refreshTokenString, nil
 }
 
-func ParseToken(tokenString string, secretKey string) (uuid.UUID, string, error) {
-    token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-        return []byte(secretKey), nil
-    })
+// ParseToken xác thực access token: chữ ký theo khoá có kid tương ứng, thuật toán thuộc
+// danh sách cho phép, issuer, audience và thời hạn.
+func ParseToken(tokenString string) (*TokenClaims, error) {
+	token, err := jwt.Parse(tokenString, verificationKey,
+		jwt.WithValidMethods([]string{AlgorithmEdDSA, AlgorithmRS256}),
+		jwt.WithIssuer(tokenIssuer()),
+		jwt.WithAudience(tokenAudience()),
+		jwt.WithExpirationRequired(),
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, jwt.ErrInvalidKey
+	}
 
-    if err != nil {
-        return uuid.Nil, "", err
-    }
+	sub, ok := claims["sub"].(string)
+	if !ok {
+		return nil, jwt.ErrInvalidKey
+	}
+	userID, err := uuid.Parse(sub)
+	if err != nil {
+		return nil, err
+	}
 
-    if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-        userID, err := uuid.Parse(claims["sub"].(string))
-        if err != nil {
-            return uuid.Nil, "", err
-        }
+	role, ok := claims["role"].(string)
+	if !ok {
+		return nil, jwt.ErrInvalidKey
+	}
 
-        role, ok := claims["role"].(string)
-        if !ok {
-            return uuid.Nil, "", jwt.ErrInvalidKey
-        }
+	sid, ok := claims["sid"].(string)
+	if !ok {
+		return nil, jwt.ErrInvalidKey
+	}
+	sessionID, err := uuid.Parse(sid)
+	if err != nil {
+		return nil, err
+	}
 
-        return userID, role, nil
-    }
+	// Các số trong JWT được giải mã thành float64
+	version, ok := claims["ver"].(float64)
+	if !ok {
+		return nil, jwt.ErrInvalidKey
+	}
 
-    return uuid.Nil, "", jwt.ErrInvalidKey
-}
\ No newline at end of file
+	mfa, _ := claims["mfa"].(bool)
+
+	// Mảng JSON được giải mã thành []interface{}
+	var permissions []string
+	if perms, ok := claims["perms"].([]interface{}); ok {
+		for _, perm := range perms {
+			if name, ok := perm.(string); ok {
+				permissions = append(permissions, name)
+			}
+		}
+	}
+
+	var impersonatorID uuid.UUID
+	if imp, ok := claims["imp"].(string); ok {
+		if impersonatorID, err = uuid.Parse(imp); err != nil {
+			return nil, err
+		}
+	}
+
+	return &TokenClaims{
+		UserID:         userID,
+		Role:           role,
+		SessionID:      sessionID,
+		TokenVersion:   int(version),
+		MFA:            mfa,
+		Permissions:    permissions,
+		ImpersonatorID: impersonatorID,
+	}, nil
+}
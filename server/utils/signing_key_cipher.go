@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"ecommerce-project/config"
+)
+
+// encryptedKeyPrefix đánh dấu phiên bản định dạng mã hoá: AES-256-GCM, base64(nonce || ciphertext)
+const encryptedKeyPrefix = "v1:"
+
+var errMissingKeyEncryptionKey = errors.New("SIGNING_KEY_ENCRYPTION_KEY must be a base64-encoded 32-byte key")
+
+// signingKeyCipher tạo AEAD từ khoá mã hoá trong biến môi trường SIGNING_KEY_ENCRYPTION_KEY
+// (32 byte, mã hoá base64, ví dụ tạo bằng `openssl rand -base64 32`).
+func signingKeyCipher() (cipher.AEAD, error) {
+	key, err := base64.StdEncoding.DecodeString(config.GetEnv("SIGNING_KEY_ENCRYPTION_KEY"))
+	if err != nil || len(key) != 32 {
+		return nil, errMissingKeyEncryptionKey
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptPrivateKey mã hoá khoá bí mật (PEM) của khoá kid. kid được dùng làm dữ liệu xác thực kèm theo,
+// nên bản mã không thể bị chép sang một dòng khác.
+func encryptPrivateKey(kid string, plaintext []byte) (string, error) {
+	aead, err := signingKeyCipher()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, plaintext, []byte(kid))
+	return encryptedKeyPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decryptPrivateKey giải mã khoá bí mật đã được encryptPrivateKey mã hoá
+func decryptPrivateKey(kid, encrypted string) ([]byte, error) {
+	if !strings.HasPrefix(encrypted, encryptedKeyPrefix) {
+		return nil, errors.New("unsupported private key encoding")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(encrypted, encryptedKeyPrefix))
+	if err != nil {
+		return nil, err
+	}
+	aead, err := signingKeyCipher()
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("encrypted private key is too short")
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(kid))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt private key: %w", err)
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,385 @@
+package utils
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"sync"
+	"time"
+
+	"ecommerce-project/config"
+	"ecommerce-project/models"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// Các thuật toán ký được hỗ trợ, chọn bằng biến môi trường JWT_SIGNING_ALG
+const (
+	AlgorithmEdDSA = "EdDSA"
+	AlgorithmRS256 = "RS256"
+)
+
+var errUnknownKey = errors.New("unknown signing key")
+
+// signingKey là khoá đã được giải mã từ bảng signing_keys
+type signingKey struct {
+	kid        string
+	method     jwt.SigningMethod
+	privateKey crypto.Signer
+	createdAt  time.Time
+	retired    bool
+}
+
+// keyStore giữ các khoá ký trong bộ nhớ, được nạp lại từ database mỗi lần rotation chạy
+var keyStore = struct {
+	sync.RWMutex
+	keys   map[string]*signingKey
+	active *signingKey
+}{keys: map[string]*signingKey{}}
+
+// signingKeyLockID là khoá advisory lock của Postgres dùng để các instance không cùng xoay vòng khoá
+const signingKeyLockID = 7_202_603_301
+
+// InitSigningKeys mã hoá các khoá cũ còn lưu dạng PEM, nạp các khoá ký từ database và tạo khoá
+// đầu tiên nếu chưa có khoá nào đang hoạt động. Cần SIGNING_KEY_ENCRYPTION_KEY.
+func InitSigningKeys() {
+	if _, err := signingKeyCipher(); err != nil {
+		log.Fatal("Failed to initialize signing keys:", err)
+	}
+	if err := migrateSigningKeys(); err != nil {
+		log.Fatal("Failed to migrate signing keys:", err)
+	}
+	if err := RotateSigningKeys(); err != nil {
+		log.Fatal("Failed to initialize signing keys:", err)
+	}
+}
+
+// migrateSigningKeys mã hoá khoá bí mật của phiên bản cũ (cột private_key_pem) rồi xoá cột đó,
+// và đảm bảo chỉ có một khoá đang hoạt động bằng unique partial index.
+func migrateSigningKeys() error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", signingKeyLockID).Error; err != nil {
+			return err
+		}
+
+		if tx.Migrator().HasColumn(&models.SigningKey{}, "private_key_pem") {
+			var legacy []struct {
+				ID            string
+				PrivateKeyPEM string
+			}
+			if err := tx.Table("signing_keys").Select("id, private_key_pem").
+				Where("encrypted_private_key IS NULL OR encrypted_private_key = ''").
+				Scan(&legacy).Error; err != nil {
+				return err
+			}
+			for _, record := range legacy {
+				encrypted, err := encryptPrivateKey(record.ID, []byte(record.PrivateKeyPEM))
+				if err != nil {
+					return err
+				}
+				if err := tx.Model(&models.SigningKey{}).Where("id = ?", record.ID).
+					Update("encrypted_private_key", encrypted).Error; err != nil {
+					return err
+				}
+			}
+			if err := tx.Migrator().DropColumn(&models.SigningKey{}, "private_key_pem"); err != nil {
+				return err
+			}
+		}
+
+		// Các lần xoay vòng đồng thời trước đây có thể đã tạo nhiều khoá đang hoạt động: giữ khoá mới nhất
+		now := time.Now()
+		expiresAt := now.Add(AccessTokenTTL + config.GetEnvDuration("JWT_KEY_CHECK_INTERVAL", 5*time.Minute))
+		if err := tx.Exec(`UPDATE signing_keys SET retired_at = ?, expires_at = ?
+			WHERE retired_at IS NULL AND id <> (SELECT id FROM signing_keys WHERE retired_at IS NULL ORDER BY created_at DESC LIMIT 1)`,
+			now, expiresAt).Error; err != nil {
+			return err
+		}
+		return tx.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_signing_keys_single_active ON signing_keys ((retired_at IS NULL)) WHERE retired_at IS NULL").Error
+	})
+}
+
+// StartKeyRotation định kỳ kiểm tra và xoay vòng khoá ký cho tới khi ctx bị huỷ
+func StartKeyRotation(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(config.GetEnvDuration("JWT_KEY_CHECK_INTERVAL", 5*time.Minute))
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := RotateSigningKeys(); err != nil {
+					log.Println("Signing key rotation failed:", err)
+				}
+			}
+		}
+	}()
+}
+
+// RotateSigningKeys tạo khoá mới khi khoá đang dùng đã quá JWT_KEY_ROTATION_INTERVAL,
+// xoá các khoá đã hết hạn công bố và nạp lại toàn bộ khoá vào bộ nhớ. Việc xoay vòng chạy trong
+// transaction giữ advisory lock nên các instance khởi động hoặc xoay vòng cùng lúc chỉ tạo một khoá.
+func RotateSigningKeys() error {
+	rotationInterval := config.GetEnvDuration("JWT_KEY_ROTATION_INTERVAL", 30*24*time.Hour)
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", signingKeyLockID).Error; err != nil {
+			return err
+		}
+		now := time.Now()
+
+		// Khoá đã ngừng ký chỉ cần được công bố thêm trong thời gian sống của access token
+		if err := tx.Where("expires_at IS NOT NULL AND expires_at < ?", now).
+			Delete(&models.SigningKey{}).Error; err != nil {
+			return err
+		}
+
+		var active models.SigningKey
+		err := tx.Where("retired_at IS NULL").Order("created_at DESC").First(&active).Error
+		if err != nil && err != gorm.ErrRecordNotFound {
+			return err
+		}
+		if err == nil && now.Sub(active.CreatedAt) < rotationInterval {
+			return nil
+		}
+
+		record, err := generateSigningKey(config.GetEnvDefault("JWT_SIGNING_ALG", AlgorithmEdDSA))
+		if err != nil {
+			return err
+		}
+
+		// Ngừng ký bằng khoá cũ trước khi thêm khoá mới (chỉ được có một khoá đang hoạt động).
+		// Instance khác có thể vẫn ký bằng khoá cũ cho tới lần nạp lại tiếp theo.
+		expiresAt := now.Add(AccessTokenTTL + config.GetEnvDuration("JWT_KEY_CHECK_INTERVAL", 5*time.Minute))
+		if err := tx.Model(&models.SigningKey{}).
+			Where("retired_at IS NULL").
+			Updates(map[string]interface{}{"retired_at": now, "expires_at": expiresAt}).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&record).Error; err != nil {
+			return err
+		}
+		log.Println("Generated new signing key:", record.ID)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return loadSigningKeys()
+}
+
+// loadSigningKeys đọc toàn bộ khoá trong database vào keyStore
+func loadSigningKeys() error {
+	var records []models.SigningKey
+	if err := config.DB.Order("created_at ASC").Find(&records).Error; err != nil {
+		return err
+	}
+
+	keys := map[string]*signingKey{}
+	var active *signingKey
+	for _, record := range records {
+		key, err := decodeSigningKey(record)
+		if err != nil {
+			return fmt.Errorf("failed to decode signing key %s: %w", record.ID, err)
+		}
+		keys[key.kid] = key
+		if !key.retired && (active == nil || key.createdAt.After(active.createdAt)) {
+			active = key
+		}
+	}
+	if active == nil {
+		return errors.New("no active signing key")
+	}
+
+	keyStore.Lock()
+	keyStore.keys = keys
+	keyStore.active = active
+	keyStore.Unlock()
+	return nil
+}
+
+var (
+	reloadMu   sync.Mutex
+	lastReload time.Time
+)
+
+// reloadSigningKeys nạp lại khoá từ database khi gặp kid chưa biết, giới hạn tần suất
+// để token giả mạo với kid ngẫu nhiên không gây tải lên database.
+func reloadSigningKeys() bool {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	if time.Since(lastReload) < 10*time.Second {
+		return false
+	}
+	lastReload = time.Now()
+	return loadSigningKeys() == nil
+}
+
+// generateSigningKey tạo cặp khoá mới theo thuật toán alg
+func generateSigningKey(alg string) (models.SigningKey, error) {
+	var privateKey crypto.Signer
+	var err error
+	switch alg {
+	case AlgorithmEdDSA:
+		_, privateKey, err = ed25519.GenerateKey(rand.Reader)
+	case AlgorithmRS256:
+		privateKey, err = rsa.GenerateKey(rand.Reader, 2048)
+	default:
+		return models.SigningKey{}, fmt.Errorf("unsupported signing algorithm %q", alg)
+	}
+	if err != nil {
+		return models.SigningKey{}, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return models.SigningKey{}, err
+	}
+
+	kidBytes := make([]byte, 16)
+	if _, err := rand.Read(kidBytes); err != nil {
+		return models.SigningKey{}, err
+	}
+
+	kid := hex.EncodeToString(kidBytes)
+	encrypted, err := encryptPrivateKey(kid, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	if err != nil {
+		return models.SigningKey{}, err
+	}
+
+	return models.SigningKey{
+		ID:                  kid,
+		Algorithm:           alg,
+		EncryptedPrivateKey: encrypted,
+		CreatedAt:           time.Now(),
+	}, nil
+}
+
+// decodeSigningKey giải mã khoá bí mật và kiểm tra khoá khớp với thuật toán đã lưu
+func decodeSigningKey(record models.SigningKey) (*signingKey, error) {
+	privateKeyPEM, err := decryptPrivateKey(record.ID, record.EncryptedPrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(privateKeyPEM)
+	if block == nil {
+		return nil, errors.New("invalid PEM data")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &signingKey{
+		kid:       record.ID,
+		createdAt: record.CreatedAt,
+		retired:   record.RetiredAt != nil,
+	}
+	switch privateKey := parsed.(type) {
+	case ed25519.PrivateKey:
+		if record.Algorithm != AlgorithmEdDSA {
+			return nil, fmt.Errorf("key type does not match algorithm %s", record.Algorithm)
+		}
+		key.method = jwt.SigningMethodEdDSA
+		key.privateKey = privateKey
+	case *rsa.PrivateKey:
+		if record.Algorithm != AlgorithmRS256 {
+			return nil, fmt.Errorf("key type does not match algorithm %s", record.Algorithm)
+		}
+		key.method = jwt.SigningMethodRS256
+		key.privateKey = privateKey
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+	return key, nil
+}
+
+// activeSigningKey trả về khoá đang dùng để ký token
+func activeSigningKey() (*signingKey, error) {
+	keyStore.RLock()
+	defer keyStore.RUnlock()
+	if keyStore.active == nil {
+		return nil, errors.New("signing keys are not initialized")
+	}
+	return keyStore.active, nil
+}
+
+// verificationKey tìm khoá theo kid trong header của token. Token phải có kid đã biết
+// và thuật toán trong header phải đúng với thuật toán của khoá đó.
+func verificationKey(token *jwt.Token) (interface{}, error) {
+	kid, ok := token.Header["kid"].(string)
+	if !ok || kid == "" {
+		return nil, errUnknownKey
+	}
+
+	keyStore.RLock()
+	key, ok := keyStore.keys[kid]
+	keyStore.RUnlock()
+	if !ok {
+		// Khoá có thể vừa được instance khác tạo ra, thử nạp lại (tối đa mỗi 10 giây một lần)
+		if !reloadSigningKeys() {
+			return nil, errUnknownKey
+		}
+		keyStore.RLock()
+		key, ok = keyStore.keys[kid]
+		keyStore.RUnlock()
+		if !ok {
+			return nil, errUnknownKey
+		}
+	}
+
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing algorithm %s for key %s", token.Method.Alg(), kid)
+	}
+	return key.privateKey.Public(), nil
+}
+
+// JWK là một khoá công khai theo định dạng JSON Web Key (RFC 7517)
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+// PublicJWKS trả về danh sách khoá công khai đang được công bố (bao gồm khoá đã ngừng ký nhưng chưa hết hạn)
+func PublicJWKS() []JWK {
+	keyStore.RLock()
+	defer keyStore.RUnlock()
+
+	jwks := make([]JWK, 0, len(keyStore.keys))
+	for _, key := range keyStore.keys {
+		jwk := JWK{Kid: key.kid, Use: "sig", Alg: key.method.Alg()}
+		switch publicKey := key.privateKey.Public().(type) {
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(publicKey)
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes())
+		}
+		jwks = append(jwks, jwk)
+	}
+	return jwks
+}